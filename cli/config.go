@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/takaishi/fif/config"
+	"github.com/takaishi/fif/editor"
+)
+
+// RunConfig implements the "config" subcommand
+// With --show it prints the effective configuration after merging the config
+// files and the environment. It returns the process exit code.
+func RunConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fif config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	showFlag := fs.Bool("show", false, "Print the effective configuration")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: fif config --show\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return ExitError
+	}
+	if !*showFlag || fs.NArg() != 0 {
+		fs.Usage()
+		return ExitError
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	if err := cfg.RegisterEditors(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	if cfg.Editor == "" {
+		// Show the editor fif would pick, if any
+		if ed, err := editor.DetectEditor(); err == nil {
+			cfg.Editor = ed
+		}
+	}
+
+	if userPath, err := config.UserConfigPath(); err == nil {
+		fmt.Fprintf(stdout, "# User config: %s\n", userPath)
+	}
+	if repoPath, ok := config.RepoConfigPath(dir); ok {
+		fmt.Fprintf(stdout, "# Repository config: %s\n", repoPath)
+	}
+	if len(cfg.Sources) == 0 {
+		fmt.Fprintf(stdout, "# No config file loaded, showing defaults\n")
+	}
+	for _, source := range cfg.Sources {
+		fmt.Fprintf(stdout, "# Loaded: %s\n", source)
+	}
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintf(stdout, "# Ignored from the repository config (set trust_repo_config = true in the user config to use them): %s\n", strings.Join(cfg.Ignored, ", "))
+	}
+	fmt.Fprintln(stdout)
+
+	if err := cfg.WriteTOML(stdout); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/search"
+)
+
+// formatter writes search results to the output in one format
+type formatter interface {
+	// Write writes a single result; path is the path to print for its file
+	Write(path string, result *search.SearchResult) error
+	// Close flushes any buffered output
+	Close() error
+}
+
+// newFormatter returns the formatter for the given format name
+// opts describe the search for the export formats.
+func newFormatter(format string, w io.Writer, opts export.Options) (formatter, error) {
+	out := bufio.NewWriter(w)
+	switch format {
+	case "json":
+		return &jsonFormatter{out: out, enc: json.NewEncoder(out)}, nil
+	case "vimgrep":
+		return &vimgrepFormatter{out: out}, nil
+	case "grouped":
+		return &groupedFormatter{out: out}, nil
+	}
+	if f, err := export.Parse(format); err == nil {
+		return &exportFormatter{out: out, format: f, opts: opts}, nil
+	}
+	if strings.Contains(format, "{") {
+		return newTemplateFormatter(format, out)
+	}
+	return nil, fmt.Errorf("invalid format %q (json, vimgrep, grouped, quickfix, emacs, markdown, csv, sarif or a template like {file}:{line})", format)
+}
+
+// jsonMatch is a submatch in JSON output
+type jsonMatch struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// jsonResult is a single line of JSON output
+type jsonResult struct {
+	File    string      `json:"file"`
+	Line    int         `json:"line"`
+	Column  int         `json:"column"`
+	Text    string      `json:"text"`
+	Matches []jsonMatch `json:"matches"`
+	// Offset of text within the line when a long line was cropped
+	TextOffset int  `json:"text_offset,omitempty"`
+	Binary     bool `json:"binary,omitempty"`
+}
+
+// jsonFormatter writes one JSON object per matching line (JSON lines)
+type jsonFormatter struct {
+	out *bufio.Writer
+	enc *json.Encoder
+}
+
+func (f *jsonFormatter) Write(path string, result *search.SearchResult) error {
+	matches := make([]jsonMatch, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		matches = append(matches, jsonMatch{Start: m.Start, End: m.End, Text: m.Text})
+	}
+	return f.enc.Encode(jsonResult{
+		File:       path,
+		Line:       result.Line,
+		Column:     result.Column,
+		Text:       result.Text,
+		Matches:    matches,
+		TextOffset: result.TextOffset,
+		Binary:     result.Binary,
+	})
+}
+
+func (f *jsonFormatter) Close() error {
+	return f.out.Flush()
+}
+
+// vimgrepFormatter writes file:line:column:text, one line per match like rg --vimgrep
+type vimgrepFormatter struct {
+	out *bufio.Writer
+}
+
+func (f *vimgrepFormatter) Write(path string, result *search.SearchResult) error {
+	text := result.DisplayText()
+	if len(result.Matches) == 0 {
+		_, err := fmt.Fprintf(f.out, "%s:%d:%d:%s\n", path, result.Line, result.Column, text)
+		return err
+	}
+	for _, m := range result.Matches {
+		// Column is the first match's, in the whole line even if Text is cropped
+		column := result.Column + utf8.RuneCountInString(result.Text[result.Matches[0].Start:m.Start])
+		if _, err := fmt.Fprintf(f.out, "%s:%d:%d:%s\n", path, result.Line, column, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *vimgrepFormatter) Close() error {
+	return f.out.Flush()
+}
+
+// exportFormatter writes the results in an export format once they are all in
+type exportFormatter struct {
+	out     *bufio.Writer
+	format  export.Format
+	opts    export.Options
+	results []*search.SearchResult
+}
+
+// Write collects the result; export writes paths relative to opts.Dir itself
+func (f *exportFormatter) Write(path string, result *search.SearchResult) error {
+	f.results = append(f.results, result)
+	return nil
+}
+
+func (f *exportFormatter) Close() error {
+	if err := export.Write(f.out, f.format, f.results, f.opts); err != nil {
+		return err
+	}
+	return f.out.Flush()
+}
+
+// groupedFormatter writes a file header followed by its matching lines,
+// for reading by humans
+type groupedFormatter struct {
+	out         *bufio.Writer
+	currentFile string
+	matches     int
+	files       int
+}
+
+func (f *groupedFormatter) Write(path string, result *search.SearchResult) error {
+	if path != f.currentFile {
+		if f.files > 0 {
+			if _, err := fmt.Fprintln(f.out); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(f.out, path); err != nil {
+			return err
+		}
+		f.currentFile = path
+		f.files++
+	}
+	f.matches++
+	_, err := fmt.Fprintf(f.out, "%6d:%-4d %s\n", result.Line, result.Column, result.DisplayText())
+	return err
+}
+
+func (f *groupedFormatter) Close() error {
+	if f.files > 0 {
+		fmt.Fprintf(f.out, "\n%s in %s\n", search.Plural(f.matches, "match", "matches"), search.Plural(f.files, "file", "files"))
+	}
+	return f.out.Flush()
+}
+
+// placeholderPattern matches the placeholders of a template format
+var placeholderPattern = regexp.MustCompile(`\{[a-z]*\}`)
+
+// templateFormatter writes one line per matching line, filling the
+// placeholders {file}, {line}, {col} and {text} of a template
+type templateFormatter struct {
+	out      *bufio.Writer
+	template string
+}
+
+// newTemplateFormatter returns a template formatter, rejecting unknown placeholders
+func newTemplateFormatter(template string, out *bufio.Writer) (*templateFormatter, error) {
+	for _, p := range placeholderPattern.FindAllString(template, -1) {
+		switch p {
+		case "{file}", "{line}", "{col}", "{text}":
+		default:
+			return nil, fmt.Errorf("invalid placeholder %s in format %q ({file}, {line}, {col} or {text})", p, template)
+		}
+	}
+	return &templateFormatter{out: out, template: template}, nil
+}
+
+func (f *templateFormatter) Write(path string, result *search.SearchResult) error {
+	line := placeholderPattern.ReplaceAllStringFunc(f.template, func(p string) string {
+		switch p {
+		case "{file}":
+			return path
+		case "{line}":
+			return strconv.Itoa(result.Line)
+		case "{col}":
+			return strconv.Itoa(result.Column)
+		}
+		return result.DisplayText()
+	})
+	_, err := fmt.Fprintln(f.out, line)
+	return err
+}
+
+func (f *templateFormatter) Close() error {
+	return f.out.Flush()
+}
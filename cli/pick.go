@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/search"
+)
+
+// CheckFormat reports an error if format is not a valid output format
+func CheckFormat(format string) error {
+	_, err := newFormatter(format, io.Discard, export.Options{})
+	return err
+}
+
+// WritePicked writes the results picked in the TUI (fif --pick) to w in
+// format, with paths relative to currentDir like fif search
+// History results have no file on disk: their {file} is the git object name
+// <commit>:<path>, which git show accepts from anywhere in the repository.
+func WritePicked(results []*search.SearchResult, format, currentDir string, w io.Writer) error {
+	formatter, err := newFormatter(format, w, export.Options{Dir: currentDir})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := formatter.Write(pickedPath(result, currentDir), result); err != nil {
+			return err
+		}
+	}
+	return formatter.Close()
+}
+
+// pickedPath returns the path printed for a picked result: relative to
+// currentDir, or for a history result the full commit hash (with ^ for a
+// removed line) and the path from the repository root
+func pickedPath(result *search.SearchResult, currentDir string) string {
+	rev := result.Revision
+	if rev == nil {
+		return result.RelPath(currentDir)
+	}
+	path := rev.Path
+	if gitRoot, ok := search.FindGitRoot(result.Root); ok {
+		if rel, err := filepath.Rel(gitRoot, filepath.Join(result.Root, rev.Path)); err == nil {
+			path = rel
+		}
+	}
+	return rev.Rev + ":" + filepath.ToSlash(path)
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/takaishi/fif/search"
+)
+
+func TestWritePicked(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "cmd")
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	results := []*search.SearchResult{
+		{File: filepath.Join("pkg", "a.go"), Root: root, Line: 3, Column: 2},
+		{
+			// A history search run from the cmd directory
+			File: "0123456:tool/main.go", Root: dir, Line: 7, Column: 1,
+			Revision: &search.Revision{Rev: hash, Hash: hash, Path: "tool/main.go"},
+		},
+		{
+			File: "0123456^:tool/old.go", Root: dir, Line: 9, Column: 4,
+			Revision: &search.Revision{Rev: hash + "^", Hash: hash, Path: "tool/old.go", Removed: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePicked(results, "{file}:{line}:{col}", dir, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join("..", "pkg", "a.go") + ":3:2\n" +
+		hash + ":cmd/tool/main.go:7:1\n" +
+		hash + "^:cmd/tool/old.go:9:4\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WritePicked() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/takaishi/fif/config"
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/search"
+)
+
+// Exit codes compatible with grep and ripgrep
+const (
+	ExitMatch   = 0 // At least one match was found
+	ExitNoMatch = 1 // The search ran but found nothing
+	ExitError   = 2 // The search could not be run
+
+	// ExitCancelled is the exit code of a pick that was cancelled, as for a
+	// program interrupted by Ctrl+C (128 + SIGINT) and like fzf's
+	ExitCancelled = 130
+)
+
+// RunSearch runs a search without the TUI and writes the results to stdout
+// args are the arguments after the "search" subcommand. The mask, hidden
+// files and extra rg arguments default to the config file. It returns the
+// process exit code.
+func RunSearch(args []string, stdout, stderr io.Writer) int {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	cfg, err := config.Load(currentDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+
+	fs := flag.NewFlagSet("fif search", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	formatFlag := fs.String("format", "vimgrep", "Output format: json, vimgrep, grouped, an export format (quickfix, emacs, markdown, csv, sarif) or a template like \"{file}:{line}:{col}\"")
+	outputFlag := fs.String("output", "", "Write the results to this file instead of stdout, e.g. results.sarif")
+	maskFlag := fs.String("mask", cfg.Mask, "Comma-separated file masks, e.g. \"*.go, !*_test.go\"")
+	scopeFlag := fs.String("scope", "", "Search scope: project, directory, changed, staged, untracked, diff[:REF], recent[:N], a directory or a named scope from the config file (default: project inside a git repository)")
+	regexFlag := fs.Bool("regex", false, "Treat the query as a regular expression")
+	caseFlag := fs.String("case", "smart", "Match case: smart, sensitive or insensitive")
+	wordFlag := fs.Bool("word", false, "Only match whole words")
+	hiddenFlag := fs.Bool("hidden", cfg.Hidden, "Search hidden files and directories")
+	noIgnoreFlag := fs.Bool("no-ignore", false, "Don't respect .gitignore and other ignore files")
+	maxCountFlag := fs.Int("max-count", 0, "Maximum number of matching lines per file (0 means unlimited)")
+	maxFilesizeFlag := fs.String("max-filesize", cfg.MaxFilesize, "Skip files larger than this, e.g. 10M (K, M or G suffix)")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: fif search [flags] <query> [path...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitMatch
+		}
+		return ExitError
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return ExitError
+	}
+
+	out := stdout
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return ExitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	formatter, err := newFormatter(*formatFlag, out, export.Options{Dir: currentDir, Query: fs.Arg(0)})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+
+	caseMode, err := search.ParseCaseMode(*caseFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+
+	if *maxFilesizeFlag != "" {
+		if _, err := search.ParseFilesize(*maxFilesizeFlag); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	mask, err := search.ParseMask(*maskFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+
+	dir, roots, err := resolveScope(*scopeFlag, fs.Args()[1:], currentDir, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+
+	opts := search.Options{
+		Query:       fs.Arg(0),
+		Regex:       *regexFlag,
+		CaseMode:    caseMode,
+		WholeWord:   *wordFlag,
+		Dir:         dir,
+		Roots:       roots,
+		Includes:    mask.Includes,
+		Excludes:    mask.Excludes,
+		Hidden:      *hiddenFlag,
+		NoIgnore:    *noIgnoreFlag,
+		MaxCount:    *maxCountFlag,
+		MaxFilesize: *maxFilesizeFlag,
+		ExtraArgs:   cfg.RgArgs,
+	}
+
+	found := 0
+	var skipped search.Skipped
+	searcher := search.NewSearcher()
+	for msg := range searcher.Search(context.Background(), opts) {
+		if msg.Error != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", msg.Error)
+			return ExitError
+		}
+		skipped = msg.Skipped
+		for _, result := range msg.Results {
+			if err := formatter.Write(result.RelPath(currentDir), result); err != nil {
+				fmt.Fprintf(stderr, "Error: %v\n", err)
+				return ExitError
+			}
+			found++
+		}
+	}
+	if err := formatter.Close(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitError
+	}
+	if s := skipped.String(); s != "" {
+		fmt.Fprintf(stderr, "fif: %s\n", s)
+	}
+
+	if found == 0 {
+		return ExitNoMatch
+	}
+	return ExitMatch
+}
+
+// resolveScope returns the directory rg runs in and the paths it searches for a scope name
+// Like the TUI, the default is project scope (the git root) inside a
+// repository and the current directory otherwise, and paths are searched
+// as a directory scope.
+// The diff and recent scopes take their ref and commit count after a colon,
+// e.g. diff:main or recent:3, and default to the config file's [git] settings.
+func resolveScope(name string, paths []string, currentDir string, cfg *config.Config) (string, []string, error) {
+	if len(paths) > 0 {
+		if name != "" {
+			return "", nil, errors.New("--scope can't be combined with paths to search")
+		}
+		roots, err := config.PathRoots(paths)
+		if err != nil {
+			return "", nil, err
+		}
+		name, paths = "dirs", roots
+	}
+	gitRoot, isGitRepo := search.FindGitRoot(currentDir)
+	if !isGitRepo {
+		gitRoot = ""
+	}
+	env := scope.Env{GitRoot: gitRoot, CurrentDir: currentDir}
+
+	s, err := scope.Parse(name, paths, env, cfg.ScopeSettings())
+	if err != nil {
+		return "", nil, err
+	}
+	return s.Resolve(env)
+}
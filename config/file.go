@@ -0,0 +1,397 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/highlight"
+	"github.com/takaishi/fif/keymap"
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/search"
+	"github.com/takaishi/fif/searches"
+)
+
+const (
+	// repoConfigName is the name of the per-repository config file at the git root
+	repoConfigName = ".fif.toml"
+
+	// Defaults used when no config file sets a value
+	defaultPreviewBefore = 5
+	defaultPreviewAfter  = 10
+	defaultDebounce      = 250 * time.Millisecond
+	defaultTheme         = "default"
+	defaultKeymap        = "default"
+)
+
+// File is the contents of a config file
+// Pointer fields distinguish "not set" from the zero value so that a
+// repository config can override a user config with false or 0.
+type File struct {
+	Editor     *string               `toml:"editor"`
+	StayOpen   *bool                 `toml:"stay_open"`
+	Mask       *string               `toml:"mask"`
+	Hidden     *bool                 `toml:"hidden"`
+	RgArgs     []string              `toml:"rg_args"`
+	MaxSize    *string               `toml:"max_filesize"`
+	DebounceMs *int                  `toml:"debounce_ms"`
+	Theme      *string               `toml:"theme"`
+	Syntax     *string               `toml:"syntax_theme"`
+	Preview    PreviewFile           `toml:"preview"`
+	Git        GitFile               `toml:"git"`
+	Keymap     *string               `toml:"keymap"`
+	Keys       map[string]string     `toml:"keys"`
+	Editors    map[string]EditorFile `toml:"editors"`
+	Scopes     map[string]ScopeFile  `toml:"scopes"`
+	Searches   map[string]SearchFile `toml:"searches"`
+
+	// TrustRepo lets the repository config set what runs commands; it is
+	// only read from the user config
+	TrustRepo *bool `toml:"trust_repo_config"`
+}
+
+// PreviewFile is the [preview] table of a config file
+type PreviewFile struct {
+	Before *int  `toml:"before"`
+	After  *int  `toml:"after"`
+	Fit    *bool `toml:"fit"` // Size the context to the preview pane (default: unless before or after is set)
+}
+
+// GitFile is the [git] table of a config file
+type GitFile struct {
+	Base    *string `toml:"base"`    // Ref the diff scope compares against
+	Commits *int    `toml:"commits"` // Number of commits of the recent-commits scope
+}
+
+// EditorFile is an [editors.<name>] table of a config file
+// It defines an editor that can be selected by name like the built-in ones.
+type EditorFile struct {
+	Command  []string `toml:"command"`  // Program and arguments with {file}, {line} and {col} placeholders
+	Terminal bool     `toml:"terminal"` // Whether the editor runs in the terminal
+}
+
+// ScopeFile is a [scopes.<name>] table of a config file
+// It defines a named search scope covering several directories.
+type ScopeFile struct {
+	Roots []string `toml:"roots"` // Directories, relative to the git root (or the current directory outside a repository)
+}
+
+// SearchFile is a [searches.<name>] table of a config file
+// It defines a saved search that fif --saved <name> starts with.
+type SearchFile struct {
+	Query string `toml:"query"`
+	Mask  string `toml:"mask,omitempty"`  // File mask (empty: all files)
+	Scope string `toml:"scope,omitempty"` // Scope spec like --scope (empty: the default scope)
+	Regex bool   `toml:"regex,omitempty"`
+	Case  string `toml:"case,omitempty"` // smart, sensitive or insensitive
+	Word  bool   `toml:"word,omitempty"`
+}
+
+// UserConfigPath returns the path of the per-user config file
+// It is $XDG_CONFIG_HOME/fif/config.toml, falling back to ~/.config/fif/config.toml.
+func UserConfigPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "fif", "config.toml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "fif", "config.toml"), nil
+}
+
+// RepoConfigPath returns the path of the per-repository config file for dir
+// It returns false if dir is not inside a git repository.
+func RepoConfigPath(dir string) (string, bool) {
+	gitRoot, ok := search.FindGitRoot(dir)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(gitRoot, repoConfigName), true
+}
+
+// defaults returns the configuration used when nothing else is set
+func defaults() *Config {
+	return &Config{
+		PreviewBefore: defaultPreviewBefore,
+		PreviewAfter:  defaultPreviewAfter,
+		PreviewFit:    true,
+		Debounce:      defaultDebounce,
+		Theme:         defaultTheme,
+		GitCommits:    scope.DefaultCommits,
+		Keymap:        defaultKeymap,
+		Keys:          make(map[string]string),
+		Editors:       make(map[string]editor.Definition),
+		Scopes:        make(map[string][]string),
+		Searches:      make(map[string]searches.Entry),
+	}
+}
+
+// Load returns the configuration for a session started in dir
+// Settings are merged with the precedence environment > repository config
+// (.fif.toml at the git root) > user config > defaults. Command line flags
+// are applied on top by ParseFlags. A missing config file is not an error.
+// A repository config comes with the clone, so unless the user config sets
+// trust_repo_config its settings that run commands are left out.
+func Load(dir string) (*Config, error) {
+	cfg := defaults()
+
+	userPath, err := UserConfigPath()
+	if err == nil {
+		if err := cfg.loadFile(userPath, false); err != nil {
+			return nil, err
+		}
+	}
+	if repoPath, ok := RepoConfigPath(dir); ok {
+		if err := cfg.loadFile(repoPath, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if envEditor := getEnvEditor(); envEditor != "" {
+		cfg.Editor = editor.Editor(envEditor)
+	}
+
+	return cfg, nil
+}
+
+// loadFile merges the config file at path into c, if it exists
+// A repository config can't set trust_repo_config, and only sets rg_args,
+// editor and [editors] if the user config trusts it.
+func (c *Config) loadFile(path string, repo bool) error {
+	var f File
+	md, err := toml.DecodeFile(path, &f)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("config %s: unknown setting %q", path, undecoded[0].String())
+	}
+	if err := f.validate(); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if repo {
+		if f.TrustRepo != nil {
+			return fmt.Errorf("config %s: trust_repo_config can only be set in the user config", path)
+		}
+		if !c.TrustRepoConfig {
+			c.Ignored = append(c.Ignored, f.untrusted()...)
+			f.RgArgs, f.Editor, f.Editors = nil, nil, nil
+		}
+	}
+	c.merge(f)
+	c.Sources = append(c.Sources, path)
+	return nil
+}
+
+// validate reports settings that can't be used
+func (f File) validate() error {
+	if f.Mask != nil {
+		if _, err := search.ParseMask(*f.Mask); err != nil {
+			return fmt.Errorf("mask: %w", err)
+		}
+	}
+	if f.MaxSize != nil && *f.MaxSize != "" {
+		if _, err := search.ParseFilesize(*f.MaxSize); err != nil {
+			return fmt.Errorf("max_filesize: %w", err)
+		}
+	}
+	if f.Syntax != nil {
+		if err := highlight.ValidateTheme(*f.Syntax); err != nil {
+			return fmt.Errorf("syntax_theme: %w", err)
+		}
+	}
+	if f.Keymap != nil {
+		if _, err := keymap.New(*f.Keymap, nil); err != nil {
+			return err
+		}
+	}
+	if f.DebounceMs != nil && *f.DebounceMs < 0 {
+		return fmt.Errorf("debounce_ms must not be negative")
+	}
+	if f.Preview.Before != nil && *f.Preview.Before < 0 {
+		return fmt.Errorf("preview.before must not be negative")
+	}
+	if f.Preview.After != nil && *f.Preview.After < 0 {
+		return fmt.Errorf("preview.after must not be negative")
+	}
+	if f.Git.Commits != nil && *f.Git.Commits < 1 {
+		return fmt.Errorf("git.commits must be at least 1")
+	}
+	for name, s := range f.Searches {
+		if s.Query == "" {
+			return fmt.Errorf("searches.%s: query is empty", name)
+		}
+		if _, err := search.ParseMask(s.Mask); err != nil {
+			return fmt.Errorf("searches.%s: mask: %w", name, err)
+		}
+		if _, err := search.ParseCaseMode(s.Case); err != nil {
+			return fmt.Errorf("searches.%s: %w", name, err)
+		}
+	}
+	for name, sc := range f.Scopes {
+		if len(sc.Roots) == 0 {
+			return fmt.Errorf("scopes.%s: roots is empty", name)
+		}
+	}
+	for name, e := range f.Editors {
+		if len(e.Command) == 0 {
+			return fmt.Errorf("editors.%s: command is empty", name)
+		}
+		if !strings.Contains(strings.Join(e.Command, " "), "{file}") {
+			return fmt.Errorf("editors.%s: command has no {file} placeholder", name)
+		}
+	}
+	return nil
+}
+
+// untrusted returns the names of the settings that run commands: rg can
+// run a preprocessor (--pre), and editors are programs
+func (f File) untrusted() []string {
+	var names []string
+	if f.RgArgs != nil {
+		names = append(names, "rg_args")
+	}
+	if f.Editor != nil {
+		names = append(names, "editor")
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Editors)) {
+		names = append(names, "editors."+name)
+	}
+	return names
+}
+
+// merge overrides the settings of c with the ones set in f
+func (c *Config) merge(f File) {
+	if f.TrustRepo != nil {
+		c.TrustRepoConfig = *f.TrustRepo
+	}
+	if f.Editor != nil {
+		c.Editor = editor.Editor(*f.Editor)
+	}
+	if f.StayOpen != nil {
+		c.StayOpen = *f.StayOpen
+	}
+	if f.Mask != nil {
+		c.Mask = *f.Mask
+	}
+	if f.Hidden != nil {
+		c.Hidden = *f.Hidden
+	}
+	if f.RgArgs != nil {
+		c.RgArgs = f.RgArgs
+	}
+	if f.MaxSize != nil {
+		c.MaxFilesize = *f.MaxSize
+	}
+	if f.DebounceMs != nil {
+		c.Debounce = time.Duration(*f.DebounceMs) * time.Millisecond
+	}
+	if f.Theme != nil {
+		c.Theme = *f.Theme
+	}
+	if f.Syntax != nil {
+		c.SyntaxTheme = *f.Syntax
+	}
+	// Configured context sizes are used as is unless fitting is asked for explicitly
+	if f.Preview.Before != nil {
+		c.PreviewBefore = *f.Preview.Before
+		c.PreviewFit = false
+	}
+	if f.Preview.After != nil {
+		c.PreviewAfter = *f.Preview.After
+		c.PreviewFit = false
+	}
+	if f.Preview.Fit != nil {
+		c.PreviewFit = *f.Preview.Fit
+	}
+	if f.Git.Base != nil {
+		c.GitBase = *f.Git.Base
+	}
+	if f.Git.Commits != nil {
+		c.GitCommits = *f.Git.Commits
+	}
+	if f.Keymap != nil {
+		c.Keymap = *f.Keymap
+	}
+	for action, key := range f.Keys {
+		c.Keys[action] = key
+	}
+	for name, s := range f.Searches {
+		c.Searches[name] = searches.Entry{
+			Query:       s.Query,
+			Mask:        s.Mask,
+			MaskEnabled: s.Mask != "",
+			Scope:       s.Scope,
+			Regex:       s.Regex,
+			Case:        s.Case,
+			Word:        s.Word,
+		}
+	}
+	for name, sc := range f.Scopes {
+		c.Scopes[name] = sc.Roots
+	}
+	for name, e := range f.Editors {
+		c.Editors[name] = editor.Definition{Name: name, Command: e.Command, Terminal: e.Terminal}
+	}
+}
+
+// WriteTOML writes the configuration in config file format
+func (c *Config) WriteTOML(w io.Writer) error {
+	editorName := string(c.Editor)
+	debounceMs := int(c.Debounce / time.Millisecond)
+	rgArgs := c.RgArgs
+	if rgArgs == nil {
+		rgArgs = []string{}
+	}
+	f := File{
+		Editor:     &editorName,
+		StayOpen:   &c.StayOpen,
+		Mask:       &c.Mask,
+		Hidden:     &c.Hidden,
+		RgArgs:     rgArgs,
+		MaxSize:    &c.MaxFilesize,
+		DebounceMs: &debounceMs,
+		Theme:      &c.Theme,
+		Syntax:     &c.SyntaxTheme,
+		Preview: PreviewFile{
+			Before: &c.PreviewBefore,
+			After:  &c.PreviewAfter,
+			Fit:    &c.PreviewFit,
+		},
+		Git: GitFile{
+			Base:    &c.GitBase,
+			Commits: &c.GitCommits,
+		},
+		Keymap:    &c.Keymap,
+		Keys:      c.Keys,
+		TrustRepo: &c.TrustRepoConfig,
+		Editors:   make(map[string]EditorFile, len(c.Editors)),
+		Scopes:    make(map[string]ScopeFile, len(c.Scopes)),
+		Searches:  make(map[string]SearchFile, len(c.Searches)),
+	}
+	for name, e := range c.Searches {
+		f.Searches[name] = SearchFile{Query: e.Query, Mask: e.Mask, Scope: e.Scope, Regex: e.Regex, Case: e.Case, Word: e.Word}
+	}
+	for name, roots := range c.Scopes {
+		f.Scopes[name] = ScopeFile{Roots: roots}
+	}
+	for name, def := range c.Editors {
+		f.Editors[name] = EditorFile{Command: def.Command, Terminal: def.Terminal}
+	}
+	enc := toml.NewEncoder(w)
+	enc.Indent = ""
+	return enc.Encode(f)
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takaishi/fif/editor"
+)
+
+// configDirs writes a user config and a repository config (either may be
+// empty for none) and returns a directory inside the repository
+// The environment is isolated from the user's own config.
+func configDirs(t *testing.T, user, repo string) (dir, userPath, repoPath string) {
+	t.Helper()
+	base := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(base, "xdg"))
+	t.Setenv("FIF_EDITOR", "")
+
+	userPath = filepath.Join(base, "xdg", "fif", "config.toml")
+	repoPath = filepath.Join(base, "repo", repoConfigName)
+	dir = filepath.Join(base, "repo", "cmd", "tool")
+	for _, d := range []string{filepath.Dir(userPath), filepath.Join(base, "repo", ".git"), dir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{userPath: user, repoPath: repo} {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, userPath, repoPath
+}
+
+func TestLoadDefaults(t *testing.T) {
+	dir, _, _ := configDirs(t, "", "")
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, defaults()) {
+		t.Errorf("Load() without config files = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	user := `
+editor = "nvim"
+theme = "light"
+mask = "*.go"
+debounce_ms = 50
+keymap = "vim"
+
+[preview]
+before = 3
+
+[keys]
+help = "f1"
+open = "ctrl+o"
+`
+	repo := `
+mask = "*.md"
+hidden = true
+keymap = "emacs"
+
+[keys]
+open = "ctrl+j"
+`
+	dir, userPath, repoPath := configDirs(t, user, repo)
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// user config over the defaults
+	if cfg.Theme != "light" || cfg.Debounce != 50*time.Millisecond || cfg.Editor != "nvim" {
+		t.Errorf("theme, debounce, editor = %q, %v, %q, want the user config's", cfg.Theme, cfg.Debounce, cfg.Editor)
+	}
+	if cfg.PreviewBefore != 3 || cfg.PreviewAfter != defaultPreviewAfter || cfg.PreviewFit {
+		t.Errorf("preview = %d, %d, fit %v, want 3, %d and no fitting", cfg.PreviewBefore, cfg.PreviewAfter, cfg.PreviewFit, defaultPreviewAfter)
+	}
+	// repository config over the user config
+	if cfg.Mask != "*.md" || !cfg.Hidden || cfg.Keymap != "emacs" {
+		t.Errorf("mask, hidden, keymap = %q, %v, %q, want the repository config's", cfg.Mask, cfg.Hidden, cfg.Keymap)
+	}
+	if want := map[string]string{"help": "f1", "open": "ctrl+j"}; !reflect.DeepEqual(cfg.Keys, want) {
+		t.Errorf("keys = %v, want %v", cfg.Keys, want)
+	}
+	if want := []string{userPath, repoPath}; !reflect.DeepEqual(cfg.Sources, want) {
+		t.Errorf("sources = %q, want %q", cfg.Sources, want)
+	}
+
+	// the environment over the config files
+	t.Setenv("FIF_EDITOR", "hx")
+	if cfg, err := Load(dir); err != nil || cfg.Editor != "hx" {
+		t.Errorf("editor = %q, %v, want FIF_EDITOR's", cfg.Editor, err)
+	}
+
+	// flags over everything
+	start, err := cfg.StartSearch(StartFlags{Args: []string{"foo"}}, nil)
+	if err != nil || start.Mask != "*.md" {
+		t.Fatalf("start mask = %+v, %v, want the repository config's", start, err)
+	}
+	mask := "*.txt"
+	start, err = cfg.StartSearch(StartFlags{Args: []string{"foo"}, Mask: &mask}, nil)
+	if err != nil || start.Mask != mask {
+		t.Errorf("start mask = %+v, %v, want --mask's", start, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown setting", `colour = "red"`, `unknown setting "colour"`},
+		{"unknown table setting", "[preview]\nbfore = 3", `unknown setting "preview.bfore"`},
+		{"wrong type", `debounce_ms = "fast"`, "debounce_ms"},
+		{"syntax error", `mask = `, "config "},
+		{"negative debounce", `debounce_ms = -1`, "debounce_ms must not be negative"},
+		{"negative context", "[preview]\nafter = -2", "preview.after must not be negative"},
+		{"no commits", "[git]\ncommits = 0", "git.commits must be at least 1"},
+		{"bad mask", `mask = "!"`, "mask: "},
+		{"bad size", `max_filesize = "huge"`, "max_filesize: "},
+		{"unknown syntax theme", `syntax_theme = "sepia-ish"`, "unknown syntax theme"},
+		{"unknown keymap", `keymap = "helix"`, `unknown preset "helix"`},
+		{"editor without file", "[editors.x]\ncommand = [\"x\", \"+{line}\"]", "editors.x: command has no {file} placeholder"},
+		{"search without query", "[searches.todo]\nmask = \"*.go\"", "searches.todo: query is empty"},
+		{"bad search case", "[searches.todo]\nquery = \"TODO\"\ncase = \"loud\"", "searches.todo: "},
+		{"scope without roots", "[scopes.api]\nroots = []", "scopes.api: roots is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Both files are checked the same way
+			for _, repo := range []bool{false, true} {
+				user, repoContent := tt.content, ""
+				if repo {
+					user, repoContent = "", tt.content
+				}
+				dir, userPath, repoPath := configDirs(t, user, repoContent)
+				path := userPath
+				if repo {
+					path = repoPath
+				}
+				_, err := Load(dir)
+				if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), path) {
+					t.Errorf("Load() error = %v, want one about %s containing %q", err, path, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRepoTrust(t *testing.T) {
+	user := `
+editor = "nvim"
+rg_args = ["--hidden"]
+`
+	repo := `
+editor = "evil"
+rg_args = ["--pre", "./run.sh"]
+mask = "*.go"
+
+[editors.evil]
+command = ["sh", "-c", "{file}"]
+`
+	t.Run("untrusted", func(t *testing.T) {
+		dir, _, _ := configDirs(t, user, repo)
+		cfg, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Editor != "nvim" || !reflect.DeepEqual(cfg.RgArgs, []string{"--hidden"}) || len(cfg.Editors) != 0 {
+			t.Errorf("editor, rg_args, editors = %q, %q, %v, want the user config's", cfg.Editor, cfg.RgArgs, cfg.Editors)
+		}
+		if want := []string{"rg_args", "editor", "editors.evil"}; !reflect.DeepEqual(cfg.Ignored, want) {
+			t.Errorf("ignored = %q, want %q", cfg.Ignored, want)
+		}
+		if cfg.Mask != "*.go" {
+			t.Errorf("mask = %q, want the rest of the repository config applied", cfg.Mask)
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		dir, _, _ := configDirs(t, "trust_repo_config = true\n"+user, repo)
+		cfg, err := Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cfg.TrustRepoConfig || len(cfg.Ignored) != 0 {
+			t.Errorf("trusted = %v, ignored = %q", cfg.TrustRepoConfig, cfg.Ignored)
+		}
+		want := editor.Definition{Name: "evil", Command: []string{"sh", "-c", "{file}"}}
+		if cfg.Editor != "evil" || !reflect.DeepEqual(cfg.RgArgs, []string{"--pre", "./run.sh"}) || !reflect.DeepEqual(cfg.Editors["evil"], want) {
+			t.Errorf("editor, rg_args, editors = %q, %q, %v, want the repository config's", cfg.Editor, cfg.RgArgs, cfg.Editors)
+		}
+	})
+
+	t.Run("repository can't trust itself", func(t *testing.T) {
+		dir, _, repoPath := configDirs(t, user, "trust_repo_config = true\n"+repo)
+		_, err := Load(dir)
+		if err == nil || !strings.Contains(err.Error(), repoPath) || !strings.Contains(err.Error(), "only be set in the user config") {
+			t.Errorf("Load() error = %v, want trust_repo_config refused in %s", err, repoPath)
+		}
+	})
+}
@@ -2,28 +2,116 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"time"
+
 	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/searches"
 )
 
 // Config holds application configuration
 type Config struct {
-	Editor editor.Editor
+	Editor        editor.Editor
+	StayOpen      bool                         // Return to fif after opening a result instead of quitting
+	Mask          string                       // Default file mask
+	Hidden        bool                         // Search hidden files and directories
+	RgArgs        []string                     // Extra arguments passed to rg
+	MaxFilesize   string                       // Skip files larger than this, e.g. "10M" (empty means no limit)
+	Debounce      time.Duration                // Delay between typing and starting a search
+	Theme         string                       // Color theme of the TUI
+	SyntaxTheme   string                       // Syntax highlighting theme of the preview (empty: matches Theme)
+	PreviewBefore int                          // Lines shown before the hit in the preview
+	PreviewAfter  int                          // Lines shown after the hit in the preview
+	PreviewFit    bool                         // Size the preview context to the pane, keeping the before/after ratio
+	GitBase       string                       // Ref the diff scope compares against (default: detected)
+	GitCommits    int                          // Number of commits of the recent-commits scope
+	Keymap        string                       // Key binding preset: default, vim or emacs
+	Keys          map[string]string            // Key binding overrides, by action name
+	Editors       map[string]editor.Definition // User-defined editors, by name
+	Scopes        map[string][]string          // Named scopes: directories to search, by name
+	Searches      map[string]searches.Entry    // Saved searches, by name
+	Start         *searches.Entry              // Search the TUI starts with (command line arguments, --saved)
+	Pick          bool                         // Print the chosen results instead of opening them (--pick)
+	PickFormat    string                       // Format the chosen results are printed in
+
+	TrustRepoConfig bool     // Let the repository config run commands (rg_args, editor, [editors])
+	Sources         []string // Config files that were loaded, lowest precedence first
+	Ignored         []string // Settings of an untrusted repository config that were left out
 }
 
 // ParseFlags parses command line flags and returns configuration
+// Flags take precedence over the environment and config files.
 func ParseFlags() (*Config, error) {
-	editorFlag := flag.String("editor", "", "Editor to use (cursor or code)")
+	editorFlag := flag.String("editor", "", "Editor to use (e.g. cursor, code, nvim, hx, idea, or one defined in the config file)")
+	stayOpenFlag := flag.Bool("stay-open", false, "Keep fif running after opening a result in the editor")
+	savedFlag := flag.String("saved", "", "Start with a saved search from the config file")
+	maskFlag := flag.String("mask", "", "File mask to start with, e.g. \"*.go, !*_test.go\"")
+	scopeFlag := flag.String("scope", "", "Scope to start with: project, directory, a directory to search, or any other fif search --scope value")
+	regexFlag := flag.Bool("regex", false, "Start with the query as a regular expression")
+	caseSensitiveFlag := flag.Bool("case-sensitive", false, "Start with case-sensitive matching")
+	wordFlag := flag.Bool("word", false, "Start matching whole words only")
+	queryFromStdinFlag := flag.Bool("query-from-stdin", false, "Read the query from stdin (first line), e.g. the editor selection")
+	selectionFileFlag := flag.String("selection-file", "", "Read the query from a file (first line), e.g. the editor selection")
+	pickFlag := flag.Bool("pick", false, "Print the chosen results to stdout instead of opening them (exit 130 when cancelled)")
+	pickFormatFlag := flag.String("pick-format", "{file}:{line}:{col}", "Format of the chosen results: a template of {file}, {line}, {col} and {text}, or json, vimgrep or quickfix")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: fif [flags] [query] [path...]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	cfg := &Config{}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.RegisterEditors(); err != nil {
+		return nil, err
+	}
+
+	// Only explicit flags override the config files and the saved search
+	start := StartFlags{
+		Saved:          *savedFlag,
+		Args:           flag.Args(),
+		Scope:          *scopeFlag,
+		QueryFromStdin: *queryFromStdinFlag,
+		SelectionFile:  *selectionFileFlag,
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "stay-open":
+			cfg.StayOpen = *stayOpenFlag
+		case "mask":
+			start.Mask = maskFlag
+		case "regex":
+			start.Regex = regexFlag
+		case "case-sensitive":
+			start.CaseSensitive = caseSensitiveFlag
+		case "word":
+			start.Word = wordFlag
+		}
+	})
+	if cfg.Start, err = cfg.StartSearch(start, os.Stdin); err != nil {
+		return nil, err
+	}
+
+	cfg.Pick = *pickFlag
+	cfg.PickFormat = *pickFormatFlag
+	if cfg.Pick {
+		return cfg, nil // No editor is needed to pick
+	}
 
 	// Determine editor
 	if *editorFlag != "" {
 		cfg.Editor = editor.Editor(*editorFlag)
-	} else if envEditor := getEnvEditor(); envEditor != "" {
-		cfg.Editor = editor.Editor(envEditor)
-	} else {
+	}
+	if cfg.Editor == "" {
 		// Auto-detect
 		ed, err := editor.DetectEditor()
 		if err != nil {
@@ -31,10 +119,28 @@ func ParseFlags() (*Config, error) {
 		}
 		cfg.Editor = ed
 	}
+	if _, err := editor.Resolve(cfg.Editor); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+// RegisterEditors makes the user-defined editors available by name
+func (c *Config) RegisterEditors() error {
+	for _, def := range c.Editors {
+		if err := editor.Register(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScopeSettings returns the settings scope specs (e.g. --scope) refer to
+func (c *Config) ScopeSettings() scope.Settings {
+	return scope.Settings{Named: c.Scopes, GitBase: c.GitBase, Commits: c.GitCommits}
+}
+
 // getEnvEditor gets editor from FIF_EDITOR environment variable
 func getEnvEditor() string {
 	return os.Getenv("FIF_EDITOR")
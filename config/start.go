@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takaishi/fif/search"
+	"github.com/takaishi/fif/searches"
+)
+
+// StartFlags is what the command line says the TUI starts with
+type StartFlags struct {
+	Saved          string   // Saved search (--saved)
+	Args           []string // Positional arguments: the query, then paths to search
+	Mask           *string  // --mask, when given
+	Scope          string   // --scope
+	Regex          *bool    // --regex, when given
+	CaseSensitive  *bool    // --case-sensitive, when given
+	Word           *bool    // --word, when given
+	QueryFromStdin bool     // --query-from-stdin
+	SelectionFile  string   // --selection-file
+}
+
+// StartSearch returns the search the TUI starts with, or nil to start empty
+// A saved search is the base; the query, paths and flags given on the
+// command line override it. stdin is read for --query-from-stdin.
+func (c *Config) StartSearch(f StartFlags, stdin io.Reader) (*searches.Entry, error) {
+	// Without a saved search, what isn't given is as usual
+	start := searches.Entry{Mask: c.Mask, MaskEnabled: true}
+	given := false
+	if f.Saved != "" {
+		saved, ok := c.Searches[f.Saved]
+		if !ok {
+			return nil, fmt.Errorf("unknown saved search %q (define it in a [searches.%s] table of the config file)", f.Saved, f.Saved)
+		}
+		start, given = saved, true
+	}
+
+	query, hasQuery, err := f.query(stdin)
+	if err != nil {
+		return nil, err
+	}
+	if hasQuery {
+		start.Query, given = query, true
+	}
+
+	if f.Mask != nil {
+		if _, err := search.ParseMask(*f.Mask); err != nil {
+			return nil, err
+		}
+		start.Mask, start.MaskEnabled, given = *f.Mask, *f.Mask != "", true
+	}
+	if f.Regex != nil {
+		start.Regex, given = *f.Regex, true
+	}
+	if f.CaseSensitive != nil {
+		start.Case, given = "", true
+		if *f.CaseSensitive {
+			start.Case = "sensitive"
+		}
+	}
+	if f.Word != nil {
+		start.Word, given = *f.Word, true
+	}
+
+	// Paths after the query are searched as a directory scope
+	paths := f.Args[min(len(f.Args), 1):]
+	if f.QueryFromStdin || f.SelectionFile != "" {
+		paths = f.Args // The query came from elsewhere: all arguments are paths
+	}
+	switch {
+	case len(paths) > 0 && f.Scope != "":
+		return nil, errors.New("--scope can't be combined with paths to search")
+	case len(paths) > 0:
+		roots, err := PathRoots(paths)
+		if err != nil {
+			return nil, err
+		}
+		start.Scope, start.Roots, given = "dirs", roots, true
+	case f.Scope != "":
+		start.Scope, start.Roots, given = f.Scope, nil, true
+	}
+
+	if !given {
+		return nil, nil
+	}
+	return &start, nil
+}
+
+// query returns the query given as the first argument, on stdin or in the
+// selection file
+// Only the first line of a multi-line selection is used, since queries
+// are single lines.
+func (f StartFlags) query(stdin io.Reader) (string, bool, error) {
+	var data []byte
+	switch {
+	case f.QueryFromStdin && f.SelectionFile != "":
+		return "", false, errors.New("--query-from-stdin and --selection-file can't be combined")
+	case f.QueryFromStdin:
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read the query from stdin: %w", err)
+		}
+		data = b
+	case f.SelectionFile != "":
+		b, err := os.ReadFile(f.SelectionFile)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read the selection file: %w", err)
+		}
+		data = b
+	case len(f.Args) > 0:
+		data = []byte(f.Args[0])
+	default:
+		return "", false, nil
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimRight(line, "\r"), true, nil
+}
+
+// PathRoots returns the absolute roots of the dirs scope searching paths
+// given on the command line, or an error naming a path that doesn't exist
+func PathRoots(paths []string) ([]string, error) {
+	roots := make([]string, len(paths))
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				err = pathErr.Err // The path is in the message already
+			}
+			return nil, fmt.Errorf("cannot search %s: %w", path, err)
+		}
+		roots[i] = abs
+	}
+	return roots, nil
+}
@@ -12,6 +12,7 @@ import (
 )
 
 // Editor represents an editor type
+// It is the name of a registered editor (see Resolve) or a command line such as $EDITOR.
 type Editor string
 
 const (
@@ -20,33 +21,147 @@ const (
 )
 
 // DetectEditor detects which editor is available
+// Inside a Cursor or VS Code terminal that editor is used. Otherwise
+// $VISUAL and $EDITOR are honored before looking for cursor and code.
 func DetectEditor() (Editor, error) {
+	if isRunningInEditor() {
+		if ed, ok := findGUIEditor(); ok {
+			return ed, nil
+		}
+	}
+
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+			if _, err := Resolve(Editor(value)); err == nil {
+				return Editor(value), nil
+			}
+		}
+	}
+
+	if ed, ok := findGUIEditor(); ok {
+		return ed, nil
+	}
+
+	return "", fmt.Errorf("no editor found (cursor or code, $VISUAL or $EDITOR)")
+}
+
+// findGUIEditor looks for Cursor and VS Code in PATH
+func findGUIEditor() (Editor, bool) {
 	// Check for cursor first
 	if _, err := exec.LookPath("cursor"); err == nil {
-		return EditorCursor, nil
+		return EditorCursor, true
 	}
 
 	// Then check for code
 	if _, err := exec.LookPath("code"); err == nil {
-		return EditorCode, nil
+		return EditorCode, true
 	}
 
-	return "", fmt.Errorf("no editor found (cursor or code)")
+	return "", false
+}
+
+// Command returns the command that opens a location in a terminal editor
+// Its standard streams are connected to fif's, so the caller must release
+// the terminal (e.g. suspend the TUI) while it runs.
+func Command(editor Editor, loc Location) (*exec.Cmd, error) {
+	def, err := Resolve(editor)
+	if err != nil {
+		return nil, err
+	}
+	args := def.expand(loc)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd, nil
 }
 
-// OpenFile opens a file in the specified editor at the given line and column
-func OpenFile(editor Editor, file string, line, column int) error {
+// OpenFile opens a file in the specified editor at a location
+// GUI editors are started in the background. Terminal editors run in the
+// current terminal and OpenFile waits for them to exit.
+func OpenFile(editor Editor, loc Location) error {
+	def, err := Resolve(editor)
+	if err != nil {
+		return err
+	}
+
+	if def.Terminal {
+		cmd, err := Command(editor, loc)
+		if err != nil {
+			return err
+		}
+		return cmd.Run()
+	}
+
+	if def.vscode {
+		return openVSCode(def, loc)
+	}
+
+	args := def.expand(loc)
+	return startDetached(exec.Command(args[0], args[1:]...))
+}
+
+// Location is a position in a file
+type Location struct {
+	File       string
+	Line       int
+	Column     int // 1-based, in characters
+	ByteColumn int // 1-based, in bytes; 0 means the same as Column
+}
+
+// OpenFiles opens several locations in a GUI editor
+// Cursor and VS Code get a single command reusing the window, with one
+// file:line:column argument per location. Other GUI editors are started
+// once per location. Terminal editors can only show one file at a time,
+// so callers run Command for each location instead.
+func OpenFiles(editor Editor, locations []Location) error {
+	def, err := Resolve(editor)
+	if err != nil {
+		return err
+	}
+	if def.Terminal {
+		return fmt.Errorf("%s runs in the terminal and opens one file at a time", def.Name)
+	}
+	if len(locations) == 0 {
+		return nil
+	}
+
+	if def.vscode {
+		// The last argument is the file:line:column template, repeated per location
+		last := len(def.Command) - 1
+		args := append([]string{}, def.Command[:last]...)
+		if hasExistingInstance, _ := findExistingInstance(Editor(def.Name)); hasExistingInstance || isRunningInEditor() {
+			args = append([]string{args[0], "--reuse-window"}, args[1:]...)
+		}
+		for _, loc := range locations {
+			tmpl := Definition{Command: def.Command[last:]}
+			args = append(args, tmpl.expand(loc)...)
+		}
+		return startDetached(exec.Command(args[0], args[1:]...))
+	}
+
+	for _, loc := range locations {
+		args := def.expand(loc)
+		if err := startDetached(exec.Command(args[0], args[1:]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openVSCode opens a file in Cursor or VS Code, reusing an existing window
+func openVSCode(def Definition, loc Location) error {
 	// Check if we're running inside editor or if existing instance exists
-	hasExistingInstance, _ := findExistingInstance(editor)
+	hasExistingInstance, _ := findExistingInstance(Editor(def.Name))
 	isInEditor := isRunningInEditor()
 
 	// On macOS, try using URL scheme first if we're in the editor
 	// This is more reliable for opening in existing instance
-	if runtime.GOOS == "darwin" && (hasExistingInstance || isInEditor) && editor == EditorCursor {
+	if runtime.GOOS == "darwin" && (hasExistingInstance || isInEditor) && Editor(def.Name) == EditorCursor {
 		// Try using cursor:// URL scheme
-		absPath, err := filepath.Abs(file)
+		absPath, err := filepath.Abs(loc.File)
 		if err == nil {
-			url := fmt.Sprintf("cursor://file/%s:%d:%d", absPath, line, column)
+			url := fmt.Sprintf("cursor://file/%s:%d:%d", absPath, loc.Line, loc.Column)
 			cmd := exec.Command("open", "-u", url)
 			cmd.Stdout = nil
 			cmd.Stderr = nil
@@ -60,18 +175,17 @@ func OpenFile(editor Editor, file string, line, column int) error {
 	}
 
 	// Fall back to CLI command
-	editorCmd := string(editor)
-	args := []string{
-		"--goto",
-		fmt.Sprintf("%s:%d:%d", file, line, column),
-	}
-
+	args := def.expand(loc)
 	if hasExistingInstance || isInEditor {
 		// Use --reuse-window to prevent new window from opening
-		args = append([]string{"--reuse-window"}, args...)
+		args = append([]string{args[0], "--reuse-window"}, args[1:]...)
 	}
 
-	cmd := exec.Command(editorCmd, args...)
+	return startDetached(exec.Command(args[0], args[1:]...))
+}
+
+// startDetached starts cmd in the background without waiting for it
+func startDetached(cmd *exec.Cmd) error {
 	// Discard output to prevent any interference
 	cmd.Stdout = nil
 	cmd.Stderr = nil
@@ -0,0 +1,141 @@
+package editor
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Definition describes how to open a file at a position in an editor
+type Definition struct {
+	Name string
+	// Command is the program followed by its arguments
+	// The placeholders {file}, {line} and {col} (1-based) are substituted
+	// in every element. {col} counts characters; {bytecol} is the same
+	// column in bytes, for editors like Vim that count bytes.
+	Command []string
+	// Terminal editors run in fif's terminal: the TUI is suspended until they exit.
+	// Other editors are spawned in the background and forgotten.
+	Terminal bool
+
+	// vscode enables the VS Code / Cursor specific window reuse
+	vscode bool
+}
+
+// builtinEditors are the editors fif knows without configuration
+var builtinEditors = []Definition{
+	{Name: "cursor", Command: []string{"cursor", "--goto", "{file}:{line}:{col}"}, vscode: true},
+	{Name: "code", Command: []string{"code", "--goto", "{file}:{line}:{col}"}, vscode: true},
+	{Name: "zed", Command: []string{"zed", "{file}:{line}:{col}"}},
+	{Name: "subl", Command: []string{"subl", "{file}:{line}:{col}"}},
+	{Name: "idea", Command: []string{"idea", "--line", "{line}", "--column", "{col}", "{file}"}},
+	{Name: "goland", Command: []string{"goland", "--line", "{line}", "--column", "{col}", "{file}"}},
+	{Name: "pycharm", Command: []string{"pycharm", "--line", "{line}", "--column", "{col}", "{file}"}},
+	{Name: "webstorm", Command: []string{"webstorm", "--line", "{line}", "--column", "{col}", "{file}"}},
+	{Name: "emacsclient", Command: []string{"emacsclient", "--no-wait", "+{line}:{col}", "{file}"}},
+	{Name: "nvim", Command: []string{"nvim", "+call cursor({line},{bytecol})", "{file}"}, Terminal: true},
+	{Name: "vim", Command: []string{"vim", "+call cursor({line},{bytecol})", "{file}"}, Terminal: true},
+	{Name: "vi", Command: []string{"vi", "+{line}", "{file}"}, Terminal: true},
+	{Name: "hx", Command: []string{"hx", "{file}:{line}:{col}"}, Terminal: true},
+	{Name: "helix", Command: []string{"helix", "{file}:{line}:{col}"}, Terminal: true},
+	{Name: "kak", Command: []string{"kak", "+{line}:{col}", "{file}"}, Terminal: true},
+	{Name: "emacs", Command: []string{"emacs", "-nw", "+{line}:{col}", "{file}"}, Terminal: true},
+	{Name: "nano", Command: []string{"nano", "+{line},{col}", "{file}"}, Terminal: true},
+	{Name: "micro", Command: []string{"micro", "{file}:{line}:{col}"}, Terminal: true},
+}
+
+// registry holds the known editor definitions by name
+var registry = make(map[string]Definition)
+
+func init() {
+	for _, def := range builtinEditors {
+		registry[def.Name] = def
+	}
+}
+
+// Register adds a user-defined editor, replacing any definition with the same name
+func Register(def Definition) error {
+	if def.Name == "" {
+		return fmt.Errorf("editor definition without a name")
+	}
+	if len(def.Command) == 0 || def.Command[0] == "" {
+		return fmt.Errorf("editor %q: command is empty", def.Name)
+	}
+	if !strings.Contains(strings.Join(def.Command, " "), "{file}") {
+		return fmt.Errorf("editor %q: command has no {file} placeholder", def.Name)
+	}
+	registry[def.Name] = def
+	return nil
+}
+
+// Names returns the names of all known editors, sorted
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the definition used to open files with ed
+// ed is an editor name, or a command line such as $EDITOR ("nvim",
+// "/usr/bin/vim", "code --wait"). Its program is looked up by base name and
+// extra arguments are kept. A command line whose program is unknown is
+// treated as a terminal editor that understands "+<line> <file>", as most
+// $EDITOR programs do, provided it is installed.
+func Resolve(ed Editor) (Definition, error) {
+	if def, ok := registry[string(ed)]; ok {
+		return def, nil
+	}
+
+	fields := strings.Fields(string(ed))
+	if len(fields) == 0 {
+		return Definition{}, fmt.Errorf("no editor configured")
+	}
+	program, extra := fields[0], fields[1:]
+
+	if def, ok := registry[filepath.Base(program)]; ok {
+		command := append([]string{program}, extra...)
+		def.Command = append(command, def.Command[1:]...)
+		return def, nil
+	}
+
+	// An unknown program that isn't installed is likely a typo
+	if _, err := exec.LookPath(program); err != nil {
+		return Definition{}, fmt.Errorf("unknown editor %q (known editors: %s)", ed, strings.Join(Names(), ", "))
+	}
+	return Definition{
+		Name:     filepath.Base(program),
+		Command:  append(fields, "+{line}", "{file}"),
+		Terminal: true,
+	}, nil
+}
+
+// IsTerminal reports whether ed runs in the terminal
+func IsTerminal(ed Editor) bool {
+	def, err := Resolve(ed)
+	return err == nil && def.Terminal
+}
+
+// expand returns the command line of def with the placeholders of loc substituted
+func (def Definition) expand(loc Location) []string {
+	byteColumn := loc.ByteColumn
+	if byteColumn == 0 {
+		byteColumn = loc.Column
+	}
+	replacer := strings.NewReplacer(
+		"{file}", loc.File,
+		"{line}", strconv.Itoa(loc.Line),
+		"{col}", strconv.Itoa(loc.Column),
+		"{bytecol}", strconv.Itoa(byteColumn),
+	)
+	args := make([]string, len(def.Command))
+	for i, arg := range def.Command {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
@@ -0,0 +1,31 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	// On "héllo", a match at the second l is character 4 but byte 5
+	loc := Location{File: "/src/a.go", Line: 3, Column: 4, ByteColumn: 5}
+	tests := []struct {
+		editor string
+		loc    Location
+		want   []string
+	}{
+		{"code", loc, []string{"code", "--goto", "/src/a.go:3:4"}},
+		{"nvim", loc, []string{"nvim", "+call cursor(3,5)", "/src/a.go"}},
+		{"vim", loc, []string{"vim", "+call cursor(3,5)", "/src/a.go"}},
+		{"vim", Location{File: "/src/a.go", Line: 3, Column: 4}, []string{"vim", "+call cursor(3,4)", "/src/a.go"}},
+		{"idea", loc, []string{"idea", "--line", "3", "--column", "4", "/src/a.go"}},
+	}
+	for _, tt := range tests {
+		def, err := Resolve(Editor(tt.editor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := def.expand(tt.loc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expand(%+v) = %q, want %q", tt.editor, tt.loc, got, tt.want)
+		}
+	}
+}
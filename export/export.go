@@ -0,0 +1,85 @@
+// Package export writes search results in formats other tools read: Vim
+// quickfix lists, Emacs grep-mode buffers, Markdown, CSV and SARIF.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/takaishi/fif/search"
+)
+
+// Format is an export format
+type Format string
+
+const (
+	Quickfix Format = "quickfix" // Vim quickfix list (vim -q, :cfile)
+	Emacs    Format = "emacs"    // Emacs grep-mode buffer
+	Markdown Format = "markdown" // Markdown with links and code snippets
+	CSV      Format = "csv"      // CSV with a header row
+	SARIF    Format = "sarif"    // SARIF 2.1.0 log, for code scanning dashboards
+)
+
+// Formats are all the formats, in the order they are offered
+var Formats = []Format{Quickfix, Emacs, Markdown, CSV, SARIF}
+
+// Parse returns the format with the given name
+func Parse(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("invalid export format %q (%s)", name, strings.Join(names, ", "))
+}
+
+// Extension returns the file name extension of the format
+func (f Format) Extension() string {
+	switch f {
+	case Emacs:
+		return ".grep"
+	case Markdown:
+		return ".md"
+	case CSV:
+		return ".csv"
+	case SARIF:
+		return ".sarif"
+	}
+	return ".txt"
+}
+
+// Options describe the search the results come from
+type Options struct {
+	Dir   string // Paths are written relative to Dir (absolute when empty)
+	Query string // Query that was searched, for titles and descriptions
+}
+
+// path returns the path to write for the result's file
+func (o Options) path(result *search.SearchResult) string {
+	if o.Dir == "" {
+		return result.Path()
+	}
+	return result.RelPath(o.Dir)
+}
+
+// Write writes the results to w in the format
+func Write(w io.Writer, f Format, results []*search.SearchResult, opts Options) error {
+	switch f {
+	case Quickfix:
+		return writeQuickfix(w, results, opts)
+	case Emacs:
+		return writeEmacs(w, results, opts)
+	case Markdown:
+		return writeMarkdown(w, results, opts)
+	case CSV:
+		return writeCSV(w, results, opts)
+	case SARIF:
+		return writeSARIF(w, results, opts)
+	}
+	return fmt.Errorf("invalid export format %q", f)
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takaishi/fif/search"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenResults are results with the awkward cases of every format: a path
+// with ':' and a space, text with a comma, quotes and backticks, and two
+// matches in one file
+func goldenResults() []*search.SearchResult {
+	match := func(text, m string) []search.Submatch {
+		i := strings.Index(text, m)
+		return []search.Submatch{{Start: i, End: i + len(m), Text: m}}
+	}
+	lines := []struct {
+		file, text string
+		line       int
+	}{
+		{"main.go", `	log.Printf("say, \"hi\"")`, 12},
+		{"main.go", "	// say `hi` once", 40},
+		{"a:b/notes 1.md", `Say "hi", then leave`, 3},
+	}
+	var results []*search.SearchResult
+	for _, l := range lines {
+		m := match(strings.ToLower(l.text), "hi")
+		results = append(results, &search.SearchResult{
+			File:    l.file,
+			Root:    "/src/proj",
+			Line:    l.line,
+			Column:  len([]rune(l.text[:m[0].Start])) + 1,
+			Text:    l.text,
+			Matches: m,
+		})
+	}
+	return results
+}
+
+func TestWriteGolden(t *testing.T) {
+	opts := Options{Dir: "/src/proj", Query: `"hi"`}
+	for _, f := range Formats {
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, f, goldenResults(), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", string(f)+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test ./export -update to create it)", err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("%s output differs from %s:\n%s", f, golden, got)
+			}
+		})
+	}
+}
+
+func TestWriteSummaries(t *testing.T) {
+	opts := Options{Dir: "/src/proj", Query: `"hi"`}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Emacs, "\nfif finished with 1 match found\n"},
+		{Markdown, "1 match in 1 file\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, tt.format, goldenResults()[:1], opts); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s output doesn't contain %q:\n%s", tt.format, tt.want, buf.String())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, f := range Formats {
+		if got, err := Parse(string(f)); err != nil || got != f {
+			t.Errorf("Parse(%q) = %q, %v", f, got, err)
+		}
+	}
+	if _, err := Parse("xml"); err == nil {
+		t.Error(`Parse("xml") succeeded, want an error`)
+	}
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/takaishi/fif/search"
+)
+
+// writeMarkdown writes a section per file, with a link and a code snippet
+// per matching line
+// Links use the #L<line> anchors of GitHub and GitLab, so a report
+// committed to the repository (or pasted in an issue) points at the lines.
+func writeMarkdown(w io.Writer, results []*search.SearchResult, opts Options) error {
+	out := bufio.NewWriter(w)
+	files := 0
+	for i, result := range results {
+		if i == 0 || result.Path() != results[i-1].Path() {
+			files++
+		}
+	}
+	fmt.Fprintf(out, "# Search results for %s\n\n", inlineCode(opts.Query))
+	fmt.Fprintf(out, "%s in %s\n", search.Plural(len(results), "match", "matches"), search.Plural(files, "file", "files"))
+
+	for i, result := range results {
+		path := opts.path(result)
+		link := (&url.URL{Path: filepath.ToSlash(path)}).String()
+		if i == 0 || result.Path() != results[i-1].Path() {
+			fmt.Fprintf(out, "\n## [%s](%s)\n", escapeLinkText(path), link)
+		}
+		fmt.Fprintf(out, "\n[Line %d](%s#L%d)\n\n", result.Line, link, result.Line)
+
+		text := result.DisplayText()
+		fence := "```"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		lang := strings.TrimPrefix(filepath.Ext(path), ".")
+		fmt.Fprintf(out, "%s%s\n%s\n%s\n", fence, lang, text, fence)
+	}
+	return out.Flush()
+}
+
+// inlineCode returns s as a Markdown code span, with a fence longer than
+// any run of backticks in it
+func inlineCode(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
+
+// escapeLinkText escapes the characters that would end a link text
+func escapeLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
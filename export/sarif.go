@@ -0,0 +1,148 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/takaishi/fif/search"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	sarifRuleID  = "fif/match"
+	sarifSrcRoot = "%SRCROOT%" // Base the relative artifact URIs are resolved against
+)
+
+// sarifLog and the types below are the SARIF 2.1.0 objects fif fills in
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	ColumnKind         string                      `json:"columnKind"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           *sarifRegion     `json:"region,omitempty"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn,omitempty"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+// writeSARIF writes a SARIF log with one note-level result per matching line
+// Paths relative to opts.Dir are given against the %SRCROOT% base, which
+// code scanning services map to the repository checkout.
+func writeSARIF(w io.Writer, results []*search.SearchResult, opts Options) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "fif",
+			Rules: []sarifRule{{
+				ID:               sarifRuleID,
+				ShortDescription: sarifMessage{Text: "Match of the search " + inlineCode(opts.Query)},
+			}},
+		}},
+		ColumnKind: "unicodeCodePoints", // Columns count characters, as rg's do
+		Results:    make([]sarifResult, 0, len(results)),
+	}
+	if opts.Dir != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLoc{
+			sarifSrcRoot: {URI: fileURI(opts.Dir) + "/"},
+		}
+	}
+
+	for _, result := range results {
+		loc := sarifArtifactLoc{URI: fileURI(result.Path())}
+		if opts.Dir != "" {
+			loc = sarifArtifactLoc{
+				URI:       (&url.URL{Path: filepath.ToSlash(opts.path(result))}).String(),
+				URIBaseID: sarifSrcRoot,
+			}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   "note",
+			Message: sarifMessage{Text: result.DisplayText()},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: loc,
+				Region:           sarifRegionOf(result),
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// sarifRegionOf returns the region of the result's first match
+func sarifRegionOf(result *search.SearchResult) *sarifRegion {
+	if result.Line < 1 {
+		return nil
+	}
+	region := &sarifRegion{StartLine: result.Line}
+	if result.Binary {
+		return region
+	}
+	region.StartColumn = result.Column
+	if len(result.Matches) > 0 && result.Column > 0 {
+		m := result.Matches[0]
+		region.EndColumn = result.Column + utf8.RuneCountInString(result.Text[m.Start:m.End])
+	}
+	region.Snippet = &sarifMessage{Text: result.DisplayText()}
+	return region
+}
+
+// fileURI returns the file URI of an absolute path
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
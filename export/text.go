@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/takaishi/fif/search"
+)
+
+// writeQuickfix writes one "file:line:column: text" entry per matching line,
+// which Vim's default errorformat reads
+func writeQuickfix(w io.Writer, results []*search.SearchResult, opts Options) error {
+	out := bufio.NewWriter(w)
+	for _, result := range results {
+		fmt.Fprintf(out, "%s:%d:%d: %s\n", opts.path(result), result.Line, result.Column, result.DisplayText())
+	}
+	return out.Flush()
+}
+
+// writeEmacs writes a buffer in the format of M-x grep output
+// The first line sets grep-mode and the directory relative paths are
+// resolved in, so Emacs opens the file ready to navigate with RET and
+// next-error.
+func writeEmacs(w io.Writer, results []*search.SearchResult, opts Options) error {
+	out := bufio.NewWriter(w)
+	if opts.Dir != "" {
+		fmt.Fprintf(out, "-*- mode: grep; default-directory: %s -*-\n", strconv.Quote(opts.Dir+"/"))
+	} else {
+		fmt.Fprintln(out, "-*- mode: grep -*-")
+	}
+	fmt.Fprintf(out, "fif search %s\n\n", strconv.Quote(opts.Query))
+	for _, result := range results {
+		fmt.Fprintf(out, "%s:%d:%d:%s\n", opts.path(result), result.Line, result.Column, result.DisplayText())
+	}
+	fmt.Fprintf(out, "\nfif finished with %s found\n", search.Plural(len(results), "match", "matches"))
+	return out.Flush()
+}
+
+// writeCSV writes a header row and one row per matching line
+func writeCSV(w io.Writer, results []*search.SearchResult, opts Options) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"file", "line", "column", "text"}); err != nil {
+		return err
+	}
+	for _, result := range results {
+		row := []string{
+			opts.path(result),
+			strconv.Itoa(result.Line),
+			strconv.Itoa(result.Column),
+			result.DisplayText(),
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
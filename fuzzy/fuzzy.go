@@ -0,0 +1,70 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Score reports whether every rune of pattern appears in s in order
+// (case-insensitively) and how good the match is. Higher is better:
+// consecutive runes and runes at the start of a path segment or word
+// score more, and shorter candidates win ties.
+func Score(pattern, s string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(strings.ToLower(pattern))
+	score := 0
+	pi := 0
+	prevMatched := false
+	prev := rune(0)
+	for i, r := range s {
+		if pi == len(p) {
+			break
+		}
+		if unicode.ToLower(r) != p[pi] {
+			prevMatched = false
+			prev = r
+			continue
+		}
+		score++
+		if prevMatched {
+			score += 4
+		}
+		if i == 0 || prev == '/' || prev == '_' || prev == '-' || prev == '.' || prev == ' ' {
+			score += 3
+		}
+		pi++
+		prevMatched = true
+		prev = r
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+	return score*100 - utf8.RuneCountInString(s), true
+}
+
+// Filter returns the items that match pattern, best match first
+// Items with equal scores keep their original order.
+func Filter(pattern string, items []string) []string {
+	type scored struct {
+		item  string
+		score int
+	}
+	var matches []scored
+	for _, item := range items {
+		if score, ok := Score(pattern, item); ok {
+			matches = append(matches, scored{item: item, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.item
+	}
+	return result
+}
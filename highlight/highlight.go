@@ -0,0 +1,117 @@
+// Package highlight colors source code for the preview pane using chroma's
+// lexers and styles.
+package highlight
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	// None is the theme name that turns highlighting off
+	None = "none"
+
+	// maxLineLength is the length above which a line is left plain
+	// Lexing minified or generated lines is slow and their colors don't help.
+	maxLineLength = 2000
+)
+
+// Style is how a span of code is drawn
+// An empty Color means the terminal's default foreground.
+type Style struct {
+	Color     string // Hex color, e.g. "#f92672"
+	Bold      bool
+	Italic    bool
+	Underline bool
+}
+
+// Span is a styled byte range of a line
+type Span struct {
+	Start int // Byte offset of the span start within the line (inclusive)
+	End   int // Byte offset of the span end within the line (exclusive)
+	Style Style
+}
+
+// ValidateTheme reports whether name is a known theme or None
+func ValidateTheme(name string) error {
+	if name == None {
+		return nil
+	}
+	if _, ok := styles.Registry[strings.ToLower(name)]; !ok {
+		return fmt.Errorf("unknown syntax theme %q (a chroma style such as monokai, dracula or github, or %q)", name, None)
+	}
+	return nil
+}
+
+// Lines returns the spans of each line of a file excerpt
+// The language is picked from the file name, or else from firstLine if it
+// is a shebang (pass "" when the excerpt doesn't start at the top of the
+// file). It returns nil for unknown languages and theme None; lines that are
+// too long get no spans.
+func Lines(filename, firstLine string, lines []string, theme string) [][]Span {
+	if theme == None || len(lines) == 0 {
+		return nil
+	}
+	lexer := lexers.Match(filepath.Base(filename))
+	if lexer == nil && strings.HasPrefix(firstLine, "#!") {
+		lexer = lexers.Analyse(firstLine)
+	}
+	if lexer == nil {
+		return nil
+	}
+	style := styles.Get(theme)
+
+	// Long lines are lexed as empty ones so the others keep their place
+	var text strings.Builder
+	for _, line := range lines {
+		if len(line) <= maxLineLength {
+			text.WriteString(line)
+		}
+		text.WriteByte('\n')
+	}
+	iter, err := chroma.Coalesce(lexer).Tokenise(nil, text.String())
+	if err != nil {
+		return nil
+	}
+
+	plain := style.Get(chroma.Text)
+	spans := make([][]Span, len(lines))
+	line, offset := 0, 0
+	for _, token := range iter.Tokens() {
+		entry := style.Get(token.Type)
+		// A token may span several lines (e.g. a block comment)
+		for i, part := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				line++
+				offset = 0
+			}
+			if line >= len(lines) {
+				break
+			}
+			if part != "" && entry != plain && len(lines[line]) <= maxLineLength {
+				spans[line] = append(spans[line], Span{Start: offset, End: offset + len(part), Style: styleOf(entry)})
+			}
+			offset += len(part)
+		}
+	}
+	return spans
+}
+
+// styleOf converts a chroma style entry, ignoring its background so the
+// preview keeps the terminal's
+func styleOf(entry chroma.StyleEntry) Style {
+	style := Style{
+		Bold:      entry.Bold == chroma.Yes,
+		Italic:    entry.Italic == chroma.Yes,
+		Underline: entry.Underline == chroma.Yes,
+	}
+	if entry.Colour.IsSet() {
+		style.Color = entry.Colour.String()
+	}
+	return style
+}
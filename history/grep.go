@@ -0,0 +1,132 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/takaishi/fif/search"
+)
+
+// Grep searches the files of a revision (branch, tag or commit) with git grep
+// An empty rev searches HEAD. Only Query, the match options, Dir, Roots and
+// the file mask of opts are used; paths are relative to Dir like with rg.
+func Grep(ctx context.Context, rev string, opts search.Options) ([]*search.SearchResult, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := git(ctx, root, "log", "-1", "--no-walk", commitFormat, rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	commit, err := parseCommit(strings.TrimPrefix(strings.TrimSpace(string(out)), "\x00"))
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := opts.Regexp()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	args := []string{"grep", "-n", "--column", "-I", "--null", "--no-color"}
+	pattern := opts.Query
+	if opts.Regex {
+		// the case and word options are part of re
+		if pattern, err = posixPattern(re); err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		args = append(args, "-E")
+	} else {
+		args = append(args, "-F")
+		if opts.IgnoreCase() {
+			args = append(args, "-i")
+		}
+		if opts.WholeWord {
+			args = append(args, "-w")
+		}
+	}
+	args = append(args, "-e", pattern, commit.Hash, "--")
+	args = append(args, pathspecs(opts)...)
+
+	out, err = git(ctx, root, args...)
+	if errors.Is(err, errNoMatch) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*search.SearchResult
+	prefix := commit.Hash + ":"
+	for _, line := range strings.Split(string(out), "\n") {
+		// <hash>:<path>\0<line>\0<column>\0<text>
+		fields := strings.SplitN(line, "\x00", 4)
+		if len(fields) != 4 || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		lineNum, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		byteCol, _ := strconv.Atoi(fields[2])
+
+		rev := *commit
+		rev.Path = strings.TrimPrefix(fields[0], prefix)
+		result := &search.SearchResult{
+			File:     rev.Short() + ":" + rev.Path,
+			Root:     root,
+			Line:     lineNum,
+			Text:     fields[3],
+			Revision: &rev,
+		}
+		result.Matches = findMatches(re, result.Text)
+		if opts.Regex && len(result.Matches) == 0 {
+			// git's pattern only approximates re
+			continue
+		}
+		if len(result.Matches) > 0 {
+			byteCol = result.Matches[0].Start + 1
+		}
+		result.Column = runeColumn(result.Text, byteCol)
+		result.Crop()
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// findMatches returns the byte ranges of the matches of re in text
+func findMatches(re *regexp.Regexp, text string) []search.Submatch {
+	if re == nil {
+		return nil
+	}
+	var matches []search.Submatch
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, search.Submatch{Start: loc[0], End: loc[1], Text: text[loc[0]:loc[1]]})
+	}
+	return matches
+}
+
+// runeColumn converts a 1-based byte column of text into a 1-based character column
+func runeColumn(text string, byteCol int) int {
+	if byteCol < 1 {
+		return 1
+	}
+	if byteCol > len(text)+1 {
+		byteCol = len(text) + 1
+	}
+	return utf8.RuneCountInString(text[:byteCol-1]) + 1
+}
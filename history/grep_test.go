@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/takaishi/fif/search"
+)
+
+func TestGrep(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("a.go", "package a\n\nvar id = 42 // café 7\n")
+	r.write("sub/b.txt", "Total: 7 items\nnone here\n")
+	first := r.commit("first")
+	r.write("a.go", "package a\n")
+	r.commit("second")
+
+	tests := []struct {
+		name  string
+		rev   string
+		opts  search.Options
+		want  []string // file:line:column:text of each result
+		match []string // text of the first match of each result
+	}{
+		{
+			name:  "fixed string",
+			opts:  search.Options{Query: "Total"},
+			want:  []string{"sub/b.txt:1:1:Total: 7 items"},
+			match: []string{"Total"},
+		},
+		{
+			name:  "perl classes",
+			rev:   first,
+			opts:  search.Options{Query: `\d+ \S+`, Regex: true},
+			want:  []string{"a.go:3:10:var id = 42 // café 7", "sub/b.txt:1:8:Total: 7 items"},
+			match: []string{"42 //", "7 items"},
+		},
+		{
+			name:  "character column",
+			rev:   first,
+			opts:  search.Options{Query: `é \d`, Regex: true},
+			want:  []string{"a.go:3:19:var id = 42 // café 7"},
+			match: []string{"é 7"},
+		},
+		{
+			name:  "inline flags and word boundaries",
+			rev:   first,
+			opts:  search.Options{Query: `(?i)\bTOTAL\b`, Regex: true},
+			want:  []string{"sub/b.txt:1:1:Total: 7 items"},
+			match: []string{"Total"},
+		},
+		{
+			// git's pattern drops the word boundaries; Go's regexp has the last word
+			name: "approximated pattern",
+			rev:  first,
+			opts: search.Options{Query: `\bota`, Regex: true},
+		},
+		{
+			name:  "roots",
+			rev:   first,
+			opts:  search.Options{Query: "7", Roots: []string{"sub"}},
+			want:  []string{"sub/b.txt:1:8:Total: 7 items"},
+			match: []string{"7"},
+		},
+		{
+			name: "no match",
+			opts: search.Options{Query: "nothing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = r.root
+			results, err := Grep(context.Background(), tt.rev, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+			for i, result := range results {
+				rev := result.Revision
+				got := fmt.Sprintf("%s:%d:%d:%s", rev.Path, result.Line, result.Column, result.Text)
+				if got != tt.want[i] {
+					t.Errorf("result %d = %q, want %q", i, got, tt.want[i])
+				}
+				if result.File != rev.Short()+":"+rev.Path || result.Root != r.root {
+					t.Errorf("result %d is %q in %q", i, result.File, result.Root)
+				}
+				if rev.Subject == "" || rev.Author != "Test" || rev.Rev != rev.Hash {
+					t.Errorf("result %d revision = %+v", i, rev)
+				}
+				if len(result.Matches) == 0 || result.Matches[0].Text != tt.match[i] {
+					t.Errorf("result %d matches = %+v, want %q first", i, result.Matches, tt.match[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGrepInvalidPattern(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("a.go", "package a\n")
+	r.commit("first")
+	_, err := Grep(context.Background(), "", search.Options{Query: "(", Regex: true, Dir: r.root})
+	if err == nil {
+		t.Fatal("Grep accepted an invalid pattern")
+	}
+}
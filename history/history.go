@@ -0,0 +1,133 @@
+// Package history searches the files of git revisions other than the
+// working tree: a single revision with git grep, or the commits that add or
+// remove a string with git log's pickaxe.
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/takaishi/fif/search"
+)
+
+// maxCommits is the number of commits a pickaxe search looks at, newest first
+const maxCommits = 500
+
+// commitFormat is the git log format of a commit header: NUL-separated
+// hash, author, date and subject, starting with a NUL to tell it apart from
+// diff lines
+const commitFormat = "--format=%x00%H%x00%an%x00%aI%x00%s"
+
+// git runs git in dir and returns its output
+// A non-zero exit status with no output on stderr (e.g. git grep finding
+// nothing) is reported as errNoMatch.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "core.quotePath=false"}, args...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimPrefix(msg, "fatal: "))
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, errNoMatch
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// errNoMatch is returned by git when a search finds nothing
+var errNoMatch = errors.New("no match")
+
+// parseCommit parses a commit header printed with commitFormat
+// (without the leading NUL)
+func parseCommit(header string) (*search.Revision, error) {
+	fields := strings.SplitN(header, "\x00", 4)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected git log output %q", header)
+	}
+	date, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected commit date %q", fields[2])
+	}
+	return &search.Revision{
+		Rev:     fields[0],
+		Hash:    fields[0],
+		Author:  fields[1],
+		Date:    date,
+		Subject: fields[3],
+	}, nil
+}
+
+// pathspecs converts the roots and file mask of opts into git pathspecs
+// Globs without a slash match the file name in any directory, like in ripgrep.
+func pathspecs(opts search.Options) []string {
+	specs := append([]string{}, opts.Roots...)
+	glob := func(magic, pattern string) string {
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		return ":(" + magic + ")" + pattern
+	}
+	for _, pattern := range opts.Includes {
+		specs = append(specs, glob("glob", pattern))
+	}
+	for _, pattern := range opts.Excludes {
+		specs = append(specs, glob("exclude,glob", pattern))
+	}
+	return specs
+}
+
+// Show returns the contents of a history result's file as of its revision
+func Show(ctx context.Context, result *search.SearchResult) ([]byte, error) {
+	if result.Revision == nil {
+		return nil, fmt.Errorf("%s is not a history result", result.File)
+	}
+	return git(ctx, result.Root, "show", result.Revision.Rev+":./"+filepath.ToSlash(result.Revision.Path))
+}
+
+// Extract writes a history result's file as of its revision to a read-only
+// file in a new temporary directory so it can be opened in an editor, and
+// returns its path
+// The file keeps its path within the repository so editors still recognize
+// the language, and files of the same name don't replace each other.
+func Extract(result *search.SearchResult) (string, error) {
+	content, err := Show(context.Background(), result)
+	if err != nil {
+		return "", err
+	}
+	rel := filepath.FromSlash(result.Revision.Path)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is not a path within the repository", result.Revision.Path)
+	}
+	rev := result.Revision.Short()
+	if result.Revision.Removed {
+		rev += "-parent"
+	}
+	dir, err := os.MkdirTemp("", "fif-"+rev+"-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, content, 0o444); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,157 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/takaishi/fif/search"
+)
+
+// Pickaxe lists the lines added or removed by the commits whose diffs change
+// the number of occurrences of the query (git log -S), or in regex mode whose
+// diffs have a line matching it (git log -G)
+// rev limits the history searched, e.g. a branch or a range like main..topic;
+// empty means HEAD. Added lines are reported at the commit, removed lines at
+// its parent, where they still exist.
+func Pickaxe(ctx context.Context, rev string, opts search.Options) ([]*search.SearchResult, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	re, err := opts.Regexp()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	args := []string{"log", commitFormat, "-p", "-U0", "--no-color", "--no-ext-diff", "--relative",
+		"--max-count=" + strconv.Itoa(maxCommits)}
+	if opts.Regex {
+		// git log -G only takes POSIX patterns; parsePatches keeps the
+		// lines re matches
+		pattern, err := posixPattern(re)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+		args = append(args, "-G"+pattern)
+	} else {
+		args = append(args, "-S"+opts.Query)
+		if opts.IgnoreCase() {
+			args = append(args, "--regexp-ignore-case")
+		}
+	}
+	args = append(args, rev, "--")
+	args = append(args, pathspecs(opts)...)
+
+	out, err := git(ctx, root, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePatches(string(out), root, re)
+}
+
+// parsePatches turns the output of git log -p -U0 into results for the
+// changed lines that match re
+func parsePatches(out, root string, re *regexp.Regexp) ([]*search.SearchResult, error) {
+	var (
+		results          []*search.SearchResult
+		commit           *search.Revision
+		oldPath, newPath string
+		oldLine, newLine int
+		inHunk           bool
+	)
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\x00"):
+			c, err := parseCommit(line[1:])
+			if err != nil {
+				return nil, err
+			}
+			commit, inHunk = c, false
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath, inHunk = "", "", false
+		case !inHunk && strings.HasPrefix(line, "--- "):
+			oldPath = patchPath(line[4:], "a/")
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			newPath = patchPath(line[4:], "b/")
+		case strings.HasPrefix(line, "@@ "):
+			oldLine, newLine = parseHunkHeader(line)
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "-"):
+			if commit != nil && oldPath != "" && re.MatchString(line[1:]) {
+				results = append(results, changedLine(root, commit, oldPath, oldLine, line[1:], re, true))
+			}
+			oldLine++
+		case inHunk && strings.HasPrefix(line, "+"):
+			if commit != nil && newPath != "" && re.MatchString(line[1:]) {
+				results = append(results, changedLine(root, commit, newPath, newLine, line[1:], re, false))
+			}
+			newLine++
+		}
+	}
+	return results, nil
+}
+
+// changedLine builds the result for a line a commit added or removed
+func changedLine(root string, commit *search.Revision, path string, lineNum int, text string, re *regexp.Regexp, removed bool) *search.SearchResult {
+	rev := *commit
+	rev.Path = path
+	rev.Removed = removed
+	if removed {
+		rev.Rev = commit.Hash + "^"
+	}
+	name := rev.Short()
+	if removed {
+		name += "^"
+	}
+	result := &search.SearchResult{
+		File:     name + ":" + path,
+		Root:     root,
+		Line:     lineNum,
+		Column:   1,
+		Text:     text,
+		Matches:  findMatches(re, text),
+		Revision: &rev,
+	}
+	if len(result.Matches) > 0 {
+		result.Column = runeColumn(text, result.Matches[0].Start+1)
+	}
+	result.Crop()
+	return result
+}
+
+// patchPath extracts the path from a ---/+++ line of a patch
+// It returns "" for /dev/null (the file didn't exist on that side).
+func patchPath(s, prefix string) string {
+	s = strings.TrimSuffix(s, "\t")
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseHunkHeader returns the first old and new line numbers of a hunk
+// header like "@@ -12,3 +12,4 @@"
+func parseHunkHeader(line string) (oldStart, newStart int) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	start := func(field string) int {
+		field, _, _ = strings.Cut(field[1:], ",")
+		n, _ := strconv.Atoi(field)
+		return n
+	}
+	return start(fields[1]), start(fields[2])
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/takaishi/fif/search"
+)
+
+func TestPickaxe(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("a.go", "package a\n\nconst limit = 10\n")
+	first := r.commit("add limit")
+	r.write("a.go", "package a\n\n// limit of items\nconst limit = 20\n")
+	second := r.commit("raise limit")
+	r.write("b.go", "package b\n")
+	r.commit("unrelated")
+
+	tests := []struct {
+		name string
+		opts search.Options
+		want []string // rev:file:line:column:text of each result
+	}{
+		{
+			name: "regex",
+			opts: search.Options{Query: `limit = \d+`, Regex: true},
+			want: []string{
+				second + "^:a.go:3:7:const limit = 10",
+				second + ":a.go:4:7:const limit = 20",
+				first + ":a.go:3:7:const limit = 10",
+			},
+		},
+		{
+			// -S finds the commits that change the number of occurrences
+			name: "fixed string",
+			opts: search.Options{Query: "limit"},
+			want: []string{
+				second + "^:a.go:3:7:const limit = 10",
+				second + ":a.go:3:4:// limit of items",
+				second + ":a.go:4:7:const limit = 20",
+				first + ":a.go:3:7:const limit = 10",
+			},
+		},
+		{
+			name: "ignore case",
+			opts: search.Options{Query: "(?i)LIMIT OF", Regex: true},
+			want: []string{second + ":a.go:3:4:// limit of items"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = r.root
+			results, err := Pickaxe(context.Background(), "", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range results {
+				rev := result.Revision
+				got = append(got, fmt.Sprintf("%s:%s:%d:%d:%s", rev.Rev, rev.Path, result.Line, result.Column, result.Text))
+				if rev.Removed != (rev.Rev == rev.Hash+"^") {
+					t.Errorf("%s: Removed = %v", result.File, rev.Removed)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("results =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatches(t *testing.T) {
+	out := "\x00" + "1234567890abcdef\x00Test\x002024-05-01T10:00:00+02:00\x00change things\n" +
+		"\n" +
+		"diff --git a/old.go b/old.go\n" +
+		"deleted file mode 100644\n" +
+		"--- a/old.go\n" +
+		"+++ /dev/null\n" +
+		"@@ -1,2 +0,0 @@\n" +
+		"-package old\n" +
+		"-var x = 1\n" +
+		"diff --git \"a/sp ace.go\" \"b/sp ace.go\"\n" +
+		"--- \"a/sp ace.go\"\n" +
+		"+++ \"b/sp ace.go\"\n" +
+		"@@ -10 +10,2 @@ func f() {\n" +
+		"-\tx := 1\n" +
+		"+\tx := 2\n" +
+		"+\ty := 3\n" +
+		"@@ -20,0 +22 @@\n" +
+		"+--- x = 4\n"
+	results, err := parsePatches(out, "/repo", regexp.MustCompile(`x`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"1234567^:old.go:2:5:var x = 1",
+		"1234567^:sp ace.go:10:2:\tx := 1",
+		"1234567:sp ace.go:10:2:\tx := 2",
+		"1234567:sp ace.go:22:5:--- x = 4",
+	}
+	var got []string
+	for _, result := range results {
+		got = append(got, fmt.Sprintf("%s:%d:%d:%s", result.File, result.Line, result.Column, result.Text))
+		if result.Root != "/repo" || result.Revision.Subject != "change things" {
+			t.Errorf("%s: root %q, revision %+v", result.File, result.Root, result.Revision)
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("results =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestParsePatchesBadCommit(t *testing.T) {
+	if _, err := parsePatches("\x00not a commit\n", "/repo", regexp.MustCompile(`x`)); err == nil {
+		t.Error("parsePatches accepted a malformed commit header")
+	}
+}
+
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		line               string
+		oldStart, newStart int
+	}{
+		{"@@ -12,3 +12,4 @@", 12, 12},
+		{"@@ -12,3 +15,4 @@ func main() {", 12, 15},
+		{"@@ -0,0 +1 @@", 0, 1},
+		{"@@ -7 +0,0 @@", 7, 0},
+		{"@@", 0, 0},
+	}
+	for _, tt := range tests {
+		oldLine, newLine := parseHunkHeader(tt.line)
+		if oldLine != tt.oldStart || newLine != tt.newStart {
+			t.Errorf("parseHunkHeader(%q) = %d, %d, want %d, %d", tt.line, oldLine, newLine, tt.oldStart, tt.newStart)
+		}
+	}
+}
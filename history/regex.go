@@ -0,0 +1,246 @@
+package history
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// noMatch is an ERE that can't match a line (an empty bracket expression
+// isn't valid)
+const noMatch = "$.^"
+
+// maxRepeat is the largest repetition count POSIX guarantees (RE_DUP_MAX)
+const maxRepeat = 255
+
+// posixPattern translates re into a POSIX extended regular expression for
+// git grep -E and git log -G, which don't understand the Perl syntax rg
+// accepts (\d, \w, (?i), lazy quantifiers...)
+// The translation may match more lines than re: word boundaries are dropped,
+// lazy quantifiers become greedy and long repetitions are shortened. Callers
+// keep only the lines re itself matches, so git merely preselects them.
+func posixPattern(re *regexp.Regexp) (string, error) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	writeERE(&b, parsed)
+	return b.String(), nil
+}
+
+// writeERE writes the POSIX extended form of re to b
+func writeERE(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		b.WriteString(noMatch)
+	case syntax.OpEmptyMatch, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		b.WriteString("()")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && unicode.SimpleFold(r) != r {
+				writeBracket(b, foldRanges(r), false)
+			} else {
+				writeLiteral(b, r)
+			}
+		}
+	case syntax.OpCharClass:
+		writeClass(b, re.Rune)
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteString(".")
+	case syntax.OpBeginLine, syntax.OpBeginText:
+		b.WriteString("^")
+	case syntax.OpEndLine, syntax.OpEndText:
+		b.WriteString("$")
+	case syntax.OpCapture:
+		b.WriteString("(")
+		writeERE(b, re.Sub[0])
+		b.WriteString(")")
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		writeAtom(b, re.Sub[0])
+		b.WriteString(map[syntax.Op]string{syntax.OpStar: "*", syntax.OpPlus: "+", syntax.OpQuest: "?"}[re.Op])
+	case syntax.OpRepeat:
+		writeAtom(b, re.Sub[0])
+		lo, hi := min(re.Min, maxRepeat), re.Max
+		if hi > maxRepeat || re.Min > maxRepeat {
+			hi = -1
+		}
+		switch {
+		case hi == -1:
+			fmt.Fprintf(b, "{%d,}", lo)
+		case lo == hi:
+			fmt.Fprintf(b, "{%d}", lo)
+		default:
+			fmt.Fprintf(b, "{%d,%d}", lo, hi)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpAlternate {
+				b.WriteString("(")
+				writeERE(b, sub)
+				b.WriteString(")")
+			} else {
+				writeERE(b, sub)
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			if i > 0 {
+				b.WriteString("|")
+			}
+			writeERE(b, sub)
+		}
+	}
+}
+
+// writeAtom writes re so that a following quantifier applies to all of it
+func writeAtom(b *strings.Builder, re *syntax.Regexp) {
+	switch {
+	case re.Op == syntax.OpLiteral && len(re.Rune) == 1,
+		re.Op == syntax.OpCharClass, re.Op == syntax.OpAnyChar,
+		re.Op == syntax.OpAnyCharNotNL, re.Op == syntax.OpCapture:
+		writeERE(b, re)
+	default:
+		b.WriteString("(")
+		writeERE(b, re)
+		b.WriteString(")")
+	}
+}
+
+// writeLiteral writes r, escaping the characters special in an ERE
+func writeLiteral(b *strings.Builder, r rune) {
+	if r == '\n' {
+		// lines don't contain newlines
+		b.WriteString(noMatch)
+		return
+	}
+	if strings.ContainsRune(`\.[]()*+?{}|^$`, r) {
+		b.WriteByte('\\')
+	}
+	b.WriteRune(r)
+}
+
+// writeClass writes a character class given as sorted rune ranges, negated
+// when that's shorter (e.g. [^a-z] rather than every other character)
+func writeClass(b *strings.Builder, ranges []rune) {
+	if len(ranges) >= 2 && ranges[0] == 0 && ranges[len(ranges)-1] == unicode.MaxRune {
+		var gaps []rune
+		for i := 1; i+1 < len(ranges); i += 2 {
+			gaps = append(gaps, ranges[i]+1, ranges[i+1]-1)
+		}
+		if len(gaps) == 0 {
+			b.WriteString(".")
+			return
+		}
+		writeBracket(b, gaps, true)
+		return
+	}
+	writeBracket(b, ranges, false)
+}
+
+// writeBracket writes a bracket expression for rune ranges
+// POSIX has no escapes in brackets: ] must come first and - last, and ^ must
+// not be first.
+func writeBracket(b *strings.Builder, ranges []rune, negate bool) {
+	if !negate && len(ranges) == 2 && ranges[0] == ranges[1] {
+		writeLiteral(b, ranges[0])
+		return
+	}
+	var body strings.Builder
+	var hasBracket, hasCaret, hasDash bool
+	special := func(r rune) bool {
+		switch r {
+		case ']':
+			hasBracket = true
+		case '^':
+			hasCaret = true
+		case '-':
+			hasDash = true
+		default:
+			return false
+		}
+		return true
+	}
+	for _, r := range splitRanges(ranges) {
+		lo, hi := r[0], r[1]
+		for lo <= hi && special(lo) {
+			lo++
+		}
+		for lo <= hi && special(hi) {
+			hi--
+		}
+		switch {
+		case lo > hi:
+		case lo == hi:
+			body.WriteRune(lo)
+		case lo+1 == hi:
+			body.WriteRune(lo)
+			body.WriteRune(hi)
+		default:
+			body.WriteRune(lo)
+			body.WriteByte('-')
+			body.WriteRune(hi)
+		}
+	}
+	if body.Len() == 0 && !hasBracket && !hasCaret && !hasDash {
+		if negate {
+			b.WriteString(".")
+		} else {
+			b.WriteString(noMatch)
+		}
+		return
+	}
+	b.WriteString("[")
+	if negate {
+		b.WriteString("^")
+	}
+	if hasBracket {
+		b.WriteString("]")
+	}
+	b.WriteString(body.String())
+	if hasCaret && !negate && !hasBracket && body.Len() == 0 {
+		// [^-] would be a negation: put the dash first instead
+		b.WriteString("-^]")
+		return
+	}
+	if hasCaret {
+		b.WriteString("^")
+	}
+	if hasDash {
+		b.WriteString("-")
+	}
+	b.WriteString("]")
+}
+
+// splitRanges returns rune ranges as pairs, without NUL and newline: git
+// can't be passed a NUL and takes a newline as separating patterns, and
+// neither is ever part of a line
+func splitRanges(ranges []rune) [][2]rune {
+	var pairs [][2]rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := max(ranges[i], 1), ranges[i+1]
+		if lo <= '\n' && '\n' <= hi {
+			pairs = append(pairs, [2]rune{lo, '\n' - 1})
+			lo = '\n' + 1
+		}
+		if lo <= hi {
+			pairs = append(pairs, [2]rune{lo, hi})
+		}
+	}
+	return pairs
+}
+
+// foldRanges returns the runes equal to r ignoring case, as ranges
+func foldRanges(r rune) []rune {
+	runes := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		runes = append(runes, f)
+	}
+	var ranges []rune
+	for _, f := range runes {
+		ranges = append(ranges, f, f)
+	}
+	return ranges
+}
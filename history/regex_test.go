@@ -0,0 +1,45 @@
+package history
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestPosixPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`foo`, `foo`},
+		{`a.b`, `a.b`},
+		{`\d+`, `[0-9]+`},
+		{`\w`, `[0-9A-Z_a-z]`},
+		{`\S`, "[^\t\f\r ]"},
+		{`[^a-z]`, `[^a-z]`},
+		{`(?i)ab`, `[Aa][Bb]`},
+		{`(?i)k`, "[Kk\u212a]"},
+		{`a.*?b`, `a.*b`},
+		{`\bfoo\b`, `()foo()`},
+		{`x{2,5}y{3}z{1,}`, `x{2,5}y{3}z{1,}`},
+		{`x{300}`, `x{255,}`},
+		{`(ab|cd)+`, `(ab|cd)+`},
+		{`(?:ab)+`, `(ab)+`},
+		{`foo|bar`, `foo|bar`},
+		{`^a$`, `^a$`},
+		{`1\.0\(\)\[\]\*\+\?\{\}\|\^\$\\`, `1\.0\(\)\[\]\*\+\?\{\}\|\^\$\\`},
+		{`[\]^-]`, `[]^-]`},
+		{`[-^]`, `[-^]`},
+		{`[\x00\n]`, noMatch},
+		{`a\nb`, `a` + noMatch + `b`},
+	}
+	for _, tt := range tests {
+		got, err := posixPattern(regexp.MustCompile(tt.pattern))
+		if err != nil {
+			t.Errorf("posixPattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("posixPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package rgtest puts a fake rg on PATH for tests, so searches can run
+// where ripgrep isn't installed and their command lines can be checked
+package rgtest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Fake is an rg installed by Install
+type Fake struct {
+	log string
+}
+
+// Install puts a fake rg on PATH for the rest of the test
+// It reports a match of "foo" at the start of line 1 of every root it is
+// given, or of each of files when it is given none, and with --files lists
+// its roots. Tests are skipped on Windows, where it can't run.
+func Install(t *testing.T, files ...string) *Fake {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rg is a shell script")
+	}
+	f := &Fake{log: filepath.Join(t.TempDir(), "runs")}
+	defaults := make([]string, len(files))
+	for i, file := range files {
+		defaults[i] = "'" + strings.ReplaceAll(file, "'", `'\''`) + "'"
+	}
+	script := `#!/bin/sh
+pwd >> '` + f.log + `'
+list=false
+if [ "$1" = "--files" ]; then list=true; fi
+while [ "$1" != "--" ]; do shift; done
+shift
+if $list; then
+	printf '%s\n' "$@"
+	exit 0
+fi
+shift
+if [ $# -eq 0 ]; then set -- ` + strings.Join(defaults, " ") + `; fi
+for f in "$@"; do
+	printf '{"type":"match","data":{"path":{"text":"%s"},"lines":{"text":"foo\\n"},"line_number":1,"absolute_offset":0,"submatches":[{"match":{"text":"foo"},"start":0,"end":3}]}}\n' "$f"
+done
+`
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "rg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return f
+}
+
+// Dirs returns the directory each run of rg ran in, in order
+func (f *Fake) Dirs(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile(f.log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
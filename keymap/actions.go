@@ -0,0 +1,172 @@
+package keymap
+
+// Action is a named command of the TUI, as used in the [keys] config table
+type Action string
+
+// Navigation
+const (
+	ResultNext    Action = "result.next"    // Select the next result
+	ResultPrev    Action = "result.prev"    // Select the previous result
+	FileNext      Action = "file.next"      // Jump to the first result of the next file
+	FilePrev      Action = "file.prev"      // Jump to the first result of the previous file
+	FileCollapse  Action = "file.collapse"  // Collapse the selected file (grouped view)
+	FileExpand    Action = "file.expand"    // Expand the selected file (grouped view)
+	HitNext       Action = "hit.next"       // Jump to the next hit in the selected file
+	HitPrev       Action = "hit.prev"       // Jump to the previous hit in the selected file
+	Open          Action = "open"           // Open the result (or marked results) in the editor
+	FocusNext     Action = "focus.next"     // Move to the next input
+	PreviewDown   Action = "preview.down"   // Scroll the preview down by half a page
+	PreviewUp     Action = "preview.up"     // Scroll the preview up by half a page
+	ContextExpand Action = "context.expand" // Show more lines around the hit
+	ContextTrim   Action = "context.trim"   // Show fewer lines around the hit
+)
+
+// Search options
+const (
+	ToggleCase    Action = "toggle.case"    // Cycle match case
+	ToggleWord    Action = "toggle.word"    // Toggle whole words
+	ToggleRegex   Action = "toggle.regex"   // Toggle regular expression
+	ToggleMask    Action = "toggle.mask"    // Toggle the file mask
+	ToggleHistory Action = "toggle.history" // Cycle between the working tree, a revision and the pickaxe
+	HistorySearch Action = "history.search" // Search the search history
+)
+
+// Scopes
+const (
+	ScopeProject   Action = "scope.project"   // Search the project
+	ScopeDirectory Action = "scope.directory" // Search the current directory
+	ScopeGit       Action = "scope.git"       // Cycle through the git scopes
+	ScopePick      Action = "scope.pick"      // Pick directories to search
+	ScopeNamed     Action = "scope.named"     // Cycle through the named scopes
+)
+
+// Results
+const (
+	ToggleGroup    Action = "toggle.group"    // Toggle grouping results by file
+	MarkToggle     Action = "mark.toggle"     // Mark or unmark the selected result
+	MarkAll        Action = "mark.all"        // Mark all results, or clear the marks
+	CopyLocations  Action = "copy.locations"  // Copy file:line of the marked (or selected) results
+	ExportQuickfix Action = "export.quickfix" // Write the marked (or selected) results to a quickfix file
+	Export         Action = "export"          // Export the marked (or all) results
+)
+
+// Replace
+const (
+	ToggleReplace Action = "toggle.replace" // Toggle replace mode
+	ReplaceSkip   Action = "replace.skip"   // Skip the selected match
+	ReplaceFile   Action = "replace.file"   // Replace all matches in the selected file
+	ReplaceAll    Action = "replace.all"    // Replace all matches
+)
+
+// Other
+const (
+	Help Action = "help" // Show the key bindings
+	Quit Action = "quit" // Exit
+)
+
+// Info describes an action for the help overlay
+type Info struct {
+	Action      Action
+	Group       string
+	Description string
+}
+
+// Actions are all the actions, in the order the help overlay lists them
+var Actions = []Info{
+	{ResultNext, "Navigation", "Next result"},
+	{ResultPrev, "Navigation", "Previous result"},
+	{FileNext, "Navigation", "Next file"},
+	{FilePrev, "Navigation", "Previous file"},
+	{FileCollapse, "Navigation", "Collapse file (grouped view)"},
+	{FileExpand, "Navigation", "Expand file (grouped view)"},
+	{HitNext, "Navigation", "Next hit in the file"},
+	{HitPrev, "Navigation", "Previous hit in the file"},
+	{Open, "Navigation", "Open in the editor"},
+	{FocusNext, "Navigation", "Next input"},
+	{PreviewDown, "Preview", "Scroll down half a page"},
+	{PreviewUp, "Preview", "Scroll up half a page"},
+	{ContextExpand, "Preview", "More lines around the hit"},
+	{ContextTrim, "Preview", "Fewer lines around the hit"},
+	{ToggleCase, "Search", "Cycle match case"},
+	{ToggleWord, "Search", "Toggle whole words"},
+	{ToggleRegex, "Search", "Toggle regular expression"},
+	{ToggleMask, "Search", "Toggle file mask"},
+	{ToggleHistory, "Search", "Working tree / revision / pickaxe"},
+	{HistorySearch, "Search", "Search history"},
+	{ScopeProject, "Scope", "Project"},
+	{ScopeDirectory, "Scope", "Current directory"},
+	{ScopeGit, "Scope", "Cycle Git scopes"},
+	{ScopePick, "Scope", "Pick directories"},
+	{ScopeNamed, "Scope", "Cycle named scopes"},
+	{ToggleGroup, "Results", "Group by file"},
+	{MarkToggle, "Results", "Mark / unmark"},
+	{MarkAll, "Results", "Mark all / clear marks"},
+	{CopyLocations, "Results", "Copy file:line"},
+	{ExportQuickfix, "Results", "Write quickfix file"},
+	{Export, "Results", "Export results"},
+	{ToggleReplace, "Replace", "Toggle replace mode"},
+	{ReplaceSkip, "Replace", "Skip match"},
+	{ReplaceFile, "Replace", "Replace in file"},
+	{ReplaceAll, "Replace", "Replace all"},
+	{Help, "Other", "Show key bindings"},
+	{Quit, "Other", "Quit"},
+}
+
+// defaultKeys is the default preset
+// Plain printable keys (like ?) only act while the input being edited is
+// empty, so letters are never bound to actions: they are typed.
+var defaultKeys = map[Action][]string{
+	ResultNext:     {"down"},
+	ResultPrev:     {"up"},
+	FileNext:       {"alt+down", "ctrl+down"},
+	FilePrev:       {"alt+up", "ctrl+up"},
+	FileCollapse:   {"left"},
+	FileExpand:     {"right"},
+	HitNext:        {"alt+j"},
+	HitPrev:        {"alt+k"},
+	Open:           {"enter"},
+	FocusNext:      {"tab"},
+	PreviewDown:    {"ctrl+d"},
+	PreviewUp:      {"ctrl+u"},
+	ContextExpand:  {"alt+e"},
+	ContextTrim:    {"alt+t"},
+	ToggleCase:     {"alt+c"},
+	ToggleWord:     {"alt+w"},
+	ToggleRegex:    {"alt+x"},
+	ToggleMask:     {"alt+m"},
+	ToggleHistory:  {"alt+l"},
+	HistorySearch:  {"ctrl+r"},
+	ScopeProject:   {"alt+p"},
+	ScopeDirectory: {"alt+d"},
+	ScopeGit:       {"alt+h"},
+	ScopePick:      {"alt+o"},
+	ScopeNamed:     {"alt+n"},
+	ToggleGroup:    {"alt+g"},
+	MarkToggle:     {"ctrl+@"},
+	MarkAll:        {"ctrl+a"},
+	CopyLocations:  {"alt+y"},
+	ExportQuickfix: {"alt+q"},
+	Export:         {"alt+v"},
+	ToggleReplace:  {"alt+r"},
+	ReplaceSkip:    {"alt+s"},
+	ReplaceFile:    {"alt+f"},
+	ReplaceAll:     {"alt+a"},
+	Help:           {"?", "f1"},
+	Quit:           {"esc", "ctrl+c"},
+}
+
+// presetKeys are the bindings each preset adds to (or changes in) the
+// default preset
+var presetKeys = map[string]map[Action][]string{
+	"default": {},
+	"vim": {
+		ResultNext: {"down", "ctrl+j", "ctrl+n"},
+		ResultPrev: {"up", "ctrl+k", "ctrl+p"},
+	},
+	"emacs": {
+		ResultNext:  {"down", "ctrl+n"},
+		ResultPrev:  {"up", "ctrl+p"},
+		PreviewDown: {"ctrl+v", "ctrl+d"},
+		Quit:        {"esc", "ctrl+c", "ctrl+g"},
+	},
+}
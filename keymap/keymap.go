@@ -0,0 +1,186 @@
+// Package keymap maps keys to the named actions of the TUI
+// A keymap starts from a preset (default, vim or emacs) and applies the
+// overrides of the [keys] config table. Both frontends look up the action
+// of a key here instead of comparing key names themselves.
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keymap binds keys to actions
+type Keymap struct {
+	preset  string
+	actions map[string]Action   // Key -> action
+	keys    map[Action][]string // Action -> keys, in binding order
+}
+
+// Binding is an action with the keys bound to it, for the help overlay
+type Binding struct {
+	Info
+	Keys []string
+}
+
+// Presets returns the names of the presets
+func Presets() []string {
+	names := make([]string, 0, len(presetKeys))
+	for name := range presetKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the default keymap
+func Default() *Keymap {
+	km, _ := New("default", nil)
+	return km
+}
+
+// New returns the keymap of a preset with the overrides applied
+// Overrides map an action name to a comma-separated list of keys; an empty
+// list unbinds the action. A key an override binds is taken from the action
+// the preset bound it to. Quit must keep at least one key.
+func New(preset string, overrides map[string]string) (*Keymap, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	changes, ok := presetKeys[preset]
+	if !ok {
+		return nil, fmt.Errorf("keymap: unknown preset %q (expected one of %s)", preset, strings.Join(Presets(), ", "))
+	}
+
+	keys := make(map[Action][]string, len(defaultKeys))
+	for action, list := range defaultKeys {
+		keys[action] = list
+	}
+	for action, list := range changes {
+		keys[action] = list
+	}
+
+	// Iterate in a stable order so conflicts are reported deterministically
+	overridden := make(map[Action]bool, len(overrides))
+	for _, name := range sortedNames(overrides) {
+		action := lookupAction(name)
+		if action == "" {
+			return nil, fmt.Errorf("keys: unknown action %q", name)
+		}
+		list, err := parseKeys(overrides[name])
+		if err != nil {
+			return nil, fmt.Errorf("keys.%s: %w", name, err)
+		}
+		overridden[action] = true
+		keys[action] = list
+	}
+
+	km := &Keymap{preset: preset, actions: make(map[string]Action), keys: make(map[Action][]string, len(keys))}
+	for _, info := range Actions {
+		if !overridden[info.Action] {
+			continue
+		}
+		for _, key := range keys[info.Action] {
+			if other, ok := km.actions[key]; ok && other != info.Action {
+				return nil, fmt.Errorf("keys: %s is bound to both %s and %s", key, other, info.Action)
+			}
+			km.bind(key, info.Action)
+		}
+	}
+	for _, info := range Actions {
+		if overridden[info.Action] {
+			continue
+		}
+		for _, key := range keys[info.Action] {
+			if _, ok := km.actions[key]; !ok {
+				km.bind(key, info.Action)
+			}
+		}
+	}
+	if len(km.keys[Quit]) == 0 {
+		return nil, fmt.Errorf("keys: %s has no key left, so fif couldn't be exited", Quit)
+	}
+	return km, nil
+}
+
+// bind binds a key to an action, unless it already is
+func (km *Keymap) bind(key string, action Action) {
+	if km.actions[key] == action {
+		return
+	}
+	km.actions[key] = action
+	km.keys[action] = append(km.keys[action], key)
+}
+
+// Preset returns the name of the preset the keymap starts from
+func (km *Keymap) Preset() string {
+	return km.preset
+}
+
+// Action returns the action bound to a key, or "" if there is none
+func (km *Keymap) Action(key string) Action {
+	return km.actions[key]
+}
+
+// Keys returns the keys bound to an action
+func (km *Keymap) Keys(action Action) []string {
+	return km.keys[action]
+}
+
+// Key returns how the first key bound to an action is displayed, or "" if
+// the action is unbound
+func (km *Keymap) Key(action Action) string {
+	keys := km.keys[action]
+	if len(keys) == 0 {
+		return ""
+	}
+	return Display(keys[0])
+}
+
+// Help returns the actions with the keys bound to them, in help order
+// Unbound actions are left out.
+func (km *Keymap) Help() []Binding {
+	bindings := make([]Binding, 0, len(Actions))
+	for _, info := range Actions {
+		if keys := km.keys[info.Action]; len(keys) > 0 {
+			bindings = append(bindings, Binding{Info: info, Keys: keys})
+		}
+	}
+	return bindings
+}
+
+// lookupAction returns the action of a [keys] name, or "" if there is none
+func lookupAction(name string) Action {
+	for _, info := range Actions {
+		if string(info.Action) == name {
+			return info.Action
+		}
+	}
+	return ""
+}
+
+// parseKeys parses a comma-separated list of keys
+func parseKeys(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var keys []string
+	for _, field := range strings.Split(value, ",") {
+		key, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sortedNames returns the keys of a map in sorted order
+func sortedNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,157 @@
+package keymap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		preset string
+		key    string
+		want   Action
+	}{
+		{"", "down", ResultNext},
+		{"default", "ctrl+j", ""},
+		{"default", "esc", Quit},
+		{"vim", "ctrl+j", ResultNext},
+		{"vim", "ctrl+k", ResultPrev},
+		{"vim", "ctrl+v", ""},
+		{"emacs", "ctrl+n", ResultNext},
+		{"emacs", "ctrl+v", PreviewDown},
+		{"emacs", "ctrl+d", PreviewDown},
+		{"emacs", "ctrl+g", Quit},
+		{"emacs", "alt+x", ToggleRegex},
+	}
+	for _, tt := range tests {
+		km, err := New(tt.preset, nil)
+		if err != nil {
+			t.Fatalf("New(%q): %v", tt.preset, err)
+		}
+		if got := km.Action(tt.key); got != tt.want {
+			t.Errorf("%q preset: %s is bound to %q, want %q", tt.preset, tt.key, got, tt.want)
+		}
+	}
+
+	if got := Presets(); !reflect.DeepEqual(got, []string{"default", "emacs", "vim"}) {
+		t.Errorf("Presets() = %q", got)
+	}
+	if _, err := New("helix", nil); err == nil || !strings.Contains(err.Error(), "default, emacs, vim") {
+		t.Errorf("New(helix) error = %v, want one listing the presets", err)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	km, err := New("vim", map[string]string{
+		"toggle.regex":   "alt+e",
+		"context.expand": "alt+=, Ctrl+E",
+		"mark.toggle":    "ctrl+space",
+		"help":           "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want Action
+	}{
+		{"alt+e", ToggleRegex},
+		{"alt+x", ""}, // the preset key of an overridden action is dropped
+		{"alt+=", ContextExpand},
+		{"ctrl+e", ContextExpand},
+		{"alt+t", ContextTrim},
+		{"ctrl+@", MarkToggle},
+		{"?", ""},
+		{"f1", ""},
+		{"ctrl+j", ResultNext}, // the rest of the preset is kept
+	}
+	for _, tt := range tests {
+		if got := km.Action(tt.key); got != tt.want {
+			t.Errorf("%s is bound to %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	if got := km.Keys(ContextExpand); !reflect.DeepEqual(got, []string{"alt+=", "ctrl+e"}) {
+		t.Errorf("Keys(context.expand) = %q", got)
+	}
+	if got := km.Key(Help); got != "" {
+		t.Errorf("Key(help) = %q, want it unbound", got)
+	}
+	for _, b := range km.Help() {
+		if b.Action == Help {
+			t.Error("Help() lists the unbound help action")
+		}
+	}
+}
+
+func TestOverrideTakesPresetKey(t *testing.T) {
+	// esc is quit's in the preset: binding it elsewhere leaves quit Ctrl+C
+	km, err := New("default", map[string]string{"toggle.mask": "esc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := km.Action("esc"); got != ToggleMask {
+		t.Errorf("esc is bound to %q, want toggle.mask", got)
+	}
+	if got := km.Keys(Quit); !reflect.DeepEqual(got, []string{"ctrl+c"}) {
+		t.Errorf("Keys(quit) = %q, want only ctrl+c", got)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      string
+	}{
+		{"unknown action", map[string]string{"result.first": "home"}, `unknown action "result.first"`},
+		{"invalid key", map[string]string{"open": "hyper+x"}, "keys.open: invalid key"},
+		{"ctrl with a symbol", map[string]string{"open": "ctrl+/"}, "Ctrl only combines"},
+		{"conflict", map[string]string{"open": "ctrl+o", "scope.pick": "ctrl+o"}, "ctrl+o is bound to both open and scope.pick"},
+		{"quit unbound", map[string]string{"quit": ""}, "quit has no key left"},
+		{"quit keys taken", map[string]string{"toggle.mask": "esc", "help": "ctrl+c"}, "quit has no key left"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New("default", tt.overrides)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// Quit may move to other keys
+	km, err := New("default", map[string]string{"quit": "ctrl+q"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.Action("esc") != "" || km.Action("ctrl+q") != Quit {
+		t.Errorf("quit is bound to %q", km.Keys(Quit))
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Alt+X", "alt+x"},
+		{"alt+X", "alt+x"},
+		{"ctrl+R", "ctrl+r"},
+		{"ctrl+space", "ctrl+@"},
+		{"space", " "},
+		{"Shift+Tab", "shift+tab"},
+		{" PgDown ", "pgdown"},
+		{"?", "?"},
+		{"alt+ctrl+up", "alt+ctrl+up"},
+	}
+	for _, tt := range tests {
+		if got, err := ParseKey(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseKey(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "shift+a", "ctrl+1", "meta+x", "hello"} {
+		if got, err := ParseKey(in); err == nil {
+			t.Errorf("ParseKey(%q) = %q, want an error", in, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package keymap
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// namedKeys are the keys with a name, as Bubble Tea names them
+var namedKeys = map[string]bool{
+	"up": true, "down": true, "left": true, "right": true,
+	"home": true, "end": true, "pgup": true, "pgdown": true,
+	"enter": true, "tab": true, "esc": true, "backspace": true, "delete": true,
+	"f1": true, "f2": true, "f3": true, "f4": true, "f5": true, "f6": true,
+	"f7": true, "f8": true, "f9": true, "f10": true, "f11": true, "f12": true,
+}
+
+// ParseKey returns the canonical name of a key such as "alt+x", "ctrl+r",
+// "up" or "?"
+// Alt can be combined with any key, Ctrl with letters and named keys, and
+// Shift with named keys. Ctrl+Space is ctrl+@, the way terminals send it.
+func ParseKey(s string) (string, error) {
+	name := strings.TrimSpace(s)
+	alt, ctrl, shift := false, false, false
+	for {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "alt+") && len(name) > 4:
+			alt, name = true, name[4:]
+		case strings.HasPrefix(lower, "ctrl+") && len(name) > 5:
+			ctrl, name = true, name[5:]
+		case strings.HasPrefix(lower, "shift+") && len(name) > 6:
+			shift, name = true, name[6:]
+		default:
+			goto base
+		}
+	}
+
+base:
+	switch lower := strings.ToLower(name); {
+	case lower == "space":
+		name = " "
+		if ctrl {
+			ctrl, name = false, "ctrl+@"
+		}
+	case namedKeys[lower]:
+		name = lower
+		if shift {
+			name = "shift+" + name
+		}
+		if ctrl {
+			name = "ctrl+" + name
+		}
+	case utf8.RuneCountInString(name) == 1 && !shift:
+		r, _ := utf8.DecodeRuneInString(name)
+		if ctrl {
+			if !unicode.IsLetter(r) && r != '@' {
+				return "", fmt.Errorf("invalid key %q (Ctrl only combines with letters and named keys)", s)
+			}
+			name = "ctrl+" + string(unicode.ToLower(r))
+		} else if alt {
+			name = string(unicode.ToLower(r))
+		}
+	default:
+		return "", fmt.Errorf("invalid key %q", s)
+	}
+	if alt {
+		name = "alt+" + name
+	}
+	return name, nil
+}
+
+// Printable reports whether a key types a character (it has no modifier)
+func Printable(key string) bool {
+	return utf8.RuneCountInString(key) == 1
+}
+
+// displayNames are how named keys are shown in the help overlay
+var displayNames = map[string]string{
+	"up": "↑", "down": "↓", "left": "←", "right": "→",
+	"pgup": "PgUp", "pgdown": "PgDn", "esc": "Esc", "ctrl+@": "Ctrl+Space", " ": "Space",
+}
+
+// Display returns a key the way it is written in the help, e.g. "Alt+P"
+func Display(key string) string {
+	if name, ok := displayNames[key]; ok {
+		return name
+	}
+	if Printable(key) {
+		return key
+	}
+	parts := strings.Split(key, "+")
+	for i, part := range parts {
+		if name, ok := displayNames[part]; ok {
+			parts[i] = name
+		} else if part != "" {
+			r, size := utf8.DecodeRuneInString(part)
+			parts[i] = string(unicode.ToUpper(r)) + part[size:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// optionKeys maps the characters macOS terminals send for Option+<key>
+// to the key itself
+//
+// IMPORTANT: On macOS, when Option+P is pressed, the terminal sends
+// the π character (U+03C0) as a regular rune WITHOUT the Alt modifier flag.
+// This is macOS's standard behavior - Option key acts as a character modifier,
+// not as a Meta key. We must intercept these characters before they reach
+// the text input handler.
+//
+// Keys whose Option character is a dead key (E, I, N, U) are missing.
+var optionKeys = map[rune]rune{
+	'å': 'a', // Option+A
+	'∫': 'b', // Option+B
+	'ç': 'c', // Option+C
+	'∂': 'd', // Option+D
+	'ƒ': 'f', // Option+F
+	'©': 'g', // Option+G
+	'˙': 'h', // Option+H
+	'∆': 'j', // Option+J
+	'˚': 'k', // Option+K
+	'¬': 'l', // Option+L
+	'µ': 'm', // Option+M
+	'ø': 'o', // Option+O
+	'π': 'p', // Option+P
+	'œ': 'q', // Option+Q
+	'®': 'r', // Option+R
+	'ß': 's', // Option+S
+	'†': 't', // Option+T
+	'√': 'v', // Option+V
+	'∑': 'w', // Option+W
+	'≈': 'x', // Option+X
+	'¥': 'y', // Option+Y
+	'Ω': 'z', // Option+Z
+}
+
+// OptionKey returns the Alt key a macOS Option character stands for, e.g.
+// "alt+p" for π
+func OptionKey(r rune) (string, bool) {
+	key, ok := optionKeys[r]
+	if !ok {
+		return "", false
+	}
+	return "alt+" + string(key), true
+}
@@ -1,23 +1,42 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/takaishi/fif/cli"
 	"github.com/takaishi/fif/config"
 	"github.com/takaishi/fif/tui"
 )
 
 func main() {
+	// "fif config --show" doesn't need ripgrep
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(cli.RunConfig(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// "fif search <query>" and "fif --print <query>" print results without the TUI
+	headless := len(os.Args) > 1 && (os.Args[1] == "search" || os.Args[1] == "--print")
+
 	// Check if ripgrep is installed
 	if _, err := exec.LookPath("rg"); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: ripgrep (rg) is not installed or not in PATH\n")
 		fmt.Fprintf(os.Stderr, "Please install ripgrep: https://github.com/BurntSushi/ripgrep\n")
+		if headless {
+			os.Exit(cli.ExitError)
+		}
 		os.Exit(1)
 	}
 
+	if headless {
+		os.Exit(cli.RunSearch(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Parse flags and configuration
 	cfg, err := config.ParseFlags()
 	if err != nil {
@@ -27,10 +46,56 @@ func main() {
 
 	// Create and start TUI with Bubble Tea
 	model := tui.New()
-	model.SetEditor(cfg.Editor)
-	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseAllMotion())
+	if err := model.ApplyConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseAllMotion()}
+	if cfg.Pick {
+		pickOptions, err := pickTerminal(cfg.PickFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		options = append(options, pickOptions...)
+	}
+	p := tea.NewProgram(model, options...)
 	if _, err := p.Run(); err != nil {
+		if cfg.Pick && errors.Is(err, tea.ErrInterrupted) {
+			os.Exit(cli.ExitCancelled)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	// "fif --pick" prints the chosen results for the caller
+	if cfg.Pick {
+		picked := model.Picked()
+		if picked == nil {
+			os.Exit(cli.ExitCancelled)
+		}
+		currentDir, err := os.Getwd()
+		if err == nil {
+			err = cli.WritePicked(picked, cfg.PickFormat, currentDir, os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// pickTerminal returns the options that draw the TUI on the terminal
+// (/dev/tty) rather than stdout, which is kept for the picked results
+func pickTerminal(format string) ([]tea.ProgramOption, error) {
+	if err := cli.CheckFormat(format); err != nil {
+		return nil, err
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("pick mode needs a terminal: %w", err)
+	}
+	// Colors are detected on the terminal too, not on the redirected stdout
+	lipgloss.DefaultRenderer().SetOutput(termenv.NewOutput(tty))
+	return []tea.ProgramOption{tea.WithOutput(tty), tea.WithInputTTY()}, nil
 }
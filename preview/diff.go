@@ -0,0 +1,30 @@
+package preview
+
+// LoadReplacePreview loads a preview for the given file and line number
+// showing the hit line replaced by newLine as a diff
+func LoadReplacePreview(file string, lineNum, before, after int, newLine string) (*Preview, error) {
+	p, err := LoadPreviewContext(file, lineNum, before, after)
+	if err != nil {
+		return nil, err
+	}
+	p.Diff = BuildDiff(p, newLine)
+	return p, nil
+}
+
+// BuildDiff returns the preview lines with the hit line shown as removed
+// and newLine shown as added in its place
+func BuildDiff(p *Preview, newLine string) []DiffLine {
+	diff := make([]DiffLine, 0, len(p.Lines)+1)
+	for i, line := range p.Lines {
+		lineNum := p.StartLine + i
+		if i+1 != p.HitLine {
+			diff = append(diff, DiffLine{Kind: DiffContext, Number: lineNum, Text: line})
+			continue
+		}
+		diff = append(diff,
+			DiffLine{Kind: DiffRemoved, Number: lineNum, Text: line},
+			DiffLine{Kind: DiffAdded, Number: lineNum, Text: newLine},
+		)
+	}
+	return diff
+}
@@ -0,0 +1,178 @@
+package preview
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// indexCacheSize is the number of files whose line index is kept
+	indexCacheSize = 32
+	// indexChunkSize is how much of a file is scanned for line starts at once
+	indexChunkSize = 256 * 1024
+	// binarySniffSize is how much of the start of a file is looked at for
+	// a NUL byte, which makes it binary (as git and ripgrep do)
+	binarySniffSize = 8000
+	// maxLineLength is the length in bytes lines are clipped to: more never
+	// fits in the preview pane
+	maxLineLength = 4096
+)
+
+// lineIndex holds the byte offsets of the line starts of a file, as far as
+// the file has been scanned
+// It is only extended as far as a preview needs, so showing a hit near the
+// top of a huge file doesn't read the rest of it.
+type lineIndex struct {
+	mu       sync.Mutex
+	size     int64     // Size of the file when it was indexed
+	modTime  time.Time // Modification time of the file when it was indexed
+	starts   []int64   // starts[i] is the offset of line i+1
+	scanned  int64     // Number of bytes scanned for line starts
+	complete bool      // Whether the whole file has been scanned
+	binary   bool      // Whether the file looks binary (known once scanning started)
+}
+
+// newLineIndex returns an empty index for a file with the given stat
+func newLineIndex(info os.FileInfo) *lineIndex {
+	return &lineIndex{size: info.Size(), modTime: info.ModTime(), starts: []int64{0}}
+}
+
+// matches reports whether the index was built for the file as it is now
+func (ix *lineIndex) matches(info os.FileInfo) bool {
+	return ix.size == info.Size() && ix.modTime.Equal(info.ModTime())
+}
+
+// extend scans f until the end of line n is known or the file ends
+func (ix *lineIndex) extend(ctx context.Context, f io.ReaderAt, n int) error {
+	buf := make([]byte, indexChunkSize)
+	for !ix.complete && len(ix.starts) <= n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		read, err := f.ReadAt(buf, ix.scanned)
+		chunk := buf[:read]
+		if ix.scanned == 0 && bytes.IndexByte(chunk[:min(read, binarySniffSize)], 0) >= 0 {
+			ix.binary = true
+			ix.complete = true
+			return nil
+		}
+		for i := 0; ; {
+			j := bytes.IndexByte(chunk[i:], '\n')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+			ix.starts = append(ix.starts, ix.scanned+int64(i))
+		}
+		ix.scanned += int64(read)
+		if errors.Is(err, io.EOF) || ix.scanned >= ix.size {
+			ix.complete = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines returns the number of lines whose end is known
+// Once the index is complete it is the number of lines in the file.
+func (ix *lineIndex) lines() int {
+	n := len(ix.starts) - 1
+	if ix.complete && ix.starts[n] < ix.scanned {
+		n++ // Last line without a trailing newline
+	}
+	return n
+}
+
+// read returns lines first to last (1-based, inclusive) of f
+// Both must be within lines(). Only the part of a line clipLine keeps is
+// read, so a minified file doesn't get loaded whole for a few lines.
+func (ix *lineIndex) read(f io.ReaderAt, first, last int) ([]string, error) {
+	if first > last {
+		return nil, nil
+	}
+	// One byte more than is kept tells clipLine whether the line goes on
+	buf := make([]byte, maxLineLength+1)
+	lines := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		start, end := ix.bounds(n)
+		read, err := f.ReadAt(buf[:min(end-start, int64(len(buf)))], start)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		lines = append(lines, clipLine(bytes.TrimSuffix(buf[:read], []byte("\r"))))
+	}
+	return lines, nil
+}
+
+// bounds returns the byte offsets of the start and end of line n, without
+// its newline
+func (ix *lineIndex) bounds(n int) (start, end int64) {
+	start = ix.starts[n-1]
+	if n < len(ix.starts) {
+		return start, ix.starts[n] - 1
+	}
+	return start, ix.scanned
+}
+
+// clipLine converts a line to a string, keeping at most maxLineLength bytes
+// of it without splitting a UTF-8 sequence
+func clipLine(line []byte) string {
+	if len(line) <= maxLineLength {
+		return string(line)
+	}
+	end := maxLineLength
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return string(line[:end])
+}
+
+// indexCache keeps the line indexes of the most recently previewed files
+type indexCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // Values are *cacheEntry
+	order   *list.List               // Most recently used first
+}
+
+// cacheEntry is an element of the indexCache order list
+type cacheEntry struct {
+	path  string
+	index *lineIndex
+}
+
+// indexes is the process-wide line index cache
+var indexes = &indexCache{entries: make(map[string]*list.Element), order: list.New()}
+
+// get returns the index of the file at path, creating a new one if there is
+// none or the file changed since it was indexed
+func (c *indexCache) get(path string, info os.FileInfo) *lineIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[path]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if entry.index.matches(info) {
+			c.order.MoveToFront(elem)
+			return entry.index
+		}
+		c.order.Remove(elem)
+		delete(c.entries, path)
+	}
+
+	entry := &cacheEntry{path: path, index: newLineIndex(info)}
+	c.entries[path] = c.order.PushFront(entry)
+	if c.order.Len() > indexCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).path)
+	}
+	return entry.index
+}
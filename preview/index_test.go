@@ -0,0 +1,156 @@
+package preview
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// openIndexed writes content to a file and returns it with an empty index
+func openIndexed(t *testing.T, content string) (*os.File, *lineIndex) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, newLineIndex(info)
+}
+
+func TestLineIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"trailing newline", "a\nbb\nccc\n", []string{"a", "bb", "ccc"}},
+		{"no trailing newline", "a\nbb\nccc", []string{"a", "bb", "ccc"}},
+		{"CRLF", "a\r\nbb\r\nccc\r\n", []string{"a", "bb", "ccc"}},
+		{"CRLF without trailing newline", "a\r\nbb\r\nccc", []string{"a", "bb", "ccc"}},
+		{"empty lines", "\n\nx\n", []string{"", "", "x"}},
+		{"empty file", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ix := openIndexed(t, tt.content)
+			if err := ix.extend(context.Background(), f, 100); err != nil {
+				t.Fatal(err)
+			}
+			if !ix.complete || ix.binary {
+				t.Fatalf("complete = %v, binary = %v, want true, false", ix.complete, ix.binary)
+			}
+			if got := ix.lines(); got != len(tt.want) {
+				t.Fatalf("lines() = %d, want %d", got, len(tt.want))
+			}
+			got, err := ix.read(f, 1, ix.lines())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read() = %q, want %q", got, tt.want)
+			}
+			if len(tt.want) >= 2 {
+				if got, _ := ix.read(f, 2, 2); !reflect.DeepEqual(got, tt.want[1:2]) {
+					t.Errorf("read(2, 2) = %q, want %q", got, tt.want[1:2])
+				}
+			}
+		})
+	}
+}
+
+func TestLineIndexBinary(t *testing.T) {
+	f, ix := openIndexed(t, "ab\ncd\x00ef\ngh\n")
+	if err := ix.extend(context.Background(), f, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.binary || !ix.complete {
+		t.Errorf("binary = %v, complete = %v, want true, true", ix.binary, ix.complete)
+	}
+}
+
+func TestLineIndexExtendsOnDemand(t *testing.T) {
+	// Enough lines for several chunks
+	var b strings.Builder
+	for b.Len() < 3*indexChunkSize {
+		b.WriteString("line " + strconv.Itoa(b.Len()) + "\n")
+	}
+	f, ix := openIndexed(t, b.String())
+
+	if err := ix.extend(context.Background(), f, 5); err != nil {
+		t.Fatal(err)
+	}
+	if ix.complete || ix.scanned != indexChunkSize {
+		t.Fatalf("after extend(5): complete = %v, scanned = %d, want false, %d", ix.complete, ix.scanned, indexChunkSize)
+	}
+
+	last := strings.Count(b.String(), "\n")
+	if err := ix.extend(context.Background(), f, last+10); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.complete || ix.lines() != last {
+		t.Fatalf("after extend(%d): complete = %v, lines() = %d", last+10, ix.complete, ix.lines())
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	got, err := ix.read(f, last-1, last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, lines[last-2:]) {
+		t.Errorf("read() = %q, want %q", got, lines[last-2:])
+	}
+}
+
+func TestLineIndexCancelled(t *testing.T) {
+	f, ix := openIndexed(t, "a\nb\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ix.extend(ctx, f, 1); err != context.Canceled {
+		t.Errorf("extend() = %v, want context.Canceled", err)
+	}
+}
+
+// countingReader counts the bytes read from a file
+type countingReader struct {
+	f    *os.File
+	read int
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.f.ReadAt(p, off)
+	r.read += n
+	return n, err
+}
+
+func TestLineIndexClipsLongLines(t *testing.T) {
+	long := strings.Repeat("x", maxLineLength-1) + "é" + strings.Repeat("y", 1<<20)
+	exact := strings.Repeat("z", maxLineLength)
+	f, ix := openIndexed(t, "short\n"+long+"\n"+exact+"\r\n")
+	if err := ix.extend(context.Background(), f, 10); err != nil {
+		t.Fatal(err)
+	}
+	r := &countingReader{f: f}
+	got, err := ix.read(r, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// é would be split at the limit, so the line stops before it
+	want := []string{"short", long[:maxLineLength-1], exact}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read() = %q, want %q", got, want)
+	}
+	if limit := len("short") + 2*(maxLineLength+1); r.read > limit {
+		t.Errorf("read %d bytes of the file, want at most %d", r.read, limit)
+	}
+}
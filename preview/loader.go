@@ -2,25 +2,82 @@ package preview
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 )
 
 const (
-	previewBefore = 5
-	previewAfter  = 10
+	// DefaultBefore is the number of lines shown before the hit line
+	DefaultBefore = 5
+	// DefaultAfter is the number of lines shown after the hit line
+	DefaultAfter = 10
 )
 
 // LoadPreview loads a preview for the given file and line number
 func LoadPreview(file string, lineNum int) (*Preview, error) {
+	return LoadPreviewContext(file, lineNum, DefaultBefore, DefaultAfter)
+}
+
+// LoadPreviewContext loads a preview with before and after lines of context around lineNum
+func LoadPreviewContext(file string, lineNum, before, after int) (*Preview, error) {
+	return LoadPreviewRange(context.Background(), file, lineNum, before, after)
+}
+
+// LoadPreviewRange loads a preview with before and after lines of context
+// around lineNum, reading no further into the file than the last of them
+// The line starts found on the way are cached per file (until its size or
+// modification time changes), so previews of later hits in the same file
+// seek directly. Loading stops with ctx's error when ctx is cancelled.
+func LoadPreviewRange(ctx context.Context, file string, lineNum, before, after int) (*Preview, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	ix := indexes.get(file, info)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	endLine := lineNum + after
+	if err := ix.extend(ctx, f, endLine); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if ix.binary {
+		return &Preview{File: file, StartLine: lineNum, HitLine: 1, AtEnd: true, Binary: true}, nil
+	}
+	endLine = min(endLine, ix.lines())
+	startLine := max(lineNum-before, 1)
+
+	lines, err := ix.read(f, startLine, endLine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return &Preview{
+		File:      file,
+		StartLine: startLine,
+		Lines:     lines,
+		HitLine:   lineNum - startLine + 1,
+		AtEnd:     ix.complete && endLine == ix.lines(),
+	}, nil
+}
 
+// LoadPreviewReader loads a preview from the contents of a file read from r
+// name is shown as the preview's file, e.g. a git object name for a file
+// from history.
+func LoadPreviewReader(r io.Reader, name string, lineNum, before, after int) (*Preview, error) {
 	// Use a larger buffer to handle very long lines (default is 64KB)
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	buf := make([]byte, 0, 1024*1024) // 1MB initial capacity
 	scanner.Buffer(buf, 10*1024*1024) // Allow up to 10MB per line
 	allLines := make([]string, 0)
@@ -36,18 +93,18 @@ func LoadPreview(file string, lineNum int) (*Preview, error) {
 	}
 
 	// Calculate preview range
-	startLine := lineNum - previewBefore
+	startLine := lineNum - before
 	if startLine < 1 {
 		startLine = 1
 	}
 
-	endLine := lineNum + previewAfter
+	endLine := lineNum + after
 	if endLine > len(allLines) {
 		endLine = len(allLines)
 	}
 
 	// Extract preview lines (1-based to 0-based conversion)
-	previewLines := make([]string, 0, endLine-startLine+1)
+	previewLines := make([]string, 0, max(endLine-startLine+1, 0))
 	for i := startLine - 1; i < endLine; i++ {
 		if i >= 0 && i < len(allLines) {
 			previewLines = append(previewLines, allLines[i])
@@ -58,9 +115,10 @@ func LoadPreview(file string, lineNum int) (*Preview, error) {
 	hitLineInPreview := lineNum - startLine + 1
 
 	return &Preview{
-		File:      file,
+		File:      name,
 		StartLine: startLine,
 		Lines:     previewLines,
 		HitLine:   hitLineInPreview,
+		AtEnd:     endLine == len(allLines),
 	}, nil
 }
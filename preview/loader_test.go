@@ -0,0 +1,65 @@
+package preview
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchLines is the number of lines of the generated file (about 8 MB)
+const benchLines = 200_000
+
+// writeLargeFile writes a file of benchLines lines of code-like text and
+// returns its path and size
+func writeLargeFile(b *testing.B) (string, int64) {
+	b.Helper()
+	var sb strings.Builder
+	for i := 1; i <= benchLines; i++ {
+		fmt.Fprintf(&sb, "\tresult%d := compute(ctx, input[%d], options) // line %d\n", i, i, i)
+	}
+	path := filepath.Join(b.TempDir(), "large.go")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path, int64(sb.Len())
+}
+
+// resetIndexes empties the line index cache
+func resetIndexes() {
+	indexes = &indexCache{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func BenchmarkLoadPreviewRange(b *testing.B) {
+	path, size := writeLargeFile(b)
+	hit := benchLines - 100 // Near the end, so a cold index scans the whole file
+
+	b.Run("cold", func(b *testing.B) {
+		b.SetBytes(size)
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			resetIndexes()
+			b.StartTimer()
+			if _, err := LoadPreviewRange(context.Background(), path, hit, DefaultBefore, DefaultAfter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		resetIndexes()
+		if _, err := LoadPreviewRange(context.Background(), path, hit, DefaultBefore, DefaultAfter); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadPreviewRange(context.Background(), path, hit, DefaultBefore, DefaultAfter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	resetIndexes()
+}
@@ -1,9 +1,47 @@
 package preview
 
+import "github.com/takaishi/fif/highlight"
+
 // Preview represents a code preview with context lines
 type Preview struct {
 	File      string
 	StartLine int
 	Lines     []string
-	HitLine   int // The line number that matched (1-based, relative to file)
+	HitLine   int  // The line number that matched (1-based, relative to file)
+	AtEnd     bool // Whether Lines reach the end of the file
+	Binary    bool // The file is binary; Lines is empty
+
+	// Syntax holds the syntax highlighting spans of each line
+	// It is nil when the preview is not highlighted.
+	Syntax [][]highlight.Span
+
+	// Diff is the diff-style view of a pending replacement of the hit line
+	// It is nil for a plain preview.
+	Diff []DiffLine
+}
+
+// DiffKind is the kind of a line in a diff preview
+type DiffKind int
+
+const (
+	DiffContext DiffKind = iota // Unchanged line
+	DiffRemoved                 // Line as it is now
+	DiffAdded                   // Line after the replacement
+)
+
+// DiffLine is a single line of a diff preview
+type DiffLine struct {
+	Kind   DiffKind
+	Number int // Line number in the file
+	Text   string
+}
+
+// Highlight sets the syntax highlighting of the preview lines using theme
+// The shebang is only looked at when the preview starts at the top of the file.
+func (p *Preview) Highlight(theme string) {
+	firstLine := ""
+	if p.StartLine == 1 && len(p.Lines) > 0 {
+		firstLine = p.Lines[0]
+	}
+	p.Syntax = highlight.Lines(p.File, firstLine, p.Lines, theme)
 }
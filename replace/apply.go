@@ -0,0 +1,106 @@
+package replace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Edit replaces the content of a single line
+type Edit struct {
+	Line   int    // 1-based line number
+	Offset int64  // Byte offset of the start of the line (or of the replaced part of it) within the file
+	Old    string // Expected current content of the line or part (without line terminator)
+	New    string // New content of the line
+}
+
+// ConflictError reports a file that changed after it was searched
+type ConflictError struct {
+	File   string
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s changed since the search ran: %s", e.File, e.Reason)
+}
+
+// ApplyFile applies edits to a file atomically
+// The file is refused if it was modified after notAfter or if any edited
+// line no longer has the expected content. On success it returns the new
+// modification time of the file.
+func ApplyFile(path string, edits []Edit, notAfter time.Time) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.ModTime().After(notAfter) {
+		return time.Time{}, &ConflictError{File: path, Reason: "modified on disk"}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// Apply edits from the end of the file so earlier offsets stay valid
+	sorted := make([]Edit, len(edits))
+	copy(sorted, edits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset > sorted[j].Offset })
+
+	for i, edit := range sorted {
+		start := edit.Offset
+		end := start + int64(len(edit.Old))
+		if start < 0 || end > int64(len(content)) || string(content[start:end]) != edit.Old {
+			return time.Time{}, &ConflictError{File: path, Reason: fmt.Sprintf("line %d no longer matches", edit.Line)}
+		}
+		if i > 0 && end > sorted[i-1].Offset {
+			return time.Time{}, fmt.Errorf("overlapping edits on line %d", edit.Line)
+		}
+		updated := make([]byte, 0, len(content)+len(edit.New)-len(edit.Old))
+		updated = append(updated, content[:start]...)
+		updated = append(updated, edit.New...)
+		updated = append(updated, content[end:]...)
+		content = updated
+	}
+
+	if err := writeAtomic(path, content, info.Mode().Perm()); err != nil {
+		return time.Time{}, err
+	}
+
+	info, err = os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// writeAtomic writes content to a temporary file next to path and renames it over path
+func writeAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".fif-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op after a successful rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
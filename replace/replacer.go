@@ -0,0 +1,94 @@
+package replace
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/takaishi/fif/search"
+)
+
+// Replacer computes replacement lines for search results
+type Replacer struct {
+	re          *regexp.Regexp
+	replacement string
+	expand      bool // Expand $1 / ${name} capture group references
+}
+
+// NewReplacer creates a Replacer for results of a search run with opts
+// In regex mode the replacement may reference capture groups with $1 or ${name};
+// in literal mode it is inserted as is.
+func NewReplacer(opts search.Options, replacement string) (*Replacer, error) {
+	re, err := opts.Regexp()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for replace: %w", err)
+	}
+
+	return &Replacer{
+		re:          re,
+		replacement: replacement,
+		expand:      opts.Regex,
+	}, nil
+}
+
+// ReplaceLine returns the result's line with every match replaced
+// Matches reported by ripgrep are authoritative; the Go regexp is only used
+// to resolve capture groups for a match at the same position. In regex mode
+// a match the Go regexp doesn't find at the same span is an error, since its
+// capture group references can't be expanded.
+func (r *Replacer) ReplaceLine(result *search.SearchResult) (string, error) {
+	text := result.Text
+
+	// Index the Go regexp matches by their span
+	locs := make(map[[2]int][]int)
+	if r.expand {
+		for _, loc := range r.re.FindAllStringSubmatchIndex(text, -1) {
+			locs[[2]int{loc[0], loc[1]}] = loc
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range result.Matches {
+		if match.Start < last || match.End > len(text) {
+			continue
+		}
+		b.WriteString(text[last:match.Start])
+		if !r.expand {
+			b.WriteString(r.replacement)
+		} else if loc, ok := locs[[2]int{match.Start, match.End}]; ok {
+			b.Write(r.re.ExpandString(nil, r.replacement, text, loc))
+		} else {
+			return "", &MismatchError{Line: result.Line, Match: text[match.Start:match.End]}
+		}
+		last = match.End
+	}
+	b.WriteString(text[last:])
+	return b.String(), nil
+}
+
+// MismatchError reports a match of ripgrep that the Go regexp doesn't find
+// at the same position, e.g. because of a difference in regex syntax
+type MismatchError struct {
+	Line  int
+	Match string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("line %d: can't expand the replacement for %q (the pattern matches differently in Go)", e.Line, e.Match)
+}
+
+// Edit returns the edit that replaces the matches of a result
+// For a cropped long line only its window is rewritten.
+func (r *Replacer) Edit(result *search.SearchResult) (Edit, error) {
+	newLine, err := r.ReplaceLine(result)
+	if err != nil {
+		return Edit{}, err
+	}
+	return Edit{
+		Line:   result.Line,
+		Offset: result.AbsoluteOffset + int64(result.TextOffset),
+		Old:    result.Text,
+		New:    newLine,
+	}, nil
+}
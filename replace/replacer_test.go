@@ -0,0 +1,111 @@
+package replace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/takaishi/fif/search"
+)
+
+// result returns a search result for text with a match at every span
+func result(text string, spans ...[2]int) *search.SearchResult {
+	r := &search.SearchResult{Line: 7, Text: text}
+	for _, span := range spans {
+		r.Matches = append(r.Matches, search.Submatch{Start: span[0], End: span[1], Text: text[span[0]:span[1]]})
+	}
+	return r
+}
+
+func TestReplaceLine(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        search.Options
+		replacement string
+		result      *search.SearchResult
+		want        string
+	}{
+		{
+			name:        "capture groups",
+			opts:        search.Options{Query: `(\w+)=(\d+)`, Regex: true},
+			replacement: "$2=$1",
+			result:      result("a=1, b=2", [2]int{0, 3}, [2]int{5, 8}),
+			want:        "1=a, 2=b",
+		},
+		{
+			name:        "named capture group",
+			opts:        search.Options{Query: `foo(?P<n>\d)`, Regex: true},
+			replacement: "bar${n}",
+			result:      result("x foo1 y", [2]int{2, 6}),
+			want:        "x bar1 y",
+		},
+		{
+			name:        "literal mode inserts the replacement as is",
+			opts:        search.Options{Query: "a.b"},
+			replacement: "$1",
+			result:      result("a.b axb a.b", [2]int{0, 3}, [2]int{8, 11}),
+			want:        "$1 axb $1",
+		},
+		{
+			name:        "no matches",
+			opts:        search.Options{Query: "foo"},
+			replacement: "bar",
+			result:      result("nothing here"),
+			want:        "nothing here",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReplacer(tt.opts, tt.replacement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.ReplaceLine(tt.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ReplaceLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceLineSpanMismatch(t *testing.T) {
+	// ripgrep reported a shorter match than the Go regexp finds
+	r, err := NewReplacer(search.Options{Query: `(a+)`, Regex: true}, "<$1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := result("aaa", [2]int{0, 1})
+
+	got, err := r.ReplaceLine(res)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("ReplaceLine() = %q, %v, want a MismatchError", got, err)
+	}
+	if mismatch.Line != 7 || mismatch.Match != "a" {
+		t.Errorf("MismatchError = %+v", mismatch)
+	}
+	if _, err := r.Edit(res); err == nil {
+		t.Error("Edit() succeeded, want an error")
+	}
+}
+
+func TestEditOfCroppedLine(t *testing.T) {
+	r, err := NewReplacer(search.Options{Query: "foo"}, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := result("x foo", [2]int{2, 5})
+	res.AbsoluteOffset = 100
+	res.TextOffset = 40
+
+	edit, err := r.Edit(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Edit{Line: 7, Offset: 140, Old: "x foo", New: "x bar"}
+	if edit != want {
+		t.Errorf("Edit() = %+v, want %+v", edit, want)
+	}
+}
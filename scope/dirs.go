@@ -0,0 +1,63 @@
+package scope
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"path"
+	"sort"
+)
+
+// maxDirs caps the number of directories offered by the directory picker
+const maxDirs = 20000
+
+// ListDirs returns the directories below base that contain searchable files,
+// relative to base, for the directory picker
+// It uses `rg --files` so ignored and hidden directories are left out like
+// in a search.
+func ListDirs(ctx context.Context, base string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "rg", "--files", "--null")
+	cmd.Dir = base
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(splitNull)
+	for scanner.Scan() && len(seen) < maxDirs {
+		// rg prints slash-separated relative paths
+		for dir := path.Dir(scanner.Text()); dir != "." && dir != "/" && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+		}
+	}
+	// Stop rg early once enough directories have been collected
+	if cmd.Process != nil && len(seen) >= maxDirs {
+		cmd.Process.Kill()
+	}
+	cmd.Wait()
+
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs, ctx.Err()
+}
+
+// splitNull is a bufio.SplitFunc for NUL-terminated entries
+func splitNull(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == 0 {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
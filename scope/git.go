@@ -0,0 +1,158 @@
+package scope
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultCommits is the number of commits covered by the recent-commits scope
+// when the config file doesn't set one
+const DefaultCommits = 5
+
+// ChangedFiles returns the files of the working tree at gitRoot that differ
+// from HEAD: modified, added, renamed and untracked files, relative to gitRoot
+// Deleted files are left out since there is nothing to search in them.
+func ChangedFiles(gitRoot string) ([]string, error) {
+	out, err := runGit(gitRoot, "status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are "XY path\0", renames and copies add "orig-path\0"
+	var files []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // Skip the original path
+		}
+		if status[0] == 'D' || status[1] == 'D' {
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// DiffFiles returns the files of the working tree that differ from ref,
+// relative to gitRoot, leaving out deleted files
+func DiffFiles(gitRoot, ref string) ([]string, error) {
+	out, err := runGit(gitRoot, "diff", "--name-only", "-z", "--diff-filter=d", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	return nullFields(out), nil
+}
+
+// StagedFiles returns the files staged in the index, relative to gitRoot,
+// leaving out deleted files
+// A staged file can still be deleted from the working tree afterwards, so
+// the files are checked to exist.
+func StagedFiles(gitRoot string) ([]string, error) {
+	out, err := runGit(gitRoot, "diff", "--cached", "--name-only", "-z", "--diff-filter=d")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range nullFields(out) {
+		if exists(gitRoot, file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// UntrackedFiles returns the files git doesn't track and doesn't ignore,
+// relative to gitRoot
+func UntrackedFiles(gitRoot string) ([]string, error) {
+	out, err := runGit(gitRoot, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return nullFields(out), nil
+}
+
+// RecentFiles returns the files touched by the last n commits, relative to gitRoot
+// Files that no longer exist in the working tree are left out. A history
+// shorter than n commits is not an error.
+func RecentFiles(gitRoot string, n int) ([]string, error) {
+	out, err := runGit(gitRoot, "log", "-n", strconv.Itoa(n), "--name-only", "-z", "--format=")
+	if err != nil {
+		return nil, err
+	}
+
+	// Commits are separated by newlines before the first file of the next one
+	seen := make(map[string]bool)
+	var files []string
+	for _, file := range nullFields(out) {
+		file = strings.TrimLeft(file, "\n")
+		if file == "" || seen[file] {
+			continue
+		}
+		seen[file] = true
+		if exists(gitRoot, file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// DefaultBase guesses the branch the work in gitRoot is based on
+// It is the remote's default branch (e.g. origin/main) if known, otherwise
+// main or master, whichever exists. It returns "" if there is none.
+func DefaultBase(gitRoot string) string {
+	if out, err := runGit(gitRoot, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		if ref := strings.TrimSpace(string(out)); ref != "" {
+			return ref
+		}
+	}
+	for _, branch := range []string{"main", "master"} {
+		if _, err := runGit(gitRoot, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+			return branch
+		}
+	}
+	return ""
+}
+
+// exists reports whether a file of the working tree exists
+func exists(gitRoot, file string) bool {
+	_, err := os.Stat(filepath.Join(gitRoot, file))
+	return err == nil
+}
+
+// nullFields splits NUL-terminated output into its non-empty entries
+func nullFields(out []byte) []string {
+	var fields []string
+	for _, field := range strings.Split(string(out), "\x00") {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// runGit runs git in gitRoot and returns its output
+// Errors carry git's own message when it printed one.
+func runGit(gitRoot string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = gitRoot
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimPrefix(msg, "fatal: "))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,193 @@
+package scope
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testRepo is a git repository in a temporary directory
+type testRepo struct {
+	t    *testing.T
+	root string
+}
+
+// newTestRepo creates an empty repository, isolated from the user's git config
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	r := &testRepo{t: t, root: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch=main")
+	return r
+}
+
+// git runs git in the repository and fails the test if it fails
+func (r *testRepo) git(args ...string) {
+	r.t.Helper()
+	if _, err := runGit(r.root, args...); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// write creates or overwrites a file of the working tree
+func (r *testRepo) write(path, content string) {
+	r.t.Helper()
+	full := filepath.Join(r.root, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit commits the given files (all changes when none are given)
+func (r *testRepo) commit(message string, paths ...string) {
+	r.t.Helper()
+	if len(paths) == 0 {
+		r.git("add", "--all")
+	} else {
+		r.git(append([]string{"add", "--"}, paths...)...)
+	}
+	r.git("commit", "--quiet", "-m", message)
+}
+
+// assertFiles compares file lists regardless of order
+func assertFiles(t *testing.T, got []string, err error, want ...string) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append([]string(nil), got...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+// baseRepo returns a repository with one commit of a few files
+func baseRepo(t *testing.T) *testRepo {
+	r := newTestRepo(t)
+	r.write(".gitignore", "*.log\n")
+	r.write("keep.go", "package keep\n")
+	r.write("modify.go", "package modify\n")
+	r.write("rename.go", "package rename\n")
+	r.write("delete.go", "package delete\n")
+	r.write("unstage.go", "package unstage\n")
+	r.commit("base")
+	return r
+}
+
+func TestChangedFiles(t *testing.T) {
+	r := baseRepo(t)
+	r.write("modify.go", "package modify // changed\n")
+	if err := os.Mkdir(filepath.Join(r.root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r.git("mv", "rename.go", "dir/renamed.go")
+	r.git("rm", "--quiet", "delete.go")
+	if err := os.Remove(filepath.Join(r.root, "unstage.go")); err != nil {
+		t.Fatal(err)
+	}
+	r.write("new dir/new file.go", "package new\n")
+	r.write("debug.log", "ignored\n")
+
+	files, err := ChangedFiles(r.root)
+	assertFiles(t, files, err, "modify.go", "dir/renamed.go", "new dir/new file.go")
+}
+
+func TestChangedFilesClean(t *testing.T) {
+	r := baseRepo(t)
+	files, err := ChangedFiles(r.root)
+	assertFiles(t, files, err)
+}
+
+func TestStagedFiles(t *testing.T) {
+	r := baseRepo(t)
+	r.write("modify.go", "package modify // staged\n")
+	r.write("keep.go", "package keep // not staged\n")
+	r.write("added.go", "package added\n")
+	r.write("gone.go", "package gone\n")
+	r.write("unstage.go", "package unstage // staged\n")
+	r.git("add", "modify.go", "added.go", "gone.go", "unstage.go")
+	r.git("rm", "--quiet", "delete.go")
+	// Staged, then deleted from the working tree ("AD" and "MD")
+	for _, file := range []string{"gone.go", "unstage.go"} {
+		if err := os.Remove(filepath.Join(r.root, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := StagedFiles(r.root)
+	assertFiles(t, files, err, "added.go", "modify.go")
+}
+
+func TestUntrackedFiles(t *testing.T) {
+	r := baseRepo(t)
+	r.write("untracked.go", "package untracked\n")
+	r.write("sub/deep/file.txt", "text\n")
+	r.write("debug.log", "ignored\n")
+	r.write("modify.go", "package modify // tracked\n")
+
+	files, err := UntrackedFiles(r.root)
+	assertFiles(t, files, err, "sub/deep/file.txt", "untracked.go")
+}
+
+func TestDiffFiles(t *testing.T) {
+	r := baseRepo(t)
+	r.git("checkout", "--quiet", "-b", "feature")
+	r.write("modify.go", "package modify // committed\n")
+	r.write("added.go", "package added\n")
+	r.git("rm", "--quiet", "delete.go")
+	r.commit("feature work")
+	r.write("keep.go", "package keep // uncommitted\n")
+
+	files, err := DiffFiles(r.root, "main")
+	assertFiles(t, files, err, "added.go", "keep.go", "modify.go")
+
+	if _, err := DiffFiles(r.root, "no-such-ref"); err == nil {
+		t.Error("DiffFiles() with an unknown ref succeeded, want an error")
+	}
+}
+
+func TestRecentFiles(t *testing.T) {
+	r := baseRepo(t)
+	r.write("modify.go", "package modify // second\n")
+	r.commit("second", "modify.go")
+	r.write("third.go", "package third\n")
+	r.git("rm", "--quiet", "delete.go")
+	r.commit("third")
+
+	files, err := RecentFiles(r.root, 1)
+	assertFiles(t, files, err, "third.go")
+
+	files, err = RecentFiles(r.root, 2)
+	assertFiles(t, files, err, "modify.go", "third.go")
+
+	// More commits than the history has; deleted files are left out
+	files, err = RecentFiles(r.root, 10)
+	assertFiles(t, files, err, ".gitignore", "keep.go", "modify.go", "rename.go", "third.go", "unstage.go")
+}
+
+func TestDefaultBase(t *testing.T) {
+	r := baseRepo(t)
+	if got := DefaultBase(r.root); got != "main" {
+		t.Errorf("DefaultBase() = %q, want main", got)
+	}
+}
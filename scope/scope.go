@@ -0,0 +1,263 @@
+package scope
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kind identifies what a scope covers
+type Kind int
+
+const (
+	KindProject   Kind = iota // The git repository root
+	KindDirectory             // The current working directory
+	KindDirs                  // Directories picked by the user
+	KindNamed                 // A named scope from the config file
+	KindChanged               // Files changed in the git working tree
+	KindStaged                // Files staged in the git index
+	KindUntracked             // Files git doesn't track
+	KindDiff                  // Files that differ from a git ref
+	KindRecent                // Files touched by the last commits
+)
+
+// Scope describes which files a search covers
+type Scope struct {
+	Kind  Kind
+	Name  string   // Name of a named scope
+	Roots []string // Absolute directories of picked and named scopes
+
+	Ref     string // Base ref of a diff scope
+	Commits int    // Number of commits of a recent-commits scope
+}
+
+// Env is the environment a scope is resolved in
+type Env struct {
+	GitRoot    string // Empty outside a git repository
+	CurrentDir string
+}
+
+// Project returns the scope of the whole git repository
+func Project() Scope {
+	return Scope{Kind: KindProject}
+}
+
+// Directory returns the scope of the current working directory
+func Directory() Scope {
+	return Scope{Kind: KindDirectory}
+}
+
+// Changed returns the scope of the files changed in the git working tree
+func Changed() Scope {
+	return Scope{Kind: KindChanged}
+}
+
+// Staged returns the scope of the files staged in the git index
+func Staged() Scope {
+	return Scope{Kind: KindStaged}
+}
+
+// Untracked returns the scope of the files git doesn't track and doesn't ignore
+func Untracked() Scope {
+	return Scope{Kind: KindUntracked}
+}
+
+// Diff returns the scope of the files that differ from ref, including
+// uncommitted changes
+func Diff(ref string) Scope {
+	return Scope{Kind: KindDiff, Ref: ref}
+}
+
+// Recent returns the scope of the files touched by the last n commits
+func Recent(n int) Scope {
+	return Scope{Kind: KindRecent, Commits: n}
+}
+
+// GitScopes returns the git-backed scopes in the order the TUI cycles
+// through them
+// The diff scope is left out when there is no base ref.
+func GitScopes(base string, commits int) []Scope {
+	scopes := []Scope{Changed(), Staged(), Untracked()}
+	if base != "" {
+		scopes = append(scopes, Diff(base))
+	}
+	return append(scopes, Recent(commits))
+}
+
+// Dirs returns a scope searching the given directories in a single run
+func Dirs(roots ...string) Scope {
+	return Scope{Kind: KindDirs, Roots: roots}
+}
+
+// Named returns a named scope searching roots
+func Named(name string, roots []string) Scope {
+	return Scope{Kind: KindNamed, Name: name, Roots: roots}
+}
+
+// Default returns the initial scope: the project inside a git repository,
+// the current directory otherwise
+func Default(env Env) Scope {
+	if env.GitRoot != "" {
+		return Project()
+	}
+	return Directory()
+}
+
+// IsGit reports whether the scope needs a git repository
+func (s Scope) IsGit() bool {
+	switch s.Kind {
+	case KindProject, KindChanged, KindStaged, KindUntracked, KindDiff, KindRecent:
+		return true
+	}
+	return false
+}
+
+// Label returns the text of the scope's header tab
+func (s Scope) Label() string {
+	switch s.Kind {
+	case KindProject:
+		return "In Project"
+	case KindDirectory:
+		return "In Directory"
+	case KindChanged:
+		return "Changed Files"
+	case KindStaged:
+		return "Staged Files"
+	case KindUntracked:
+		return "Untracked Files"
+	case KindDiff:
+		return "Diff vs " + s.Ref
+	case KindRecent:
+		if s.Commits == 1 {
+			return "Last Commit"
+		}
+		return fmt.Sprintf("Last %d Commits", s.Commits)
+	case KindNamed:
+		return "Scope: " + s.Name
+	case KindDirs:
+		if len(s.Roots) == 0 {
+			return "Directory…"
+		}
+		label := "Dir: " + filepath.Base(s.Roots[0])
+		if len(s.Roots) > 1 {
+			label += fmt.Sprintf(" +%d", len(s.Roots)-1)
+		}
+		return label
+	}
+	return ""
+}
+
+// Equal reports whether two scopes cover the same files
+func (s Scope) Equal(other Scope) bool {
+	if s.Kind != other.Kind || s.Name != other.Name || s.Ref != other.Ref || s.Commits != other.Commits ||
+		len(s.Roots) != len(other.Roots) {
+		return false
+	}
+	for i := range s.Roots {
+		if s.Roots[i] != other.Roots[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve returns the directory ripgrep runs in and the paths it searches
+// Result paths are relative to dir, so dir is the git root whenever all
+// roots are inside the repository, or else their closest common directory.
+// Resolving a git scope other than the project runs git and passes the
+// files it lists to ripgrep explicitly; search.Searcher splits a long list
+// over several rg runs.
+func (s Scope) Resolve(env Env) (dir string, roots []string, err error) {
+	if s.IsGit() && env.GitRoot == "" {
+		return "", nil, fmt.Errorf("not in a git repository")
+	}
+
+	switch s.Kind {
+	case KindProject:
+		return env.GitRoot, nil, nil
+	case KindDirectory:
+		return env.CurrentDir, nil, nil
+	case KindChanged, KindStaged, KindUntracked, KindDiff, KindRecent:
+		files, err := s.gitFiles(env.GitRoot)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(files) == 0 {
+			return "", nil, fmt.Errorf("no files in scope %s", s.Label())
+		}
+		return env.GitRoot, files, nil
+	case KindDirs, KindNamed:
+		if len(s.Roots) == 0 {
+			return "", nil, fmt.Errorf("scope %s has no directories", s.Label())
+		}
+		dir := commonDir(s.Roots)
+		if env.GitRoot != "" && isWithin(env.GitRoot, dir) {
+			dir = env.GitRoot
+		}
+		roots := make([]string, 0, len(s.Roots))
+		for _, root := range s.Roots {
+			rel, err := filepath.Rel(dir, root)
+			if err != nil {
+				return "", nil, err
+			}
+			roots = append(roots, rel)
+		}
+		return dir, roots, nil
+	}
+	return "", nil, fmt.Errorf("unknown scope")
+}
+
+// gitFiles lists the files of a git scope, relative to gitRoot
+func (s Scope) gitFiles(gitRoot string) ([]string, error) {
+	switch s.Kind {
+	case KindStaged:
+		return StagedFiles(gitRoot)
+	case KindUntracked:
+		return UntrackedFiles(gitRoot)
+	case KindDiff:
+		return DiffFiles(gitRoot, s.Ref)
+	case KindRecent:
+		return RecentFiles(gitRoot, s.Commits)
+	}
+	return ChangedFiles(gitRoot)
+}
+
+// commonDir returns the closest directory containing all the absolute paths
+// The paths may be files as well as directories.
+func commonDir(paths []string) string {
+	dir := filepath.Clean(paths[0])
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for _, path := range paths[1:] {
+		for !isWithin(dir, path) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// AbsRoots resolves the roots of a named scope from the config file
+// Relative roots are relative to base (the git root, or the current
+// directory outside a repository).
+func AbsRoots(base string, roots []string) []string {
+	abs := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(base, root)
+		}
+		abs = append(abs, filepath.Clean(root))
+	}
+	return abs
+}
@@ -0,0 +1,121 @@
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings are the configured defaults scope specs refer to
+type Settings struct {
+	Named   map[string][]string // Named scopes: roots relative to the git root (or current directory), by name
+	GitBase string              // Ref of a diff scope without one (empty: the detected default branch)
+	Commits int                 // Commit count of a recent scope without one
+}
+
+// Spec returns the scope in the form Parse reads: project, directory,
+// changed, staged, untracked, diff:REF, recent:N, dirs or the name of a
+// named scope
+// The directories of a dirs scope are not part of the spec: they are
+// passed to Parse as they are in Roots.
+func (s Scope) Spec() string {
+	switch s.Kind {
+	case KindProject:
+		return "project"
+	case KindDirectory:
+		return "directory"
+	case KindChanged:
+		return "changed"
+	case KindStaged:
+		return "staged"
+	case KindUntracked:
+		return "untracked"
+	case KindDiff:
+		return "diff:" + s.Ref
+	case KindRecent:
+		return "recent:" + strconv.Itoa(s.Commits)
+	case KindDirs:
+		return "dirs"
+	case KindNamed:
+		return s.Name
+	}
+	return ""
+}
+
+// Parse returns the scope a spec names (see Spec)
+// An empty spec is the default scope. The diff and recent scopes take their
+// ref and commit count after a colon, e.g. diff:main or recent:3, and
+// default to set's. The dirs scope searches roots, which are relative to
+// the current directory. Any other spec naming an existing directory
+// searches that directory.
+func Parse(spec string, roots []string, env Env, set Settings) (Scope, error) {
+	kind, arg, hasArg := strings.Cut(spec, ":")
+	if hasArg && kind != "diff" && kind != "recent" {
+		kind, hasArg = spec, false // A named scope with a colon in its name
+	}
+
+	switch kind {
+	case "":
+		return Default(env), nil
+	case "project":
+		return Project(), nil
+	case "directory":
+		return Directory(), nil
+	case "changed":
+		return Changed(), nil
+	case "staged":
+		return Staged(), nil
+	case "untracked":
+		return Untracked(), nil
+	case "diff":
+		ref := set.GitBase
+		if hasArg {
+			ref = arg
+		} else if ref == "" && env.GitRoot != "" {
+			ref = DefaultBase(env.GitRoot)
+		}
+		if ref == "" {
+			return Scope{}, fmt.Errorf("no base ref to diff against; use diff:REF or set git.base")
+		}
+		return Diff(ref), nil
+	case "recent":
+		commits := set.Commits
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return Scope{}, fmt.Errorf("invalid commit count %q", arg)
+			}
+			commits = n
+		}
+		if commits < 1 {
+			commits = DefaultCommits
+		}
+		return Recent(commits), nil
+	case "dirs":
+		if len(roots) == 0 {
+			return Scope{}, errors.New("no directories to search in the dirs scope")
+		}
+		return Dirs(AbsRoots(env.CurrentDir, roots)...), nil
+	}
+
+	named, ok := set.Named[spec]
+	if !ok {
+		// Anything else is a directory to search, e.g. --scope ./services
+		dir := spec
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(env.CurrentDir, dir)
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return Dirs(dir), nil
+		}
+		return Scope{}, fmt.Errorf("invalid scope %q (project, directory, changed, staged, untracked, diff[:REF], recent[:N], a named scope or a directory)", spec)
+	}
+	base := env.CurrentDir
+	if env.GitRoot != "" {
+		base = env.GitRoot
+	}
+	return Named(spec, AbsRoots(base, named)), nil
+}
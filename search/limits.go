@@ -0,0 +1,119 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLineLength is the length in bytes above which a result keeps only
+	// a window of its line around the first match (e.g. a hit in a minified
+	// bundle)
+	MaxLineLength = 1000
+	// cropBefore is how much of a cropped line is kept before the first match
+	// Little enough for the match to be visible in a narrow results list.
+	cropBefore = 40
+
+	// BinaryText is shown in place of the line of a match in a binary file
+	BinaryText = "binary file matches"
+)
+
+// Crop replaces the text of a result whose line is longer than
+// MaxLineLength with a window around its first match
+// Matches are moved into the window, or dropped if they fall outside it;
+// Column keeps referring to the whole line.
+func (r *SearchResult) Crop() {
+	if len(r.Text) <= MaxLineLength {
+		return
+	}
+
+	anchor := 0
+	if len(r.Matches) > 0 {
+		anchor = r.Matches[0].Start
+	} else {
+		// Byte offset of the column
+		column := 1
+		for i := range r.Text {
+			if column == r.Column {
+				anchor = i
+				break
+			}
+			column++
+		}
+	}
+	start := runeStart(r.Text, max(anchor-cropBefore, 0))
+	end := runeStart(r.Text, min(start+MaxLineLength, len(r.Text)))
+
+	matches := make([]Submatch, 0, len(r.Matches))
+	for _, m := range r.Matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		m.Start = max(m.Start, start) - start
+		m.End = min(m.End, end) - start
+		m.Text = r.Text[start+m.Start : start+m.End]
+		matches = append(matches, m)
+	}
+
+	r.LineLength = len(r.Text)
+	r.TextOffset = start
+	r.Text = r.Text[start:end]
+	r.Matches = matches
+}
+
+// Cropped reports whether Text leaves out the start and the end of the line
+func (r *SearchResult) Cropped() (before, after bool) {
+	return r.TextOffset > 0, r.TextOffset+len(r.Text) < r.LineLength
+}
+
+// DisplayText returns the text to show for the result: its line, with an
+// ellipsis where a long line was cropped, or BinaryText
+func (r *SearchResult) DisplayText() string {
+	if r.Binary {
+		return BinaryText
+	}
+	text := r.Text
+	before, after := r.Cropped()
+	if before {
+		text = "…" + text
+	}
+	if after {
+		text += "…"
+	}
+	return text
+}
+
+// runeStart moves i back to the start of the UTF-8 sequence it is in
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// ParseFilesize parses a file size limit in ripgrep's --max-filesize format:
+// a number of bytes with an optional K, M or G suffix
+func ParseFilesize(size string) (int64, error) {
+	s := strings.TrimSpace(size)
+	unit := int64(1)
+	if s != "" {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			unit = 1 << 10
+		case "M":
+			unit = 1 << 20
+		case "G":
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid file size %q (bytes, or a number with a K, M or G suffix)", size)
+	}
+	return n * unit, nil
+}
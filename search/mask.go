@@ -0,0 +1,160 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mask is a parsed file mask
+type Mask struct {
+	Includes []string // Globs a file must match
+	Excludes []string // Globs a file must not match (written with a ! prefix)
+}
+
+// MaskError reports an entry of a file mask that is not a valid glob
+type MaskError struct {
+	Glob   string
+	Reason string
+}
+
+func (e *MaskError) Error() string {
+	return fmt.Sprintf("invalid file mask %q: %s", e.Glob, e.Reason)
+}
+
+// ParseMask parses a comma-separated list of globs like "*.go, !*_test.go, !vendor/**"
+// Entries prefixed with ! are excludes. Commas inside braces ("*.{ts,tsx}")
+// belong to the glob and do not split entries.
+func ParseMask(s string) (Mask, error) {
+	var mask Mask
+	for _, entry := range splitMask(s) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		exclude := strings.HasPrefix(entry, "!")
+		glob := strings.TrimSpace(strings.TrimPrefix(entry, "!"))
+		if glob == "" {
+			return Mask{}, &MaskError{Glob: entry, Reason: "empty pattern"}
+		}
+		if err := validateGlob(glob); err != nil {
+			return Mask{}, &MaskError{Glob: entry, Reason: err.Error()}
+		}
+
+		if exclude {
+			mask.Excludes = append(mask.Excludes, glob)
+		} else {
+			mask.Includes = append(mask.Includes, glob)
+		}
+	}
+	return mask, nil
+}
+
+// IsEmpty reports whether the mask matches every file
+func (m Mask) IsEmpty() bool {
+	return len(m.Includes) == 0 && len(m.Excludes) == 0
+}
+
+// Entries returns the mask entries in display form, excludes prefixed with !
+func (m Mask) Entries() []string {
+	entries := make([]string, 0, len(m.Includes)+len(m.Excludes))
+	entries = append(entries, m.Includes...)
+	for _, glob := range m.Excludes {
+		entries = append(entries, "!"+glob)
+	}
+	return entries
+}
+
+// String returns the mask in the same comma-separated form ParseMask accepts
+func (m Mask) String() string {
+	return strings.Join(m.Entries(), ", ")
+}
+
+// splitMask splits a mask on commas that are not inside braces or brackets
+func splitMask(s string) []string {
+	var entries []string
+	depth := 0
+	inClass := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++ // Skip the escaped character
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '{':
+			if !inClass {
+				depth++
+			}
+		case '}':
+			if !inClass && depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 && !inClass {
+				entries = append(entries, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(entries, s[start:])
+}
+
+// validateGlob checks a glob for the syntax errors ripgrep would reject
+func validateGlob(glob string) error {
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			if i == len(glob)-1 {
+				return fmt.Errorf("dangling '\\' at end of pattern")
+			}
+			i++
+		case '[':
+			end, err := classEnd(glob, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case '{':
+			if depth > 0 {
+				return fmt.Errorf("nested '{' alternates are not allowed")
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				return fmt.Errorf("unopened '}'")
+			}
+			depth--
+		}
+	}
+	if depth > 0 {
+		return fmt.Errorf("unclosed '{'")
+	}
+	return nil
+}
+
+// classEnd returns the index of the ']' closing the character class at start
+func classEnd(glob string, start int) (int, error) {
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		i++
+	}
+	// A ']' right after the opening bracket is a literal
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		switch glob[i] {
+		case ']':
+			return i, nil
+		case '-':
+			if i > start+1 && i+1 < len(glob) && glob[i+1] != ']' && glob[i-1] > glob[i+1] {
+				return 0, fmt.Errorf("invalid range '%c-%c'", glob[i-1], glob[i+1])
+			}
+		}
+	}
+	return 0, fmt.Errorf("unclosed '['")
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMask(t *testing.T) {
+	tests := []struct {
+		mask     string
+		includes []string
+		excludes []string
+	}{
+		{"", nil, nil},
+		{"*.go", []string{"*.go"}, nil},
+		{"*.go, !*_test.go, !vendor/**", []string{"*.go"}, []string{"*_test.go", "vendor/**"}},
+		{"  *.go ,*.md,, ", []string{"*.go", "*.md"}, nil},
+		{"! *.log", nil, []string{"*.log"}},
+		{"*.{ts,tsx}, !*.d.{ts,tsx}", []string{"*.{ts,tsx}"}, []string{"*.d.{ts,tsx}"}},
+		{"[,]x, y", []string{"[,]x", "y"}, nil},
+		{`a\,b, c`, []string{`a\,b`, "c"}, nil},
+		{"file name.txt", []string{"file name.txt"}, nil},
+		{"[]a]*, [!a-c]?", []string{"[]a]*", "[!a-c]?"}, nil},
+	}
+	for _, tt := range tests {
+		mask, err := ParseMask(tt.mask)
+		if err != nil {
+			t.Errorf("ParseMask(%q): %v", tt.mask, err)
+			continue
+		}
+		if !reflect.DeepEqual(mask.Includes, tt.includes) || !reflect.DeepEqual(mask.Excludes, tt.excludes) {
+			t.Errorf("ParseMask(%q) = %q / %q, want %q / %q", tt.mask, mask.Includes, mask.Excludes, tt.includes, tt.excludes)
+		}
+	}
+}
+
+func TestParseMaskErrors(t *testing.T) {
+	tests := []struct {
+		mask, glob, reason string
+	}{
+		{"*.go, !", "!", "empty pattern"},
+		{"*.{go", "*.{go", "unclosed '{'"},
+		{"*.go}", "*.go}", "unopened '}'"},
+		{"{a,{b,c}}", "{a,{b,c}}", "nested '{' alternates are not allowed"},
+		{"*.[ch", "*.[ch", "unclosed '['"},
+		{"[z-a]", "[z-a]", "invalid range 'z-a'"},
+		{`*.go, foo\`, `foo\`, `dangling '\' at end of pattern`},
+	}
+	for _, tt := range tests {
+		_, err := ParseMask(tt.mask)
+		var maskErr *MaskError
+		if !errors.As(err, &maskErr) {
+			t.Errorf("ParseMask(%q) error = %v, want a MaskError", tt.mask, err)
+			continue
+		}
+		if maskErr.Glob != tt.glob || maskErr.Reason != tt.reason {
+			t.Errorf("ParseMask(%q) error = %+v, want glob %q, reason %q", tt.mask, maskErr, tt.glob, tt.reason)
+		}
+	}
+}
+
+func TestMaskString(t *testing.T) {
+	mask, err := ParseMask("!*_test.go,*.go ,  *.{ts,tsx}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mask.String(), "*.go, *.{ts,tsx}, !*_test.go"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if mask.IsEmpty() {
+		t.Error("IsEmpty() = true")
+	}
+	if empty, _ := ParseMask(" , "); !empty.IsEmpty() {
+		t.Errorf("ParseMask(%q).IsEmpty() = false", " , ")
+	}
+}
@@ -0,0 +1,209 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CaseMode controls how ripgrep treats letter case in the query
+type CaseMode int
+
+const (
+	// CaseSmart ignores case unless the query contains an uppercase letter
+	CaseSmart CaseMode = iota
+	// CaseSensitive matches case exactly
+	CaseSensitive
+	// CaseInsensitive ignores case
+	CaseInsensitive
+)
+
+// Next returns the mode that follows m when cycling through case modes
+func (m CaseMode) Next() CaseMode {
+	switch m {
+	case CaseSmart:
+		return CaseSensitive
+	case CaseSensitive:
+		return CaseInsensitive
+	default:
+		return CaseSmart
+	}
+}
+
+// String returns a human readable name of the case mode
+func (m CaseMode) String() string {
+	switch m {
+	case CaseSensitive:
+		return "sensitive"
+	case CaseInsensitive:
+		return "insensitive"
+	default:
+		return "smart"
+	}
+}
+
+// ParseCaseMode parses the name of a case mode, as String returns it
+// An empty name is the smart mode.
+func ParseCaseMode(s string) (CaseMode, error) {
+	switch strings.ToLower(s) {
+	case "smart", "":
+		return CaseSmart, nil
+	case "sensitive":
+		return CaseSensitive, nil
+	case "insensitive":
+		return CaseInsensitive, nil
+	}
+	return CaseSmart, fmt.Errorf("invalid case mode %q (smart, sensitive or insensitive)", s)
+}
+
+// Options describes a single search
+// It is independent of any UI so both frontends and headless callers build
+// the ripgrep command line the same way through Args.
+type Options struct {
+	Query     string
+	Regex     bool     // Treat the query as a regular expression instead of a literal string
+	CaseMode  CaseMode // How letter case is matched
+	WholeWord bool     // Only match whole words
+
+	// Dir is the working directory of ripgrep (empty means current directory)
+	// Result paths are relative to it.
+	Dir string
+	// Roots are the files or directories to search, relative to Dir or absolute
+	// (empty means Dir itself)
+	Roots []string
+
+	Includes []string // Globs a file must match (--glob)
+	Excludes []string // Globs a file must not match (--glob !)
+	Types    []string // ripgrep file types to search (--type)
+	TypesNot []string // ripgrep file types to skip (--type-not)
+
+	ContextLines int    // Lines of context around each match (0 means none)
+	MaxCount     int    // Maximum number of matching lines per file (0 means unlimited)
+	Hidden       bool   // Search hidden files and directories
+	NoIgnore     bool   // Don't respect .gitignore and other ignore files
+	Encoding     string // Text encoding of the searched files (empty means auto)
+	MaxFilesize  string // Skip files larger than this, e.g. "10M" (empty means no limit)
+
+	// ExtraArgs are passed to ripgrep as is, before the query
+	// They must not change the output format (--json is always used).
+	ExtraArgs []string
+}
+
+// IgnoreCase reports whether the query is matched regardless of letter case
+func (o Options) IgnoreCase() bool {
+	switch o.CaseMode {
+	case CaseInsensitive:
+		return true
+	case CaseSmart:
+		return !hasUpper(o.Query)
+	}
+	return false
+}
+
+// Regexp compiles the query into a Go regular expression matching what
+// ripgrep matches, for callers that match lines themselves
+func (o Options) Regexp() (*regexp.Regexp, error) {
+	pattern := o.Query
+	if !o.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if o.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if o.IgnoreCase() {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// hasUpper reports whether s contains an uppercase letter (ripgrep's smart case rule)
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns the ripgrep argument list (without the program name) for the options
+func (o Options) Args() []string {
+	args := []string{
+		"--json",
+	}
+
+	if !o.Regex {
+		args = append(args, "--fixed-strings")
+	}
+
+	switch o.CaseMode {
+	case CaseSensitive:
+		args = append(args, "--case-sensitive")
+	case CaseInsensitive:
+		args = append(args, "--ignore-case")
+	default:
+		args = append(args, "--smart-case")
+	}
+
+	if o.WholeWord {
+		args = append(args, "--word-regexp")
+	}
+
+	if o.ContextLines > 0 {
+		args = append(args, "--context", strconv.Itoa(o.ContextLines))
+	}
+	if o.MaxCount > 0 {
+		args = append(args, "--max-count", strconv.Itoa(o.MaxCount))
+	}
+	if o.Encoding != "" {
+		args = append(args, "--encoding", o.Encoding)
+	}
+	if o.MaxFilesize != "" {
+		args = append(args, "--max-filesize", o.MaxFilesize)
+	}
+
+	args = append(args, o.walkArgs()...)
+
+	args = append(args, "--", o.Query)
+	args = append(args, o.Roots...)
+
+	return args
+}
+
+// FilesArgs returns the ripgrep argument list that prints the files a
+// search with the options looks at (--files), ignoring MaxFilesize
+func (o Options) FilesArgs() []string {
+	args := append([]string{"--files"}, o.walkArgs()...)
+	args = append(args, "--")
+	return append(args, o.Roots...)
+}
+
+// walkArgs returns the arguments that select the files to search
+func (o Options) walkArgs() []string {
+	var args []string
+	if o.Hidden {
+		args = append(args, "--hidden")
+	}
+	if o.NoIgnore {
+		args = append(args, "--no-ignore")
+	}
+
+	for _, t := range o.Types {
+		args = append(args, "--type", t)
+	}
+	for _, t := range o.TypesNot {
+		args = append(args, "--type-not", t)
+	}
+
+	// Later globs take precedence in ripgrep, so excludes go last
+	for _, glob := range o.Includes {
+		args = append(args, "--glob", glob)
+	}
+	for _, glob := range o.Excludes {
+		args = append(args, "--glob", "!"+glob)
+	}
+
+	return append(args, o.ExtraArgs...)
+}
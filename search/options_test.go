@@ -0,0 +1,86 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "literal with smart case",
+			opts: Options{Query: "foo"},
+			want: "--json --fixed-strings --smart-case -- foo",
+		},
+		{
+			name: "regex",
+			opts: Options{Query: `fo+\d`, Regex: true},
+			want: `--json --smart-case -- fo+\d`,
+		},
+		{
+			name: "case sensitive",
+			opts: Options{Query: "foo", CaseMode: CaseSensitive},
+			want: "--json --fixed-strings --case-sensitive -- foo",
+		},
+		{
+			name: "case insensitive",
+			opts: Options{Query: "Foo", CaseMode: CaseInsensitive},
+			want: "--json --fixed-strings --ignore-case -- Foo",
+		},
+		{
+			name: "whole word",
+			opts: Options{Query: "foo", WholeWord: true},
+			want: "--json --fixed-strings --smart-case --word-regexp -- foo",
+		},
+		{
+			name: "mask globs, excludes last",
+			opts: Options{Query: "foo", Includes: []string{"*.go", "*.md"}, Excludes: []string{"*_test.go"}},
+			want: "--json --fixed-strings --smart-case --glob *.go --glob *.md --glob !*_test.go -- foo",
+		},
+		{
+			name: "walk and limit options",
+			opts: Options{
+				Query: "foo", ContextLines: 2, MaxCount: 5, Encoding: "utf-16", MaxFilesize: "1M",
+				Hidden: true, NoIgnore: true, Types: []string{"go"}, TypesNot: []string{"js"},
+				ExtraArgs: []string{"--follow"},
+			},
+			want: "--json --fixed-strings --smart-case --context 2 --max-count 5 --encoding utf-16 --max-filesize 1M " +
+				"--hidden --no-ignore --type go --type-not js --follow -- foo",
+		},
+		{
+			name: "roots after the query",
+			opts: Options{Query: "foo", Roots: []string{"a", "b/c.go"}},
+			want: "--json --fixed-strings --smart-case -- foo a b/c.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.opts.Args(), " "); got != tt.want {
+				t.Errorf("Args() = %q\nwant     %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgsQueryIsNotAFlag(t *testing.T) {
+	// A query starting with "-" is passed after "--" so rg doesn't read it
+	// as a flag
+	args := Options{Query: "-foo", Roots: []string{"-dir"}}.Args()
+	want := []string{"--", "-foo", "-dir"}
+	if got := args[len(args)-3:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() ends with %q, want %q", got, want)
+	}
+}
+
+func TestFilesArgs(t *testing.T) {
+	opts := Options{Query: "foo", Hidden: true, Includes: []string{"*.go"}, MaxFilesize: "1M", Roots: []string{"a"}}
+	want := "--files --hidden --glob *.go -- a"
+	if got := strings.Join(opts.FilesArgs(), " "); got != want {
+		t.Errorf("FilesArgs() = %q, want %q", got, want)
+	}
+}
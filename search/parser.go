@@ -1,58 +1,124 @@
 package search
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// ParseVimgrepLine parses a single line of ripgrep vimgrep output
-// Format: file:line:column:text
-func ParseVimgrepLine(line string) (*SearchResult, error) {
-	// Find the last colon before the text content
-	// We need to split on colons, but the text part may contain colons
-	parts := strings.SplitN(line, ":", 4)
-	if len(parts) < 4 {
-		return nil, fmt.Errorf("invalid vimgrep format: %s", line)
-	}
+// rgMessage is a single message of ripgrep's --json output
+type rgMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
 
-	file := parts[0]
-	lineNum, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("invalid line number: %s", parts[1])
+// rgData is either a UTF-8 string or base64 encoded raw bytes
+type rgData struct {
+	Text  *string `json:"text"`
+	Bytes *string `json:"bytes"`
+}
+
+// rgMatch is the data of a "match" message
+type rgMatch struct {
+	Path           rgData `json:"path"`
+	Lines          rgData `json:"lines"`
+	LineNumber     int    `json:"line_number"`
+	AbsoluteOffset int64  `json:"absolute_offset"`
+	Submatches     []struct {
+		Match rgData `json:"match"`
+		Start int    `json:"start"`
+		End   int    `json:"end"`
+	} `json:"submatches"`
+}
+
+// decode returns the value as a string and whether it was sent as raw bytes
+func (d rgData) decode() (string, bool, error) {
+	if d.Text != nil {
+		return *d.Text, false, nil
+	}
+	if d.Bytes != nil {
+		b, err := base64.StdEncoding.DecodeString(*d.Bytes)
+		if err != nil {
+			return "", true, fmt.Errorf("invalid base64 data: %w", err)
+		}
+		return string(b), true, nil
 	}
+	return "", false, nil
+}
 
-	columnNum, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return nil, fmt.Errorf("invalid column number: %s", parts[2])
+// ParseJSONLine parses a single line of ripgrep --json output
+// It returns nil without an error for messages that are not matches
+// (begin, end, context and summary messages)
+func ParseJSONLine(line []byte) (*SearchResult, error) {
+	var msg rgMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, fmt.Errorf("invalid json message: %w", err)
+	}
+	if msg.Type != "match" {
+		return nil, nil
 	}
 
-	text := parts[3]
+	var data rgMatch
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return nil, fmt.Errorf("invalid match message: %w", err)
+	}
 
-	return &SearchResult{
-		File:   file,
-		Line:   lineNum,
-		Column: columnNum,
-		Text:   text,
-	}, nil
-}
+	file, pathIsBytes, err := data.Path.decode()
+	if err != nil {
+		return nil, err
+	}
+	text, _, err := data.Lines.decode()
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimRight(text, "\r\n")
 
-// ParseVimgrepOutput parses multiple lines of ripgrep vimgrep output
-func ParseVimgrepOutput(output string) []*SearchResult {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	results := make([]*SearchResult, 0, len(lines))
+	// A NUL byte means ripgrep searched a binary file (e.g. one given as a
+	// root); its "line" is meaningless
+	if strings.IndexByte(text, 0) >= 0 {
+		return &SearchResult{
+			File:           file,
+			Line:           data.LineNumber,
+			Column:         1,
+			AbsoluteOffset: data.AbsoluteOffset,
+			PathIsBytes:    pathIsBytes,
+			Binary:         true,
+		}, nil
+	}
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
+	matches := make([]Submatch, 0, len(data.Submatches))
+	for _, sm := range data.Submatches {
+		start, end := sm.Start, sm.End
+		// Matches may include the stripped line terminator
+		if start > len(text) {
+			start = len(text)
 		}
-		result, err := ParseVimgrepLine(line)
-		if err != nil {
-			// Skip invalid lines
-			continue
+		if end > len(text) {
+			end = len(text)
 		}
-		results = append(results, result)
+		matches = append(matches, Submatch{
+			Start: start,
+			End:   end,
+			Text:  text[start:end],
+		})
 	}
 
-	return results
+	column := 1
+	if len(matches) > 0 {
+		column = utf8.RuneCountInString(text[:matches[0].Start]) + 1
+	}
+
+	result := &SearchResult{
+		File:           file,
+		Line:           data.LineNumber,
+		Column:         column,
+		Text:           text,
+		Matches:        matches,
+		AbsoluteOffset: data.AbsoluteOffset,
+		PathIsBytes:    pathIsBytes,
+	}
+	result.Crop()
+	return result, nil
 }
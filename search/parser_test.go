@@ -0,0 +1,119 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// rgLine builds an rg --json message; data values that are []byte are sent
+// as base64 "bytes", strings as "text"
+func rgLine(t *testing.T, typ string, path, lines any, lineNumber int, submatches ...[2]int) []byte {
+	t.Helper()
+	field := func(v any) map[string]string {
+		if b, ok := v.([]byte); ok {
+			return map[string]string{"bytes": base64.StdEncoding.EncodeToString(b)}
+		}
+		return map[string]string{"text": v.(string)}
+	}
+	var sms []map[string]any
+	for _, sm := range submatches {
+		sms = append(sms, map[string]any{"match": map[string]string{"text": ""}, "start": sm[0], "end": sm[1]})
+	}
+	line, err := json.Marshal(map[string]any{
+		"type": typ,
+		"data": map[string]any{
+			"path":            field(path),
+			"lines":           field(lines),
+			"line_number":     lineNumber,
+			"absolute_offset": 120,
+			"submatches":      sms,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line
+}
+
+func TestParseJSONLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line []byte
+		want *SearchResult
+	}{
+		{
+			name: "text path and line",
+			line: rgLine(t, "match", "pkg/a.go", "x := foo()\n", 3, [2]int{5, 8}),
+			want: &SearchResult{
+				File: "pkg/a.go", Line: 3, Column: 6, Text: "x := foo()", AbsoluteOffset: 120,
+				Matches: []Submatch{{Start: 5, End: 8, Text: "foo"}},
+			},
+		},
+		{
+			name: "bytes path",
+			line: rgLine(t, "match", []byte("caf\xe9.txt"), "foo\r\n", 1, [2]int{0, 3}),
+			want: &SearchResult{
+				File: "caf\xe9.txt", Line: 1, Column: 1, Text: "foo", AbsoluteOffset: 120, PathIsBytes: true,
+				Matches: []Submatch{{Start: 0, End: 3, Text: "foo"}},
+			},
+		},
+		{
+			name: "bytes line",
+			line: rgLine(t, "match", "latin1.txt", []byte("\xe9t\xe9 foo\n"), 2, [2]int{4, 7}),
+			want: &SearchResult{
+				File: "latin1.txt", Line: 2, Column: 5, Text: "\xe9t\xe9 foo", AbsoluteOffset: 120,
+				Matches: []Submatch{{Start: 4, End: 7, Text: "foo"}},
+			},
+		},
+		{
+			name: "column counts runes before the first of several matches",
+			line: rgLine(t, "match", "a.go", "héllo wörld foo foo\n", 7, [2]int{14, 17}, [2]int{18, 21}),
+			want: &SearchResult{
+				File: "a.go", Line: 7, Column: 13, Text: "héllo wörld foo foo", AbsoluteOffset: 120,
+				Matches: []Submatch{{Start: 14, End: 17, Text: "foo"}, {Start: 18, End: 21, Text: "foo"}},
+			},
+		},
+		{
+			name: "match on the line terminator",
+			line: rgLine(t, "match", "a.go", "foo\n", 1, [2]int{3, 4}),
+			want: &SearchResult{
+				File: "a.go", Line: 1, Column: 4, Text: "foo", AbsoluteOffset: 120,
+				Matches: []Submatch{{Start: 3, End: 3, Text: ""}},
+			},
+		},
+		{
+			name: "NUL byte makes a binary match",
+			line: rgLine(t, "match", "app.bin", []byte("\x00\x01foo\n"), 1, [2]int{2, 5}),
+			want: &SearchResult{File: "app.bin", Line: 1, Column: 1, AbsoluteOffset: 120, Binary: true},
+		},
+		{name: "begin", line: []byte(`{"type":"begin","data":{"path":{"text":"a.go"}}}`)},
+		{name: "end", line: []byte(`{"type":"end","data":{"path":{"text":"a.go"},"binary_offset":null,"stats":{}}}`)},
+		{name: "context", line: rgLine(t, "context", "a.go", "near\n", 2)},
+		{name: "summary", line: []byte(`{"type":"summary","data":{"elapsed_total":{"secs":0,"nanos":1},"stats":{}}}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJSONLine(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseJSONLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONLineErrors(t *testing.T) {
+	for _, line := range []string{
+		`not json`,
+		`{"type":"match","data":[]}`,
+		`{"type":"match","data":{"path":{"bytes":"!!"},"lines":{"text":"foo"}}}`,
+	} {
+		if got, err := ParseJSONLine([]byte(line)); err == nil {
+			t.Errorf("ParseJSONLine(%s) = %+v, want an error", line, got)
+		}
+	}
+}
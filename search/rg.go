@@ -2,10 +2,27 @@ package search
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
+)
+
+const (
+	// batchSize is the maximum number of results sent in a single partial batch
+	batchSize = 200
+	// batchInterval is how often pending results are flushed to the consumer
+	batchInterval = 50 * time.Millisecond
+	// maxMessageSize is the size of the longest ripgrep message read; longer
+	// ones (matches on lines of many megabytes) are skipped
+	maxMessageSize = 10 * 1024 * 1024
 )
 
 // Searcher handles ripgrep search execution
@@ -19,118 +36,373 @@ func NewSearcher() *Searcher {
 }
 
 // SearchResultMsg is sent when search results are available
+// A search produces zero or more partial batches followed by a final
+// message with Done set. Results in each batch are new results only and
+// must be appended to the ones received before.
 type SearchResultMsg struct {
 	SearchID int64
 	Results  []*SearchResult
 	Error    error
+	Done     bool
+	// Skipped counts what the search left out; it is set with Done
+	Skipped Skipped
+}
+
+// Skipped counts the files and lines a search left out
+type Skipped struct {
+	LargeFiles int // Files larger than Options.MaxFilesize
+	LongLines  int // Matching lines longer than fif reads
+}
+
+// String describes what was skipped, e.g. "3 large files skipped", or
+// returns "" if nothing was
+func (s Skipped) String() string {
+	var parts []string
+	if s.LargeFiles > 0 {
+		parts = append(parts, Plural(s.LargeFiles, "large file", "large files"))
+	}
+	if s.LongLines > 0 {
+		parts = append(parts, Plural(s.LongLines, "long line", "long lines"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, ", ") + " skipped"
 }
 
-// Search executes a ripgrep search with the given query and glob pattern
-// It returns a channel that will receive search results as they come in
-// searchPath specifies the directory to search in (empty means current directory)
-func (s *Searcher) Search(ctx context.Context, query, globPattern, searchPath string) <-chan SearchResultMsg {
+// Plural formats a count with the singular or plural form of a noun, e.g.
+// "1 file" or "3 files"
+func Plural(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// CurrentID returns the ID of the most recently started search
+func (s *Searcher) CurrentID() int64 {
+	return s.searchID
+}
+
+// Search executes a ripgrep search described by opts
+// It returns a channel that will receive search results in batches as they come in
+func (s *Searcher) Search(ctx context.Context, opts Options) <-chan SearchResultMsg {
 	s.searchID++
 	currentID := s.searchID
-	resultChan := make(chan SearchResultMsg, 1)
+	resultChan := make(chan SearchResultMsg, 16)
+
+	// send delivers a message unless the search has been cancelled
+	send := func(msg SearchResultMsg) bool {
+		msg.SearchID = currentID
+		select {
+		case resultChan <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 
 	go func() {
 		defer close(resultChan)
 
-		// Build ripgrep command
-		args := []string{
-			"--vimgrep",
-			"--no-heading",
-			"--color=never",
-		}
-
-		if globPattern != "" {
-			args = append(args, "--glob", globPattern)
+		// Result paths are relative to the directory rg runs in
+		// If opts.Dir is empty, ripgrep searches from the current directory
+		root, err := filepath.Abs(opts.Dir)
+		if err != nil {
+			send(SearchResultMsg{
+				Error: fmt.Errorf("failed to resolve search directory: %w", err),
+				Done:  true,
+			})
+			return
 		}
 
-		args = append(args, query)
-
-		// Set search path (directory to search in)
-		// If empty, ripgrep will search from current directory
-		var cmd *exec.Cmd
-		if searchPath != "" {
-			cmd = exec.CommandContext(ctx, "rg", args...)
-			cmd.Dir = searchPath
+		// Files over the size limit are counted by listing the files rg
+		// walks, as it doesn't report the ones it skips. The listing only
+		// runs when there is a limit.
+		largeFiles := make(chan int, 1)
+		if opts.MaxFilesize != "" {
+			go func() {
+				n, _ := countLargeFiles(ctx, opts, root)
+				largeFiles <- n
+			}()
 		} else {
-			cmd = exec.CommandContext(ctx, "rg", args...)
+			largeFiles <- 0
 		}
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			resultChan <- SearchResultMsg{
-				SearchID: currentID,
-				Error:    fmt.Errorf("failed to create stdout pipe: %w", err),
+
+		// A long list of files (e.g. a changed-files scope) doesn't fit on
+		// one command line, so it is searched by several rg runs in turn
+		var skipped Skipped
+		for _, roots := range rootBatches(opts.Roots) {
+			batchOpts := opts
+			batchOpts.Roots = roots
+			longLines, err := runRg(ctx, batchOpts, root, send)
+			if ctx.Err() != nil {
+				return
 			}
-			return
+			if err != nil {
+				send(SearchResultMsg{Error: err, Done: true})
+				return
+			}
+			skipped.LongLines += longLines
 		}
 
-		if err := cmd.Start(); err != nil {
-			resultChan <- SearchResultMsg{
-				SearchID: currentID,
-				Error:    fmt.Errorf("failed to start ripgrep: %w", err),
-			}
+		select {
+		case skipped.LargeFiles = <-largeFiles:
+		case <-ctx.Done():
 			return
 		}
+		send(SearchResultMsg{Done: true, Skipped: skipped})
+	}()
 
-		// Read output line by line
-		// Use a larger buffer to handle very long lines (default is 64KB)
-		scanner := bufio.NewScanner(stdout)
-		buf := make([]byte, 0, 1024*1024) // 1MB initial capacity
-		scanner.Buffer(buf, 10*1024*1024) // Allow up to 10MB per line
-		results := make([]*SearchResult, 0)
+	return resultChan
+}
 
-		for scanner.Scan() {
-			// Check if context was cancelled
+// runRg runs rg once and sends its results in batches as they come in
+// It returns the number of long lines it skipped. When the search is
+// cancelled it returns early; the caller checks ctx.
+func runRg(ctx context.Context, opts Options, root string, send func(SearchResultMsg) bool) (int, error) {
+	cmd := exec.CommandContext(ctx, "rg", opts.Args()...)
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start ripgrep: %w", err)
+	}
+
+	// Read output line by line in a separate goroutine so that pending
+	// results can be flushed on a timer even while rg is quiet
+	lines := make(chan *SearchResult, batchSize)
+	scanErr := make(chan error, 1)
+	longLines := 0
+	go func() {
+		defer close(lines)
+		// Use a larger buffer to handle very long lines (default is 4KB)
+		reader := bufio.NewReaderSize(stdout, 1024*1024)
+		binaryFile := ""
+		for {
+			line, tooLong, err := readMessage(reader)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				scanErr <- err
+				return
+			}
+			if tooLong {
+				longLines++
+				continue
+			}
+			result, err := ParseJSONLine(line)
+			if err != nil || result == nil {
+				// Skip invalid lines and non-match messages
+				continue
+			}
+			if result.Binary {
+				// One "binary file matches" per file is enough
+				if result.File == binaryFile {
+					continue
+				}
+				binaryFile = result.File
+			}
+			result.Root = root
 			select {
+			case lines <- result:
 			case <-ctx.Done():
-				cmd.Process.Kill()
-				cmd.Wait()
 				return
-			default:
 			}
+		}
+	}()
 
-			line := scanner.Text()
-			result, err := ParseVimgrepLine(line)
-			if err != nil {
-				// Skip invalid lines
-				continue
-			}
-			results = append(results, result)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SearchResult, 0, batchSize)
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
 		}
+		ok := send(SearchResultMsg{Results: batch})
+		batch = make([]*SearchResult, 0, batchSize)
+		return ok
+	}
 
-		if err := scanner.Err(); err != nil {
-			resultChan <- SearchResultMsg{
-				SearchID: currentID,
-				Error:    fmt.Errorf("failed to read output: %w", err),
+readLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			cmd.Wait()
+			return 0, ctx.Err()
+		case <-ticker.C:
+			if !flush() {
+				cmd.Wait()
+				return 0, ctx.Err()
+			}
+		case result, ok := <-lines:
+			if !ok {
+				break readLoop
+			}
+			batch = append(batch, result)
+			if len(batch) >= batchSize && !flush() {
+				cmd.Wait()
+				return 0, ctx.Err()
 			}
-			return
 		}
+	}
 
-		if err := cmd.Wait(); err != nil {
-			// ripgrep returns non-zero exit code when no matches found
-			// This is not an error, just empty results
-			if strings.Contains(err.Error(), "exit status 1") {
-				resultChan <- SearchResultMsg{
-					SearchID: currentID,
-					Results:  []*SearchResult{},
-				}
-				return
+	if !flush() {
+		cmd.Wait()
+		return 0, ctx.Err()
+	}
+
+	var readErr error
+	select {
+	case readErr = <-scanErr:
+	default:
+	}
+	// lines is closed, so the reader is done with longLines
+	if readErr != nil {
+		cmd.Wait()
+		return 0, fmt.Errorf("failed to read output: %w", readErr)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		// ripgrep returns non-zero exit code when no matches found
+		// This is not an error, just empty results
+		if strings.Contains(err.Error(), "exit status 1") {
+			return longLines, nil
+		}
+		// Prefer ripgrep's own message (e.g. a regex parse error)
+		// over the bare exit status
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return 0, errors.New(rgErrorMessage(msg))
+		}
+		return 0, fmt.Errorf("ripgrep failed: %w", err)
+	}
+	return longLines, nil
+}
+
+// countLargeFiles counts the files a search with opts walks that are larger
+// than opts.MaxFilesize
+// Paths are relative to root, the absolute directory rg runs in.
+func countLargeFiles(ctx context.Context, opts Options, root string) (int, error) {
+	limit, err := ParseFilesize(opts.MaxFilesize)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, roots := range rootBatches(opts.Roots) {
+		batchOpts := opts
+		batchOpts.Roots = roots
+		cmd := exec.CommandContext(ctx, "rg", batchOpts.FilesArgs()...)
+		cmd.Dir = root
+		out, err := cmd.Output()
+		if err != nil {
+			return n, err
+		}
+		for _, file := range strings.Split(string(out), "\n") {
+			if file == "" {
+				continue
 			}
-			resultChan <- SearchResultMsg{
-				SearchID: currentID,
-				Error:    fmt.Errorf("ripgrep failed: %w", err),
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(root, file)
+			}
+			if info, err := os.Stat(file); err == nil && info.Size() > limit {
+				n++
 			}
-			return
 		}
+	}
+	return n, nil
+}
+
+// maxRootsSize returns the number of bytes of roots passed to one rg run
+// It stays well below the limit on the size of a command line: ARG_MAX
+// (including the environment) on Unix and 32K characters on Windows.
+func maxRootsSize() int {
+	if runtime.GOOS == "windows" {
+		return 16 * 1024
+	}
+	return 128 * 1024
+}
+
+// rootBatches splits roots into groups that fit on one rg command line
+// Without roots there is a single empty group: rg searches its directory.
+func rootBatches(roots []string) [][]string {
+	if len(roots) == 0 {
+		return [][]string{nil}
+	}
+	var batches [][]string
+	start, size := 0, 0
+	for i, root := range roots {
+		if size > 0 && size+len(root)+1 > maxRootsSize() {
+			batches = append(batches, roots[start:i])
+			start, size = i, 0
+		}
+		size += len(root) + 1
+	}
+	return append(batches, roots[start:])
+}
+
+// readMessage reads the next line of ripgrep's output
+// Lines longer than maxMessageSize are consumed but not kept: tooLong is
+// set instead.
+func readMessage(r *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) <= maxMessageSize {
+			line = append(line, chunk...)
+		} else {
+			line, tooLong = nil, true
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if errors.Is(err, io.EOF) && (len(line) > 0 || tooLong) {
+				return line, tooLong, nil
+			}
+			return line, tooLong, err
+		}
+	}
+}
+
+// Run runs a search that doesn't stream, such as one over git history,
+// under a new search ID
+// The channel receives a single final message with all the results fn found.
+func (s *Searcher) Run(ctx context.Context, fn func(context.Context) ([]*SearchResult, error)) <-chan SearchResultMsg {
+	s.searchID++
+	currentID := s.searchID
+	resultChan := make(chan SearchResultMsg, 1)
 
-		resultChan <- SearchResultMsg{
-			SearchID: currentID,
-			Results:  results,
+	go func() {
+		defer close(resultChan)
+		results, err := fn(ctx)
+		if ctx.Err() != nil {
+			return
 		}
+		resultChan <- SearchResultMsg{SearchID: currentID, Results: results, Error: err, Done: true}
 	}()
 
 	return resultChan
 }
+
+// rgErrorMessage condenses ripgrep's error output into a single line
+// ripgrep prints regex errors over several lines with the pattern, a caret
+// and the actual reason last; the status line only has room for one
+func rgErrorMessage(msg string) string {
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return msg
+	}
+	last := lines[len(lines)-1]
+	if len(lines) > 1 && strings.HasPrefix(last, "error:") {
+		return lines[0] + " " + strings.TrimSpace(strings.TrimPrefix(last, "error:"))
+	}
+	return lines[0]
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takaishi/fif/internal/rgtest"
+)
+
+func TestRootBatches(t *testing.T) {
+	if got := rootBatches(nil); len(got) != 1 || got[0] != nil {
+		t.Errorf("rootBatches(nil) = %q, want one empty batch", got)
+	}
+
+	root := strings.Repeat("x", 99) // 100 bytes with the separator
+	roots := make([]string, 3*maxRootsSize()/100+1)
+	for i := range roots {
+		roots[i] = root
+	}
+	batches := rootBatches(roots)
+	if len(batches) != 4 {
+		t.Fatalf("got %d batches, want 4", len(batches))
+	}
+	n := 0
+	for _, batch := range batches {
+		if size := len(batch) * 100; size > maxRootsSize() {
+			t.Errorf("batch of %d bytes, want at most %d", size, maxRootsSize())
+		}
+		n += len(batch)
+	}
+	if n != len(roots) {
+		t.Errorf("batches have %d roots, want %d", n, len(roots))
+	}
+}
+
+func TestSearchManyRoots(t *testing.T) {
+	rg := rgtest.Install(t)
+	roots := make([]string, 20000)
+	for i := range roots {
+		roots[i] = fmt.Sprintf("some/deeply/nested/dir/file%05d.go", i)
+	}
+
+	opts := Options{Query: "foo", Dir: t.TempDir(), Roots: roots}
+	seen := make(map[string]bool)
+	done := false
+	for msg := range NewSearcher().Search(context.Background(), opts) {
+		if msg.Error != nil {
+			t.Fatal(msg.Error)
+		}
+		for _, result := range msg.Results {
+			if seen[result.File] {
+				t.Errorf("%s reported twice", result.File)
+			}
+			seen[result.File] = true
+		}
+		done = done || msg.Done
+	}
+	if !done {
+		t.Error("no Done message")
+	}
+	if len(seen) != len(roots) {
+		t.Errorf("got results for %d roots, want %d", len(seen), len(roots))
+	}
+
+	if got, want := len(rg.Dirs(t)), len(rootBatches(roots)); got != want || got < 2 {
+		t.Errorf("rg ran %d times, want %d (more than once)", got, want)
+	}
+}
+
+func TestSearchCountsLargeFiles(t *testing.T) {
+	rgtest.Install(t)
+	dir := t.TempDir()
+	for name, size := range map[string]int{"big.txt": 2048, "small.txt": 10} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		maxFilesize string
+		want        int
+	}{{"1K", 1}, {"", 0}} {
+		opts := Options{Query: "foo", Dir: dir, Roots: []string{"big.txt", "small.txt"}, MaxFilesize: tt.maxFilesize}
+		var skipped Skipped
+		for msg := range NewSearcher().Search(context.Background(), opts) {
+			if msg.Error != nil {
+				t.Fatal(msg.Error)
+			}
+			if msg.Done {
+				skipped = msg.Skipped
+			}
+		}
+		if skipped.LargeFiles != tt.want {
+			t.Errorf("max_filesize %q: LargeFiles = %d, want %d", tt.maxFilesize, skipped.LargeFiles, tt.want)
+		}
+	}
+}
@@ -1,9 +1,103 @@
 package search
 
+import (
+	"path/filepath"
+	"time"
+)
+
 // SearchResult represents a single search result from ripgrep
 type SearchResult struct {
-	File   string // 相対パス
+	File   string // Path relative to Root, as reported by ripgrep
+	Root   string // Absolute directory ripgrep ran in (Options.Dir)
 	Line   int    // 1-based
-	Column int
+	Column int    // 1-based, in characters, of the first match
 	Text   string // マッチ行
+
+	// Matches holds the byte range of every match within Text
+	Matches []Submatch
+	// AbsoluteOffset is the byte offset of the start of the line within the file
+	AbsoluteOffset int64
+	// PathIsBytes reports whether ripgrep reported the path as raw bytes
+	// because it is not valid UTF-8
+	PathIsBytes bool
+	// Binary reports a match in a binary file; Text and Matches are empty
+	// and only the first match of the file is kept
+	Binary bool
+	// TextOffset is the byte offset of Text within the line and LineLength
+	// the length of the line when Text is a window of a long line (see
+	// Crop); both are 0 otherwise
+	TextOffset int
+	LineLength int
+
+	// Revision is the commit the result was found in, for searches of git
+	// history; nil for the working tree
+	// File is then the git object name "<rev>:<path>" and Path is not a file
+	// on disk.
+	Revision *Revision
+}
+
+// Revision identifies the version of a file a history result comes from
+type Revision struct {
+	Rev     string // Revision the file is read from (a commit hash, or "<hash>^" for removed lines)
+	Path    string // Path of the file in the revision, relative to the result's Root
+	Hash    string // Full hash of the commit
+	Author  string
+	Date    time.Time
+	Subject string
+	Removed bool // The line was removed by the commit rather than added (pickaxe results)
+}
+
+// Short returns the abbreviated commit hash
+func (r *Revision) Short() string {
+	if len(r.Hash) > 7 {
+		return r.Hash[:7]
+	}
+	return r.Hash
+}
+
+// Path returns the absolute path of the result's file
+// Use it to read or open the file: File is relative to Root, not to the
+// working directory of the process.
+func (r *SearchResult) Path() string {
+	if filepath.IsAbs(r.File) || r.Root == "" {
+		return r.File
+	}
+	return filepath.Join(r.Root, r.File)
+}
+
+// ByteColumn returns the 1-based column of the first match in bytes, for
+// editors that count columns that way (Column counts characters)
+func (r *SearchResult) ByteColumn() int {
+	if len(r.Matches) > 0 {
+		return r.TextOffset + r.Matches[0].Start + 1
+	}
+	if r.TextOffset > 0 {
+		return r.Column // Cropped: the start of the line is gone
+	}
+	column := 1
+	for i := range r.Text {
+		if column == r.Column {
+			return i + 1
+		}
+		column++
+	}
+	return len(r.Text) + 1
+}
+
+// RelPath returns the path of the result's file relative to dir, for display
+// It falls back to the absolute path when there is no relative one
+// (e.g. on another Windows drive).
+func (r *SearchResult) RelPath(dir string) string {
+	path := r.Path()
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// Submatch is a single match within a result line
+type Submatch struct {
+	Start int // Byte offset of the match start within the line (inclusive)
+	End   int // Byte offset of the match end within the line (exclusive)
+	Text  string
 }
@@ -0,0 +1,80 @@
+package search
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResultPaths(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	tests := []struct {
+		name     string
+		result   SearchResult
+		dir      string
+		wantPath string
+		wantRel  string
+	}{
+		{
+			name:     "from the search root",
+			result:   SearchResult{Root: root, File: filepath.FromSlash("pkg/a.go")},
+			dir:      root,
+			wantPath: filepath.FromSlash("/repo/pkg/a.go"),
+			wantRel:  filepath.FromSlash("pkg/a.go"),
+		},
+		{
+			name:     "from a subdirectory of the root",
+			result:   SearchResult{Root: root, File: filepath.FromSlash("pkg/a.go")},
+			dir:      filepath.FromSlash("/repo/cmd/tool"),
+			wantPath: filepath.FromSlash("/repo/pkg/a.go"),
+			wantRel:  filepath.FromSlash("../../pkg/a.go"),
+		},
+		{
+			name:     "inside the current directory",
+			result:   SearchResult{Root: root, File: filepath.FromSlash("cmd/tool/main.go")},
+			dir:      filepath.FromSlash("/repo/cmd"),
+			wantPath: filepath.FromSlash("/repo/cmd/tool/main.go"),
+			wantRel:  filepath.FromSlash("tool/main.go"),
+		},
+		{
+			name:     "absolute file",
+			result:   SearchResult{Root: root, File: filepath.FromSlash("/other/b.go")},
+			dir:      root,
+			wantPath: filepath.FromSlash("/other/b.go"),
+			wantRel:  filepath.FromSlash("../other/b.go"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Path(); got != tt.wantPath {
+				t.Errorf("Path() = %q, want %q", got, tt.wantPath)
+			}
+			if got := tt.result.RelPath(tt.dir); got != tt.wantRel {
+				t.Errorf("RelPath(%q) = %q, want %q", tt.dir, got, tt.wantRel)
+			}
+		})
+	}
+}
+
+func TestByteColumn(t *testing.T) {
+	// "héllo wörld": w is the 7th character and starts at byte 8
+	text := "héllo wörld"
+	long := strings.Repeat("é", MaxLineLength) + " needle"
+	cropped := &SearchResult{Text: long, Column: MaxLineLength + 2, Matches: []Submatch{{Start: 2*MaxLineLength + 1, End: 2*MaxLineLength + 7}}}
+	cropped.Crop()
+	tests := []struct {
+		name   string
+		result *SearchResult
+		want   int
+	}{
+		{"ASCII", &SearchResult{Text: "hello world", Column: 7, Matches: []Submatch{{Start: 6, End: 11}}}, 7},
+		{"multibyte before the match", &SearchResult{Text: text, Column: 7, Matches: []Submatch{{Start: 7, End: 12}}}, 8},
+		{"no matches", &SearchResult{Text: text, Column: 7}, 8},
+		{"cropped line", cropped, 2*MaxLineLength + 2},
+	}
+	for _, tt := range tests {
+		if got := tt.result.ByteColumn(); got != tt.want {
+			t.Errorf("%s: ByteColumn() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
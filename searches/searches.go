@@ -0,0 +1,172 @@
+// Package searches keeps the searches a user ran, per repository, so they
+// can be brought back in a later session.
+package searches
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxEntries is the number of searches kept per repository
+const maxEntries = 500
+
+// Entry is a search as it was run: the query with the mask, scope and
+// toggles it was run with
+type Entry struct {
+	Query       string    `json:"query"`
+	Mask        string    `json:"mask,omitempty"`
+	MaskEnabled bool      `json:"mask_enabled,omitempty"`
+	Scope       string    `json:"scope,omitempty"` // Scope spec, e.g. "diff:main" (empty: the default scope)
+	Roots       []string  `json:"roots,omitempty"` // Directories of a "dirs" scope
+	Regex       bool      `json:"regex,omitempty"`
+	Case        string    `json:"case,omitempty"` // smart, sensitive or insensitive (empty: smart)
+	Word        bool      `json:"word,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// same reports whether two entries are the same search, whenever they ran
+func (e Entry) same(other Entry) bool {
+	if !slices.Equal(e.Roots, other.Roots) {
+		return false
+	}
+	e.Time, other.Time = time.Time{}, time.Time{}
+	e.Roots, other.Roots = nil, nil // Compared above: nil and empty are the same
+	return reflect.DeepEqual(e, other)
+}
+
+// History is the search history of one repository (or directory outside
+// a repository)
+type History struct {
+	path    string
+	entries []Entry // Oldest first
+}
+
+// StateDir returns the directory fif keeps its state in:
+// $XDG_STATE_HOME/fif, or ~/.local/state/fif
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "fif"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "fif"), nil
+}
+
+// Open loads the search history of root
+// Each root has its own file in the state directory, named after a hash of
+// its path. A missing file is an empty history.
+func Open(root string) (*History, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(root))
+	h := &History{path: filepath.Join(dir, "history", hex.EncodeToString(sum[:8])+".json")}
+
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h.entries); err != nil {
+		return nil, fmt.Errorf("failed to read search history %s: %w", h.path, err)
+	}
+	return h, nil
+}
+
+// Entries returns the searches, most recent first
+func (h *History) Entries() []Entry {
+	entries := make([]Entry, len(h.entries))
+	for i, e := range h.entries {
+		entries[len(entries)-1-i] = e
+	}
+	return entries
+}
+
+// Add records a search as the most recent one and saves the history
+// An earlier run of the same search is moved rather than repeated.
+func (h *History) Add(e Entry) error {
+	if e.Query == "" {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for i, old := range h.entries {
+		if old.same(e) {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, e)
+	if len(h.entries) > maxEntries {
+		h.entries = h.entries[len(h.entries)-maxEntries:]
+	}
+	return h.save()
+}
+
+// save writes the history file atomically, so concurrent fif sessions
+// never see a partial file (the last one to save wins)
+func (h *History) save() error {
+	data, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(h.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to save search history: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".history-*")
+	if err != nil {
+		return fmt.Errorf("failed to save search history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save search history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save search history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to save search history: %w", err)
+	}
+	return nil
+}
+
+// String describes the entry on one line, e.g. "TODO  mask: *.go  scope: changed  regex"
+func (e Entry) String() string {
+	parts := []string{e.Query}
+	if e.MaskEnabled && e.Mask != "" {
+		parts = append(parts, "mask: "+e.Mask)
+	}
+	if len(e.Roots) > 0 {
+		parts = append(parts, "scope: "+strings.Join(e.Roots, ", "))
+	} else if e.Scope != "" {
+		parts = append(parts, "scope: "+e.Scope)
+	}
+	if e.Regex {
+		parts = append(parts, "regex")
+	}
+	if e.Case != "" && e.Case != "smart" {
+		parts = append(parts, "case: "+e.Case)
+	}
+	if e.Word {
+		parts = append(parts, "word")
+	}
+	return strings.Join(parts, "  ")
+}
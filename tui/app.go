@@ -7,11 +7,15 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/highlight"
+	"github.com/takaishi/fif/keymap"
 	"github.com/takaishi/fif/preview"
+	"github.com/takaishi/fif/scope"
 	"github.com/takaishi/fif/search"
 )
 
@@ -42,15 +46,24 @@ type App struct {
 	searcher      *search.Searcher
 	searchCancel  context.CancelFunc
 	searchResults []*search.SearchResult
-	selectedIndex int
-	isSearching   bool
-	searchError   error
-	preview       *preview.Preview
-	previewError  error
-	editor        editor.Editor
-	searchScope   string // "project" or "directory"
-	gitRoot       string
-	currentDir    string
+	rows          []resultRow // Rows currently shown in resultsList
+	selectedIndex int         // Index of the selected row in resultsList
+	// Grouped view (toggled with Alt+G)
+	groupByFile    bool
+	collapsedFiles map[string]bool
+	isSearching    bool
+	searchError    error
+	preview        *preview.Preview
+	previewError   error
+	editor         editor.Editor
+	stayOpen       bool
+	searchScope    string // "project" or "directory"
+	gitRoot        string
+	currentDir     string
+
+	// Key bindings
+	keys     *keymap.Keymap
+	showHelp bool // Whether the key bindings are shown in the preview
 
 	// Debounce
 	searchTimer *time.Timer
@@ -70,14 +83,16 @@ func NewApp() *App {
 	}
 
 	app := &App{
-		app:           tview.NewApplication(),
-		searcher:      search.NewSearcher(),
-		editor:        ed,
-		searchScope:   searchScope,
-		gitRoot:       gitRoot,
-		currentDir:    currentDir,
-		maskEnabled:   true,
-		selectedIndex: -1,
+		app:            tview.NewApplication(),
+		searcher:       search.NewSearcher(),
+		editor:         ed,
+		searchScope:    searchScope,
+		gitRoot:        gitRoot,
+		currentDir:     currentDir,
+		maskEnabled:    true,
+		selectedIndex:  -1,
+		collapsedFiles: make(map[string]bool),
+		keys:           keymap.Default(),
 	}
 
 	app.setupUI()
@@ -89,6 +104,16 @@ func (a *App) SetEditor(ed editor.Editor) {
 	a.editor = ed
 }
 
+// SetStayOpen sets whether the application keeps running after opening a result
+func (a *App) SetStayOpen(stayOpen bool) {
+	a.stayOpen = stayOpen
+}
+
+// SetKeymap sets the key bindings
+func (a *App) SetKeymap(keys *keymap.Keymap) {
+	a.keys = keys
+}
+
 // Start starts the tview application
 func (a *App) Start() error {
 	return a.app.Run()
@@ -162,54 +187,6 @@ func (a *App) setupUI() {
 	// Note: This captures keys before they reach individual components
 	// We need to be careful to not interfere with component-specific keys
 	a.app.SetInputCapture(a.handleGlobalKeys)
-
-	// Set input capture on results list to ensure arrow keys work
-	// This is called AFTER the application's SetInputCapture
-	// So we need to handle Up/Down keys here to ensure they reach the list
-	a.resultsList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// CRITICAL: For Up/Down keys, let the list handle them
-		// This must return event to allow the list's internal navigation
-		if event.Key() == tcell.KeyUp || event.Key() == tcell.KeyDown {
-			return event // Let the list handle navigation
-		}
-		// Handle Enter key to open file
-		if event.Key() == tcell.KeyEnter {
-			currentIdx := a.resultsList.GetCurrentItem()
-			if currentIdx >= 0 && currentIdx < len(a.searchResults) {
-				result := a.searchResults[currentIdx]
-				if err := editor.OpenFile(a.editor, result.File, result.Line, result.Column); err != nil {
-					// Error opening editor
-				}
-				a.app.Stop()
-			}
-			return nil // Consume the event
-		}
-		// Handle j/k keys for vim-style navigation
-		if event.Key() == tcell.KeyRune {
-			if event.Rune() == 'j' || event.Rune() == 'J' {
-				// Move down
-				currentIdx := a.resultsList.GetCurrentItem()
-				if currentIdx < len(a.searchResults)-1 {
-					a.resultsList.SetCurrentItem(currentIdx + 1)
-				}
-				return nil
-			}
-			if event.Rune() == 'k' || event.Rune() == 'K' {
-				// Move up
-				currentIdx := a.resultsList.GetCurrentItem()
-				if currentIdx > 0 {
-					a.resultsList.SetCurrentItem(currentIdx - 1)
-				}
-				return nil
-			}
-		}
-		// For other keys, let the global handler process them first
-		// But we need to check if global handler wants to consume them
-		handled := a.handleGlobalKeys(event)
-		// If global handler returns nil, it consumed the event
-		// If it returns event, we should also return event to let list handle it
-		return handled
-	})
 }
 
 // buildLayout creates the UI layout
@@ -281,7 +258,10 @@ func (a *App) updateScopeTabs() {
 	a.scopeTabs.SetText(scopeText)
 }
 
-// handleGlobalKeys handles global keyboard shortcuts
+// handleGlobalKeys dispatches keys through the keymap
+// Keys without an action, and actions this frontend doesn't have, go to
+// the focused component. Printable keys go to the focused input while it
+// has text.
 func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 	// Get current focus
 	currentFocus := a.app.GetFocus()
@@ -295,263 +275,246 @@ func (a *App) handleGlobalKeys(event *tcell.EventKey) *tcell.EventKey {
 		currentFocus = a.queryInput
 	}
 
-	// CRITICAL: If focus is on results list, handle Up/Down keys FIRST
-	// This must be checked before any other processing to ensure arrow keys work
-	// tviewのListコンポーネントは上下キーで自動的に選択を移動するので、
-	// アプリケーション全体のInputCaptureで上下キーをそのまま返す必要がある
-	if currentFocus == a.resultsList {
-		// For Up/Down keys, MUST return event to let list handle them
-		// This is the most important check - must be first
-		if event.Key() == tcell.KeyUp || event.Key() == tcell.KeyDown {
-			return event // Let the list handle navigation
+	// Any key closes the help
+	if a.showHelp {
+		a.showHelp = false
+		a.previewText.SetTitle(" Preview ")
+		a.renderPreview()
+		return nil
+	}
+
+	key := a.keyName(event)
+	action := a.keys.Action(key)
+	if keymap.Printable(key) {
+		if input, ok := currentFocus.(*tview.InputField); ok && input.GetText() != "" {
+			action = ""
 		}
-		// Handle Enter key to open file
-		if event.Key() == tcell.KeyEnter {
-			currentIdx := a.resultsList.GetCurrentItem()
-			if currentIdx >= 0 && currentIdx < len(a.searchResults) {
-				result := a.searchResults[currentIdx]
-				if err := editor.OpenFile(a.editor, result.File, result.Line, result.Column); err != nil {
-					// Error opening editor
-				}
-				a.app.Stop()
-			}
-			return nil // Consume the event
+	}
+
+	switch action {
+	case keymap.ResultNext, keymap.ResultPrev:
+		// Move the selection without changing focus, so users can continue
+		// typing while navigating results
+		if len(a.rows) == 0 {
+			return event
 		}
-		// Handle j/k keys for vim-style navigation
-		if event.Key() == tcell.KeyRune {
-			if event.Rune() == 'j' || event.Rune() == 'J' {
-				// Move down
-				currentIdx := a.resultsList.GetCurrentItem()
-				if currentIdx < len(a.searchResults)-1 {
-					a.resultsList.SetCurrentItem(currentIdx + 1)
-				}
-				return nil
-			}
-			if event.Rune() == 'k' || event.Rune() == 'K' {
-				// Move up
-				currentIdx := a.resultsList.GetCurrentItem()
-				if currentIdx > 0 {
-					a.resultsList.SetCurrentItem(currentIdx - 1)
-				}
-				return nil
-			}
+		if action == keymap.ResultNext {
+			a.moveSelection(1)
+		} else {
+			a.moveSelection(-1)
 		}
-		// Allow Tab to move to query input
-		if event.Key() == tcell.KeyTab {
-			a.app.SetFocus(a.queryInput)
-			return nil
+		return nil
+
+	case keymap.Open:
+		if result := a.resultAt(max(a.selectedIndex, 0)); result != nil {
+			a.openResult(result)
 		}
-		// Allow Esc and Ctrl+C to quit
-		if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC {
-			if a.searchCancel != nil {
-				a.searchCancel()
-			}
-			a.app.Stop()
-			return nil
+		return nil
+
+	case keymap.Quit:
+		if a.searchCancel != nil {
+			a.searchCancel()
 		}
-		// Check for Alt+P/D
-		if event.Key() == tcell.KeyRune {
-			if event.Rune() == 'π' {
-				if a.gitRoot != "" && a.searchScope != "project" {
-					a.searchScope = "project"
-					a.updateScopeTabs()
-					a.triggerSearch()
-				}
-				return nil
-			}
-			if event.Rune() == '∂' {
-				if a.searchScope != "directory" {
-					a.searchScope = "directory"
-					a.updateScopeTabs()
-					a.triggerSearch()
-				}
-				return nil
-			}
+		a.app.Stop()
+		return nil
+
+	case keymap.FocusNext:
+		// Switch between query and mask input, or move to results list
+		switch {
+		case currentFocus == a.queryInput:
+			a.app.SetFocus(a.maskInput)
+		case currentFocus == a.maskInput && len(a.rows) > 0:
+			a.app.SetFocus(a.resultsList)
+		default:
+			a.app.SetFocus(a.queryInput)
 		}
-		// For all other keys, let the list handle them
-		return event
-	}
-
-	// If focus is on InputField, allow normal input processing
-	// Only intercept specific global shortcuts
-	if currentFocus == a.queryInput || currentFocus == a.maskInput {
-		// CRITICAL: If there are search results and user presses Up/Down,
-		// move selection in results list WITHOUT changing focus
-		// This allows users to continue typing while navigating results
-		if (event.Key() == tcell.KeyUp || event.Key() == tcell.KeyDown) && len(a.searchResults) > 0 {
-			// Get current selection index
-			currentIdx := a.selectedIndex
-			if currentIdx < 0 {
-				currentIdx = 0
-			}
+		return nil
 
-			// Move selection
-			var newIdx int
-			if event.Key() == tcell.KeyUp {
-				if currentIdx > 0 {
-					newIdx = currentIdx - 1
-				} else {
-					newIdx = 0
-				}
-			} else { // KeyDown
-				if currentIdx < len(a.searchResults)-1 {
-					newIdx = currentIdx + 1
-				} else {
-					newIdx = len(a.searchResults) - 1
-				}
-			}
+	case keymap.ScopeProject:
+		if a.gitRoot != "" {
+			a.setScope("project")
+		}
+		return nil
 
-			// Update selection in results list
-			a.resultsList.SetCurrentItem(newIdx)
-			a.selectedIndex = newIdx
+	case keymap.ScopeDirectory:
+		a.setScope("directory")
+		return nil
 
-			// Load preview for selected item
-			if newIdx >= 0 && newIdx < len(a.searchResults) {
-				a.loadPreview(a.searchResults[newIdx])
-			}
+	case keymap.ToggleGroup:
+		a.groupByFile = !a.groupByFile
+		a.updateResultsList()
+		return nil
 
-			// Consume the event so InputField doesn't process it
-			return nil
-		}
-		// Handle Enter key to open file when queryInput has focus
-		if event.Key() == tcell.KeyEnter && len(a.searchResults) > 0 {
-			currentIdx := a.selectedIndex
-			if currentIdx < 0 {
-				currentIdx = 0
-			}
-			if currentIdx >= 0 && currentIdx < len(a.searchResults) {
-				result := a.searchResults[currentIdx]
-				if err := editor.OpenFile(a.editor, result.File, result.Line, result.Column); err != nil {
-					// Error opening editor
-				}
-				a.app.Stop()
-			}
-			return nil // Consume the event
-		}
-		// Handle j/k keys for vim-style navigation when queryInput has focus
-		if event.Key() == tcell.KeyRune && len(a.searchResults) > 0 {
-			if event.Rune() == 'j' || event.Rune() == 'J' {
-				// Move down
-				currentIdx := a.selectedIndex
-				if currentIdx < 0 {
-					currentIdx = 0
-				}
-				if currentIdx < len(a.searchResults)-1 {
-					newIdx := currentIdx + 1
-					a.resultsList.SetCurrentItem(newIdx)
-					a.selectedIndex = newIdx
-					if newIdx >= 0 && newIdx < len(a.searchResults) {
-						a.loadPreview(a.searchResults[newIdx])
-					}
-				}
-				return nil // Consume the event
-			}
-			if event.Rune() == 'k' || event.Rune() == 'K' {
-				// Move up
-				currentIdx := a.selectedIndex
-				if currentIdx < 0 {
-					currentIdx = 0
-				}
-				if currentIdx > 0 {
-					newIdx := currentIdx - 1
-					a.resultsList.SetCurrentItem(newIdx)
-					a.selectedIndex = newIdx
-					if newIdx >= 0 && newIdx < len(a.searchResults) {
-						a.loadPreview(a.searchResults[newIdx])
-					}
-				}
-				return nil // Consume the event
-			}
+	case keymap.FileNext:
+		a.selectFile(1)
+		return nil
+
+	case keymap.FilePrev:
+		a.selectFile(-1)
+		return nil
+
+	case keymap.FileCollapse, keymap.FileExpand:
+		// In the inputs Left/Right move the cursor, so files are only
+		// collapsed and expanded from the results list
+		if currentFocus != a.resultsList || !a.groupByFile {
+			return event
 		}
-		// Allow Tab to switch between inputs
-		if event.Key() == tcell.KeyTab {
-			if currentFocus == a.queryInput {
-				a.app.SetFocus(a.maskInput)
+		if result := a.resultAt(a.selectedIndex); result != nil {
+			if action == keymap.FileCollapse {
+				a.collapsedFiles[result.File] = true
 			} else {
-				a.app.SetFocus(a.queryInput)
+				delete(a.collapsedFiles, result.File)
 			}
-			return nil
+			a.updateResultsList()
 		}
-		// Allow Esc and Ctrl+C to quit
-		if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC {
-			if a.searchCancel != nil {
-				a.searchCancel()
-			}
-			a.app.Stop()
-			return nil
+		return nil
+
+	case keymap.Help:
+		a.showHelp = true
+		a.renderHelp()
+		return nil
+	}
+
+	return event
+}
+
+// keyName returns the name of a key as the keymap knows it
+// macOS Option characters are only taken as Alt keys when the key is bound,
+// so the others can still be typed.
+func (a *App) keyName(event *tcell.EventKey) string {
+	mods := event.Modifiers()
+	var name string
+	switch key := event.Key(); key {
+	case tcell.KeyRune:
+		r := event.Rune()
+		if mods&tcell.ModAlt != 0 {
+			return "alt+" + string(unicode.ToLower(r))
 		}
-		// Check for Alt+P/D only if they are special characters (π/∂)
-		if event.Key() == tcell.KeyRune {
-			if event.Rune() == 'π' {
-				if a.gitRoot != "" && a.searchScope != "project" {
-					a.searchScope = "project"
-					a.updateScopeTabs()
-					a.triggerSearch()
-				}
-				return nil
-			}
-			if event.Rune() == '∂' {
-				if a.searchScope != "directory" {
-					a.searchScope = "directory"
-					a.updateScopeTabs()
-					a.triggerSearch()
-				}
-				return nil
-			}
+		if name, ok := keymap.OptionKey(r); ok && a.keys.Action(name) != "" {
+			return name
 		}
-		// For all other keys, let InputField handle them
-		return event
-	}
-
-	// For other components, handle global shortcuts
-	// Alt+P: Switch to project scope
-	if event.Key() == tcell.KeyRune {
-		if event.Rune() == 'π' || (event.Modifiers()&tcell.ModAlt != 0 && event.Rune() == 'p') {
-			if a.gitRoot != "" && a.searchScope != "project" {
-				a.searchScope = "project"
-				a.updateScopeTabs()
-				a.triggerSearch()
-			}
-			return nil
+		if r == ' ' && mods&tcell.ModCtrl != 0 {
+			return "ctrl+@"
 		}
-		// Alt+D: Switch to directory scope
-		if event.Rune() == '∂' || (event.Modifiers()&tcell.ModAlt != 0 && event.Rune() == 'd') {
-			if a.searchScope != "directory" {
-				a.searchScope = "directory"
-				a.updateScopeTabs()
-				a.triggerSearch()
-			}
-			return nil
+		return string(r)
+	case tcell.KeyCtrlSpace:
+		return "ctrl+@"
+	case tcell.KeyUp:
+		name = "up"
+	case tcell.KeyDown:
+		name = "down"
+	case tcell.KeyLeft:
+		name = "left"
+	case tcell.KeyRight:
+		name = "right"
+	case tcell.KeyHome:
+		name = "home"
+	case tcell.KeyEnd:
+		name = "end"
+	case tcell.KeyPgUp:
+		name = "pgup"
+	case tcell.KeyPgDn:
+		name = "pgdown"
+	case tcell.KeyDelete:
+		name = "delete"
+	case tcell.KeyEnter:
+		name = "enter"
+	case tcell.KeyTab:
+		name = "tab"
+	case tcell.KeyBacktab:
+		return "shift+tab"
+	case tcell.KeyEscape:
+		name = "esc"
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		name = "backspace"
+	default:
+		switch {
+		case key >= tcell.KeyF1 && key <= tcell.KeyF12:
+			name = "f" + strconv.Itoa(int(key-tcell.KeyF1)+1)
+		case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ:
+			return "ctrl+" + string(rune('a'+key-tcell.KeyCtrlA))
+		default:
+			return ""
 		}
 	}
+	if mods&tcell.ModShift != 0 {
+		name = "shift+" + name
+	}
+	if mods&tcell.ModCtrl != 0 {
+		name = "ctrl+" + name
+	}
+	if mods&tcell.ModAlt != 0 {
+		name = "alt+" + name
+	}
+	return name
+}
 
-	// Tab: Switch between query and mask input, or move to results list
-	if event.Key() == tcell.KeyTab {
-		if currentFocus == a.queryInput {
-			a.app.SetFocus(a.maskInput)
-		} else if currentFocus == a.maskInput {
-			// Move to results list if there are results
-			if len(a.searchResults) > 0 {
-				a.app.SetFocus(a.resultsList)
-			} else {
-				a.app.SetFocus(a.queryInput)
-			}
-		} else {
-			a.app.SetFocus(a.queryInput)
-		}
-		return nil
+// moveSelection moves the selection in the results list by delta rows
+func (a *App) moveSelection(delta int) {
+	newIdx := min(max(a.selectedIndex, 0)+delta, len(a.rows)-1)
+	newIdx = max(newIdx, 0)
+
+	// Update selection in results list
+	a.resultsList.SetCurrentItem(newIdx)
+	a.selectedIndex = newIdx
+
+	// Load preview for selected item
+	if result := a.resultAt(newIdx); result != nil {
+		a.loadPreview(result)
 	}
+}
 
-	// Esc or Ctrl+C: Quit
-	if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC {
-		if a.searchCancel != nil {
-			a.searchCancel()
+// setScope switches the search scope and searches again if it changed
+func (a *App) setScope(name string) {
+	if a.searchScope == name {
+		return
+	}
+	a.searchScope = name
+	a.updateScopeTabs()
+	a.triggerSearch()
+}
+
+// renderHelp shows the key bindings in the preview
+func (a *App) renderHelp() {
+	var lines []string
+	for _, g := range helpGroups(a.keys) {
+		keysWidth := 0
+		for _, b := range g.bindings {
+			keysWidth = max(keysWidth, len([]rune(b.keys)))
 		}
-		a.app.Stop()
-		return nil
+		lines = append(lines, "[yellow:black:b]"+g.name+"[white:black:-]")
+		for _, b := range g.bindings {
+			keys := b.keys + strings.Repeat(" ", keysWidth-len([]rune(b.keys)))
+			lines = append(lines, "  [aqua:black]"+tview.Escape(keys)+"[white:black]  "+b.description)
+		}
+		lines = append(lines, "")
 	}
+	lines = append(lines, "[gray:black]Press any key to close")
+	a.previewText.SetTitle(" Key bindings ")
+	a.previewText.SetText(strings.Join(lines, "\n")).ScrollToBeginning()
+}
 
-	return event
+// selectFile moves the selection to the first result of the next (delta=1)
+// or previous (delta=-1) file
+func (a *App) selectFile(delta int) {
+	current := a.resultAt(a.selectedIndex)
+	if current == nil {
+		return
+	}
+	groups := groupResults(a.searchResults)
+	for gi, group := range groups {
+		if group.file != current.File {
+			continue
+		}
+		target := gi + delta
+		if target < 0 || target >= len(groups) {
+			return
+		}
+		if pos := findRow(a.rows, a.searchResults, groups[target].indices[0], false); pos >= 0 {
+			a.resultsList.SetCurrentItem(pos)
+		}
+		return
+	}
 }
 
 // onQueryChanged is called when query input changes
@@ -585,11 +548,25 @@ func (a *App) onMaskCheckboxChanged(checked bool) {
 
 // onResultSelected is called when a result is selected (Enter)
 func (a *App) onResultSelected(index int, mainText, secondaryText string, shortcut rune) {
-	if index >= 0 && index < len(a.searchResults) {
-		result := a.searchResults[index]
-		if err := editor.OpenFile(a.editor, result.File, result.Line, result.Column); err != nil {
-			// Error opening editor
-		}
+	if result := a.resultAt(index); result != nil {
+		a.openResult(result)
+	}
+}
+
+// openResult opens result in the editor and stops the application unless it stays open
+// A terminal editor runs while the application is suspended.
+func (a *App) openResult(result *search.SearchResult) {
+	loc := editor.Location{File: result.Path(), Line: result.Line, Column: result.Column, ByteColumn: result.ByteColumn()}
+	if editor.IsTerminal(a.editor) {
+		a.app.Suspend(func() {
+			if err := editor.OpenFile(a.editor, loc); err != nil {
+				// Error opening editor
+			}
+		})
+	} else if err := editor.OpenFile(a.editor, loc); err != nil {
+		// Error opening editor
+	}
+	if !a.stayOpen {
 		a.app.Stop()
 	}
 }
@@ -597,8 +574,8 @@ func (a *App) onResultSelected(index int, mainText, secondaryText string, shortc
 // onResultChanged is called when result selection changes
 func (a *App) onResultChanged(index int, mainText, secondaryText string, shortcut rune) {
 	a.selectedIndex = index
-	if index >= 0 && index < len(a.searchResults) {
-		a.loadPreview(a.searchResults[index])
+	if result := a.resultAt(index); result != nil {
+		a.loadPreview(result)
 	} else {
 		a.selectedIndex = -1
 		a.previewText.Clear()
@@ -620,6 +597,9 @@ func (a *App) triggerSearch() {
 
 	// Reset state
 	a.selectedIndex = -1
+	a.searchResults = nil
+	a.rows = nil
+	a.collapsedFiles = make(map[string]bool)
 	a.resultsList.Clear()
 	a.previewText.Clear()
 	a.preview = nil
@@ -628,6 +608,7 @@ func (a *App) triggerSearch() {
 	// If query is empty, clear results
 	if a.query == "" {
 		a.searchResults = nil
+		a.rows = nil
 		a.isSearching = false
 		a.updateStatus()
 		return
@@ -641,31 +622,38 @@ func (a *App) triggerSearch() {
 
 // performSearch executes the actual search
 func (a *App) performSearch() {
+	opts, err := searchState{
+		query:       a.query,
+		mask:        a.mask,
+		maskEnabled: a.maskEnabled,
+		scope:       appScope(a.searchScope),
+		gitRoot:     a.gitRoot,
+		currentDir:  a.currentDir,
+	}.options()
+	if err != nil {
+		// Report an invalid file mask instead of letting rg fail on it
+		a.app.QueueUpdateDraw(func() {
+			a.searchError = err
+			a.isSearching = false
+			a.updateStatus()
+		})
+		return
+	}
+
 	a.isSearching = true
+	a.searchError = nil
 	a.updateStatus()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	a.searchCancel = cancel
 
-	// Determine search path
-	searchPath := a.currentDir
-	if a.searchScope == "project" && a.gitRoot != "" {
-		searchPath = a.gitRoot
-	}
-
-	// Determine mask
-	mask := a.mask
-	if !a.maskEnabled {
-		mask = ""
-	}
-
 	// Start search
-	resultChan := a.searcher.Search(ctx, a.query, mask, searchPath)
+	resultChan := a.searcher.Search(ctx, opts)
 
-	// Process results
+	// Process results as they stream in
 	go func() {
-		var results []*search.SearchResult
 		for resultMsg := range resultChan {
+			resultMsg := resultMsg
 			if resultMsg.Error != nil {
 				a.app.QueueUpdateDraw(func() {
 					a.searchError = resultMsg.Error
@@ -674,89 +662,200 @@ func (a *App) performSearch() {
 				})
 				return
 			}
-			results = append(results, resultMsg.Results...)
 			a.app.QueueUpdateDraw(func() {
-				a.searchResults = results
-				a.updateResultsList()
+				// Drop batches that arrive after a newer search started
+				if ctx.Err() != nil {
+					return
+				}
+				if len(resultMsg.Results) > 0 {
+					a.appendResults(resultMsg.Results)
+				}
+				if resultMsg.Done {
+					a.isSearching = false
+				}
 				a.updateStatus()
 				// Keep focus on queryInput so users can continue typing
 				// Arrow keys will move focus to resultsList when pressed
 			})
 		}
-
-		a.app.QueueUpdateDraw(func() {
-			a.isSearching = false
-			a.updateStatus()
-		})
 	}()
 }
 
-// updateResultsList updates the results list display
-func (a *App) updateResultsList() {
-	a.resultsList.Clear()
+// appendResults appends a batch of streamed results to the results list
+// without touching the current selection
+func (a *App) appendResults(results []*search.SearchResult) {
+	a.searchResults = append(a.searchResults, results...)
+
+	// Header rows of earlier files change their hit counts, so the grouped
+	// view is rebuilt; the flat view only needs the new rows
+	if a.groupByFile {
+		a.updateResultsList()
+		return
+	}
+
+	width := a.resultsListWidth()
+	for i, result := range results {
+		a.rows = append(a.rows, resultRow{file: result.File, resultIndex: len(a.searchResults) - len(results) + i})
+		a.resultsList.AddItem(formatAppResult(result, width), "", 0, nil)
+	}
 
+	// Auto-select first item if no selection
+	if a.selectedIndex < 0 && len(a.rows) > 0 {
+		a.selectedIndex = 0
+		a.resultsList.SetCurrentItem(0)
+		a.loadPreview(a.searchResults[0])
+	}
+}
+
+// resultsListWidth returns the width available for result lines
+func (a *App) resultsListWidth() int {
 	// Get terminal width for formatting
 	_, _, width, _ := a.resultsList.GetRect()
 	if width == 0 {
 		// Fallback if width not available
 		width = 80
 	}
+	return width
+}
 
-	for _, result := range a.searchResults {
-		// Format: code snippet | file:line (JetBrains style)
-		// Extract filename from path
-		fileParts := strings.Split(result.File, "/")
-		fileName := fileParts[len(fileParts)-1]
-		fileInfo := fileName + ":" + strconv.Itoa(result.Line)
-
-		// Calculate the actual width needed for file info
-		fileInfoWidth := len(fileInfo)
-
-		// Calculate available width for code snippet
-		// Reserve space for separator " | " (3 chars) and file info
-		codeWidth := width - fileInfoWidth - 3
-		if codeWidth < 10 {
-			codeWidth = 10
-			fileInfoWidth = width - codeWidth - 3
-		}
-
-		// Format code snippet (truncate if needed)
-		codeSnippet := result.Text
-		if len(codeSnippet) > codeWidth {
-			codeSnippet = codeSnippet[:codeWidth-3] + "..."
+// resultAt returns the result shown at the given list index
+// For a file header row it returns the first result of that file.
+func (a *App) resultAt(index int) *search.SearchResult {
+	if index < 0 || index >= len(a.rows) {
+		return nil
+	}
+	row := a.rows[index]
+	if row.isHeader() {
+		if i := firstResultInFile(a.searchResults, row.file); i >= 0 {
+			return a.searchResults[i]
 		}
+		return nil
+	}
+	return a.searchResults[row.resultIndex]
+}
 
-		// Calculate padding to align file info to the right edge
-		codeSnippetLen := len(codeSnippet)
-		separatorLen := 3 // " | "
-		totalUsed := codeSnippetLen + separatorLen + fileInfoWidth
-		padding := width - totalUsed
-		if padding < 0 {
-			padding = 0
+// updateResultsList updates the results list display
+// The selected row is kept on the same result (or file header) across rebuilds.
+func (a *App) updateResultsList() {
+	selected := -1
+	onHeader := false
+	if a.selectedIndex >= 0 && a.selectedIndex < len(a.rows) {
+		row := a.rows[a.selectedIndex]
+		onHeader = row.isHeader()
+		selected = row.resultIndex
+		if onHeader {
+			selected = firstResultInFile(a.searchResults, row.file)
 		}
+	}
 
-		// Combine: code snippet + separator + padding + file info
-		// Padding ensures file info is right-aligned to the edge
-		mainText := codeSnippet + " | " + strings.Repeat(" ", padding) + fileInfo
+	// Clearing the list fires the changed callback, which must not see stale rows
+	a.rows = nil
+	a.resultsList.Clear()
+	a.rows = buildRows(a.searchResults, a.groupByFile, a.collapsedFiles)
 
-		a.resultsList.AddItem(mainText, "", 0, nil)
-	}
-	// Set selection if valid
-	if len(a.searchResults) > 0 {
-		if a.selectedIndex >= 0 && a.selectedIndex < len(a.searchResults) {
-			a.resultsList.SetCurrentItem(a.selectedIndex)
+	width := a.resultsListWidth()
+	for _, row := range a.rows {
+		if row.isHeader() {
+			a.resultsList.AddItem(formatAppFileHeader(row), "", 0, nil)
+			continue
+		}
+		result := a.searchResults[row.resultIndex]
+		if a.groupByFile {
+			a.resultsList.AddItem(formatAppGroupedResult(result, width), "", 0, nil)
 		} else {
-			// Auto-select first item if no selection
-			a.selectedIndex = 0
-			a.resultsList.SetCurrentItem(0)
-			// Load preview for first item
-			a.loadPreview(a.searchResults[0])
+			a.resultsList.AddItem(formatAppResult(result, width), "", 0, nil)
 		}
-	} else {
+	}
+
+	// Set selection if valid
+	if len(a.rows) == 0 {
 		a.selectedIndex = -1
+		return
+	}
+	if pos := findRow(a.rows, a.searchResults, selected, onHeader); pos >= 0 {
+		a.selectedIndex = pos
+		a.resultsList.SetCurrentItem(pos)
+		return
+	}
+	// Auto-select first item if no selection
+	a.selectedIndex = 0
+	a.resultsList.SetCurrentItem(0)
+	// Load preview for first item
+	if result := a.resultAt(0); result != nil {
+		a.loadPreview(result)
 	}
 }
 
+// formatAppFileHeader formats a file header row of the grouped view
+func formatAppFileHeader(row resultRow) string {
+	marker := "▾"
+	if row.collapsed {
+		marker = "▸"
+	}
+	hits := "1 match"
+	if row.count != 1 {
+		hits = strconv.Itoa(row.count) + " matches"
+	}
+	return "[::b]" + marker + " " + tview.Escape(row.file) + "[::-]  " + hits
+}
+
+// formatAppGroupedResult formats a result row of the grouped view: code snippet | line
+func formatAppGroupedResult(result *search.SearchResult, width int) string {
+	lineInfo := strconv.Itoa(result.Line)
+	codeWidth := width - len(lineInfo) - 5 // indent (2) + separator " | " (3)
+	if codeWidth < 10 {
+		codeWidth = 10
+	}
+	codeSnippet := result.DisplayText()
+	if len(codeSnippet) > codeWidth {
+		codeSnippet = codeSnippet[:codeWidth-3] + "..."
+	}
+	padding := width - 2 - len(codeSnippet) - 3 - len(lineInfo)
+	if padding < 0 {
+		padding = 0
+	}
+	return "  " + codeSnippet + " | " + strings.Repeat(" ", padding) + lineInfo
+}
+
+// formatAppResult formats a result line for the results list
+func formatAppResult(result *search.SearchResult, width int) string {
+	// Format: code snippet | file:line (JetBrains style)
+	// Extract filename from path
+	fileParts := strings.Split(result.File, "/")
+	fileName := fileParts[len(fileParts)-1]
+	fileInfo := fileName + ":" + strconv.Itoa(result.Line)
+
+	// Calculate the actual width needed for file info
+	fileInfoWidth := len(fileInfo)
+
+	// Calculate available width for code snippet
+	// Reserve space for separator " | " (3 chars) and file info
+	codeWidth := width - fileInfoWidth - 3
+	if codeWidth < 10 {
+		codeWidth = 10
+		fileInfoWidth = width - codeWidth - 3
+	}
+
+	// Format code snippet (truncate if needed)
+	codeSnippet := result.DisplayText()
+	if len(codeSnippet) > codeWidth {
+		codeSnippet = codeSnippet[:codeWidth-3] + "..."
+	}
+
+	// Calculate padding to align file info to the right edge
+	codeSnippetLen := len(codeSnippet)
+	separatorLen := 3 // " | "
+	totalUsed := codeSnippetLen + separatorLen + fileInfoWidth
+	padding := width - totalUsed
+	if padding < 0 {
+		padding = 0
+	}
+
+	// Combine: code snippet + separator + padding + file info
+	// Padding ensures file info is right-aligned to the edge
+	return codeSnippet + " | " + strings.Repeat(" ", padding) + fileInfo
+}
+
 // updateStatus updates the status text
 func (a *App) updateStatus() {
 	if a.isSearching {
@@ -809,13 +908,14 @@ func (a *App) updateStatus() {
 
 // loadPreview loads preview for the selected result
 func (a *App) loadPreview(result *search.SearchResult) {
-	preview, err := preview.LoadPreview(result.File, result.Line)
+	preview, err := preview.LoadPreview(result.Path(), result.Line)
 	if err != nil {
 		a.previewError = err
 		a.previewText.SetText("Error loading preview: " + err.Error())
 		return
 	}
 
+	preview.Highlight(themes["default"].syntax)
 	a.preview = preview
 	a.renderPreview()
 }
@@ -831,6 +931,9 @@ func (a *App) renderPreview() {
 	// File path header
 	lines = append(lines, "[yellow:black:b]"+a.preview.File+"[white:black]")
 	lines = append(lines, "")
+	if a.preview.Binary {
+		lines = append(lines, "[gray:black]Binary file, no preview")
+	}
 
 	// Code lines with line numbers
 	for i, line := range a.preview.Lines {
@@ -840,9 +943,51 @@ func (a *App) renderPreview() {
 			// Highlight the hit line
 			lines = append(lines, "[white:blue]"+lineNumStr+"[white:black] | [yellow:black]"+line+"[white:black]")
 		} else {
-			lines = append(lines, "[gray:black]"+lineNumStr+"[white:black] | "+line)
+			var syntax []highlight.Span
+			if i < len(a.preview.Syntax) {
+				syntax = a.preview.Syntax[i]
+			}
+			lines = append(lines, "[gray:black]"+lineNumStr+"[white:black] | "+tviewCode(line, syntax))
 		}
 	}
 
 	a.previewText.SetText(strings.Join(lines, "\n"))
 }
+
+// tviewCode colors a line with its syntax spans using tview color tags
+func tviewCode(line string, syntax []highlight.Span) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range syntax {
+		if span.Start < last || span.End > len(line) {
+			continue
+		}
+		b.WriteString(tview.Escape(line[last:span.Start]))
+		flags := ""
+		if span.Style.Bold {
+			flags += "b"
+		}
+		if span.Style.Italic {
+			flags += "i"
+		}
+		if span.Style.Underline {
+			flags += "u"
+		}
+		color := span.Style.Color
+		if color == "" {
+			color = "-"
+		}
+		fmt.Fprintf(&b, "[%s::%s]%s[-::-]", color, flags, tview.Escape(line[span.Start:span.End]))
+		last = span.End
+	}
+	b.WriteString(tview.Escape(line[last:]))
+	return b.String()
+}
+
+// appScope converts the App's scope name to a scope
+func appScope(name string) scope.Scope {
+	if name == "project" {
+		return scope.Project()
+	}
+	return scope.Directory()
+}
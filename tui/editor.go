@@ -0,0 +1,133 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/search"
+)
+
+// openResult opens result in the editor
+// A terminal editor takes over the terminal: the TUI is suspended until it
+// exits. fif quits after opening the result unless it stays open; it also
+// stays when the editor can't be started, to report the error.
+func (m *Model) openResult(result *search.SearchResult) tea.Cmd {
+	m.editorError = nil
+	m.rememberSearch()
+	loc, err := resultLocation(result)
+	if err != nil {
+		m.editorError = err
+		return nil
+	}
+
+	if editor.IsTerminal(m.editor) {
+		cmd, err := editor.Command(m.editor, loc)
+		if err != nil {
+			m.editorError = err
+			return nil
+		}
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return editorFinishedMsg{Err: err}
+		})
+	}
+
+	if err := editor.OpenFile(m.editor, loc); err != nil {
+		m.editorError = err
+		return nil
+	}
+	if m.stayOpen {
+		return nil
+	}
+	return tea.Quit
+}
+
+// editorFinishedMsg is sent when a terminal editor exits
+type editorFinishedMsg struct {
+	Err error
+}
+
+// handleEditorFinished returns to the search after a terminal editor exits
+// The file may have been edited, so the search is refreshed in place.
+func (m *Model) handleEditorFinished(msg editorFinishedMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		m.editorError = msg.Err
+		return m, nil
+	}
+	if !m.stayOpen {
+		return m, tea.Quit
+	}
+	return m, m.refreshSearch()
+}
+
+// selectionAnchor identifies the selected result across a refresh of the results
+type selectionAnchor struct {
+	file           string
+	line           int
+	resultsOffset  int
+	headerSelected bool
+}
+
+// refreshSearch re-runs the current search right away
+// Query, mask, scope and collapsed files are kept, and the selected result
+// and scroll offset are restored once the new results contain it.
+func (m *Model) refreshSearch() tea.Cmd {
+	if m.query == "" {
+		return nil
+	}
+
+	var anchor *selectionAnchor
+	if m.selectedIndex >= 0 && m.selectedIndex < len(m.searchResults) {
+		result := m.searchResults[m.selectedIndex]
+		anchor = &selectionAnchor{
+			file:           result.File,
+			line:           result.Line,
+			resultsOffset:  m.resultsOffset,
+			headerSelected: m.headerSelected,
+		}
+	}
+	collapsed := m.collapsedFiles
+
+	if m.triggerSearch() == nil {
+		// The options became invalid; triggerSearch reported it
+		return nil
+	}
+	m.collapsedFiles = collapsed
+	m.restoreSelection = anchor
+
+	// Skip the debounce: nothing is being typed
+	_, cmd := m.handleStartSearch(startSearchMsg{Generation: m.searchGeneration})
+	return cmd
+}
+
+// restoreSelected selects the anchored result once it has been received
+// If the search finishes without it (the line was edited away), the first
+// result of the same file, or else the first result, is selected instead.
+func (m *Model) restoreSelected(done bool) tea.Cmd {
+	anchor := m.restoreSelection
+
+	index := -1
+	for i, result := range m.searchResults {
+		if result.File == anchor.file && result.Line == anchor.line {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if !done {
+			return nil
+		}
+		index = firstResultInFile(m.searchResults, anchor.file)
+		if index < 0 && len(m.searchResults) > 0 {
+			index = 0
+		}
+	}
+
+	m.restoreSelection = nil
+	if index < 0 {
+		return nil
+	}
+	m.selectedIndex = index
+	m.resultsOffset = anchor.resultsOffset
+	m.headerSelected = anchor.headerSelected && m.groupByFile
+	m.adjustScroll()
+	return m.loadPreview()
+}
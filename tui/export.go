@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/search"
+)
+
+// exportFilePattern is the pattern (without extension) of the names of the
+// files in the temporary directory results are exported to
+const exportFilePattern = "fif-results-*"
+
+// openExportPrompt asks for the format to export the results in
+// Each format is chosen with its first letter.
+func (m *Model) openExportPrompt() {
+	if len(m.searchResults) == 0 {
+		return
+	}
+	choices := make([]string, len(export.Formats))
+	for i, f := range export.Formats {
+		choices[i] = fmt.Sprintf("%c: %s", f[0], f)
+	}
+	m.exportPrompt = true
+	m.actionStatus = "Export as  " + strings.Join(choices, "  ") + "  Esc: cancel"
+}
+
+// handleExportKey processes the key choosing the export format
+func (m *Model) handleExportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.exportPrompt = false
+	m.actionStatus = ""
+	if len(msg.Runes) != 1 || msg.Alt {
+		return m, nil
+	}
+	for _, f := range export.Formats {
+		if msg.Runes[0] == rune(f[0]) {
+			m.exportResults(f)
+			break
+		}
+	}
+	return m, nil
+}
+
+// exportResults writes the marked results, or all of them, to a file in
+// the temporary directory
+// History results are skipped, as their files aren't in the working tree.
+func (m *Model) exportResults(f export.Format) {
+	results := m.markedResults()
+	if len(results) == 0 {
+		results = m.searchResults
+	}
+	results, skipped := workingTreeResults(results)
+	if len(results) == 0 {
+		m.actionStatus = "Export failed: history results have no file to export"
+		return
+	}
+	path, err := m.writeExport(exportFilePattern+f.Extension(), f, results, export.Options{Dir: m.exportDir(), Query: m.query})
+	if err != nil {
+		m.actionStatus = fmt.Sprintf("Export failed: %v", err)
+		return
+	}
+	m.actionStatus = fmt.Sprintf("Wrote %s to %s", search.Plural(len(results), "result", "results"), path)
+	if skipped > 0 {
+		m.actionStatus += fmt.Sprintf(", skipped %s", search.Plural(skipped, "history result", "history results"))
+	}
+}
+
+// exportDir returns the directory exported paths are relative to: the git
+// root, or the current directory outside a repository
+func (m *Model) exportDir() string {
+	if m.gitRoot != "" {
+		return m.gitRoot
+	}
+	return m.currentDir
+}
+
+// writeExport writes results in the format to a new file in the temporary
+// directory and returns its path
+// The file is named after pattern, as with os.CreateTemp.
+func (m *Model) writeExport(pattern string, f export.Format, results []*search.SearchResult, opts export.Options) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := export.Write(file, f, results, opts); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// workingTreeResults returns the results whose file is in the working tree
+// and the number of history results left out: their file only exists in a
+// revision, so there is no path to write for them.
+func workingTreeResults(results []*search.SearchResult) ([]*search.SearchResult, int) {
+	files := make([]*search.SearchResult, 0, len(results))
+	for _, result := range results {
+		if result.Revision == nil {
+			files = append(files, result)
+		}
+	}
+	return files, len(results) - len(files)
+}
@@ -0,0 +1,109 @@
+package tui
+
+import "github.com/takaishi/fif/search"
+
+// resultRow is a single row of the results list
+// In flat mode every row is a result; in grouped mode each file gets a
+// header row followed by its results unless it is collapsed.
+type resultRow struct {
+	file        string
+	resultIndex int  // Index into the results, or -1 for a file header row
+	count       int  // Number of hits in the file (header rows only)
+	collapsed   bool // Whether the file's results are hidden (header rows only)
+
+	revision *search.Revision // Commit of a history result's file (header rows only)
+}
+
+// isHeader reports whether the row is a file header
+func (r resultRow) isHeader() bool {
+	return r.resultIndex < 0
+}
+
+// fileGroup holds the indices of all results in one file
+type fileGroup struct {
+	file    string
+	indices []int
+}
+
+// groupResults groups results by file, in order of first appearance
+func groupResults(results []*search.SearchResult) []fileGroup {
+	var groups []fileGroup
+	groupIndex := make(map[string]int)
+	for i, result := range results {
+		gi, ok := groupIndex[result.File]
+		if !ok {
+			gi = len(groups)
+			groupIndex[result.File] = gi
+			groups = append(groups, fileGroup{file: result.File})
+		}
+		groups[gi].indices = append(groups[gi].indices, i)
+	}
+	return groups
+}
+
+// buildRows builds the rows of the results list for the given view mode
+func buildRows(results []*search.SearchResult, grouped bool, collapsed map[string]bool) []resultRow {
+	if !grouped {
+		rows := make([]resultRow, len(results))
+		for i, result := range results {
+			rows[i] = resultRow{file: result.File, resultIndex: i}
+		}
+		return rows
+	}
+
+	groups := groupResults(results)
+	rows := make([]resultRow, 0, len(results)+len(groups))
+	for _, group := range groups {
+		isCollapsed := collapsed[group.file]
+		rows = append(rows, resultRow{
+			file:        group.file,
+			resultIndex: -1,
+			count:       len(group.indices),
+			collapsed:   isCollapsed,
+			revision:    results[group.indices[0]].Revision,
+		})
+		if isCollapsed {
+			continue
+		}
+		for _, i := range group.indices {
+			rows = append(rows, resultRow{file: group.file, resultIndex: i})
+		}
+	}
+	return rows
+}
+
+// findRow returns the position of the row for the given selection
+// onHeader selects the header row of the selected result's file.
+// If the result is hidden in a collapsed file, its header row is returned.
+func findRow(rows []resultRow, results []*search.SearchResult, selectedIndex int, onHeader bool) int {
+	if selectedIndex < 0 || selectedIndex >= len(results) {
+		return -1
+	}
+	file := results[selectedIndex].File
+	headerPos := -1
+	for i, row := range rows {
+		if row.isHeader() {
+			if row.file == file {
+				headerPos = i
+				if onHeader || row.collapsed {
+					return i
+				}
+			}
+			continue
+		}
+		if row.resultIndex == selectedIndex {
+			return i
+		}
+	}
+	return headerPos
+}
+
+// firstResultInFile returns the index of the first result in the given file
+func firstResultInFile(results []*search.SearchResult, file string) int {
+	for i, result := range results {
+		if result.File == file {
+			return i
+		}
+	}
+	return -1
+}
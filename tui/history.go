@@ -0,0 +1,104 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/history"
+	"github.com/takaishi/fif/preview"
+	"github.com/takaishi/fif/search"
+)
+
+// historyMode selects whether the working tree or git history is searched
+type historyMode int
+
+const (
+	historyOff      historyMode = iota // Search the working tree with rg
+	historyRevision                    // Search a revision with git grep
+	historyPickaxe                     // List lines added or removed by commits with git log -S/-G
+)
+
+// label returns the name of the mode shown in the header
+func (h historyMode) label() string {
+	switch h {
+	case historyRevision:
+		return "Revision"
+	case historyPickaxe:
+		return "Pickaxe"
+	}
+	return "Working Tree"
+}
+
+// cycleHistory switches to the next history mode: working tree, revision, pickaxe
+// Searching history needs a git repository and excludes replace mode.
+func (m *Model) cycleHistory() tea.Cmd {
+	if m.gitRoot == "" {
+		return nil
+	}
+	m.history = (m.history + 1) % 3
+	if m.history == historyOff && m.inputMode == InputModeRev {
+		m.inputMode = InputModeQuery
+	}
+	if m.history != historyOff && m.replaceMode {
+		m.replaceMode = false
+		if m.inputMode == InputModeReplace {
+			m.inputMode = InputModeQuery
+		}
+	}
+	return m.triggerSearch()
+}
+
+// searchHistory starts a search of git history with opts
+func (m *Model) searchHistory(ctx context.Context, opts search.Options) <-chan search.SearchResultMsg {
+	mode, rev := m.history, m.rev
+	return m.searcher.Run(ctx, func(ctx context.Context) ([]*search.SearchResult, error) {
+		if mode == historyPickaxe {
+			return history.Pickaxe(ctx, rev, opts)
+		}
+		return history.Grep(ctx, rev, opts)
+	})
+}
+
+// loadRevisionPreview loads the preview of a history result from its revision
+func (m *Model) loadRevisionPreview(ctx context.Context, result *search.SearchResult) tea.Cmd {
+	before, after := m.previewWindow()
+	theme := m.syntaxTheme
+	return func() tea.Msg {
+		content, err := history.Show(ctx, result)
+		if err != nil {
+			return previewLoadedMsg{Result: result, Error: err}
+		}
+		p, err := preview.LoadPreviewReader(bytes.NewReader(content), result.File, result.Line, before, after)
+		if err == nil {
+			p.Highlight(theme)
+		}
+		return previewLoadedMsg{Result: result, Preview: p, Error: err}
+	}
+}
+
+// resultLocation returns the file and position to open for result
+// History results are written to a temporary file first.
+func resultLocation(result *search.SearchResult) (editor.Location, error) {
+	loc := editor.Location{File: result.Path(), Line: result.Line, Column: result.Column, ByteColumn: result.ByteColumn()}
+	if result.Revision != nil {
+		path, err := history.Extract(result)
+		if err != nil {
+			return editor.Location{}, err
+		}
+		loc.File = path
+	}
+	return loc, nil
+}
+
+// revisionInfo describes the commit of a history result next to its object
+// name, which already has the hash: author and date
+func revisionInfo(rev *search.Revision) string {
+	info := fmt.Sprintf("%s, %s", rev.Author, rev.Date.Format("2006-01-02"))
+	if rev.Removed {
+		info = "removed by " + rev.Short() + " " + info
+	}
+	return info
+}
@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/takaishi/fif/keymap"
+)
+
+// helpGroup is a group of actions in the key bindings help
+type helpGroup struct {
+	name     string
+	bindings []helpBinding
+}
+
+// helpBinding is a line of the key bindings help
+type helpBinding struct {
+	keys        string // Keys bound to the action, as displayed
+	description string
+}
+
+// helpGroups returns the bound actions of a keymap, by group
+func helpGroups(km *keymap.Keymap) []helpGroup {
+	var groups []helpGroup
+	for _, b := range km.Help() {
+		if len(groups) == 0 || groups[len(groups)-1].name != b.Group {
+			groups = append(groups, helpGroup{name: b.Group})
+		}
+		keys := make([]string, len(b.Keys))
+		for i, key := range b.Keys {
+			keys[i] = keymap.Display(key)
+		}
+		g := &groups[len(groups)-1]
+		g.bindings = append(g.bindings, helpBinding{keys: strings.Join(keys, ", "), description: b.Description})
+	}
+	return groups
+}
+
+// renderHelp renders the key bindings in place of the results and preview
+// Groups are laid out in as many columns as the height requires.
+func renderHelp(m *Model, height int) string {
+	visible := height - 4 // Border, blank line and footer
+	if visible < 1 {
+		visible = 1
+	}
+
+	var columns [][]string
+	var column []string
+	for _, g := range helpGroups(m.keys) {
+		keysWidth := 0
+		for _, b := range g.bindings {
+			keysWidth = max(keysWidth, lipgloss.Width(b.keys))
+		}
+		lines := []string{fileHeaderStyle.Render(g.name)}
+		for _, b := range g.bindings {
+			key := highlightStyle.Render(b.keys) + strings.Repeat(" ", keysWidth-lipgloss.Width(b.keys))
+			lines = append(lines, "  "+key+"  "+resultStyle.Render(b.description))
+		}
+
+		// Start a new column when the group doesn't fit below the previous one
+		if len(column) > 0 && len(column)+1+len(lines) > visible {
+			columns = append(columns, column)
+			column = nil
+		}
+		if len(column) > 0 {
+			column = append(column, "")
+		}
+		column = append(column, lines...)
+	}
+	if len(column) > 0 {
+		columns = append(columns, column)
+	}
+
+	blocks := make([]string, 0, 2*len(columns))
+	for i, c := range columns {
+		if i > 0 {
+			blocks = append(blocks, "    ")
+		}
+		blocks = append(blocks, lipgloss.JoinVertical(lipgloss.Left, c...))
+	}
+	body := lipgloss.JoinHorizontal(lipgloss.Top, blocks...)
+	footer := statusStyle.Render("Key bindings (" + m.keys.Preset() + " keymap) | Press any key to close")
+	return previewStyle.Width(m.width - 2).Render(lipgloss.JoinVertical(lipgloss.Left, body, "", footer))
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
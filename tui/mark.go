@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/search"
+)
+
+// quickfixFilePattern is the pattern of the names of the files in the
+// temporary directory that marked results are written to, for `vim -q`
+const quickfixFilePattern = "fif-quickfix-*.txt"
+
+// toggleMark marks or unmarks the selected result
+// With a file header selected in grouped view, all results of the file are
+// toggled together.
+func (m *Model) toggleMark() {
+	result := m.selectedResult()
+	if result == nil {
+		return
+	}
+	if !m.headerSelected {
+		if m.marked[result] {
+			delete(m.marked, result)
+		} else {
+			m.marked[result] = true
+		}
+		return
+	}
+
+	var results []*search.SearchResult
+	allMarked := true
+	for _, r := range m.searchResults {
+		if r.File == result.File {
+			results = append(results, r)
+			allMarked = allMarked && m.marked[r]
+		}
+	}
+	m.setMarked(results, !allMarked)
+}
+
+// toggleMarkAll marks every visible result, or clears the marks if they all are marked
+// Results of collapsed files in grouped view are not visible.
+func (m *Model) toggleMarkAll() {
+	var visible []*search.SearchResult
+	allMarked := true
+	for _, row := range m.rows() {
+		if row.isHeader() {
+			continue
+		}
+		result := m.searchResults[row.resultIndex]
+		visible = append(visible, result)
+		allMarked = allMarked && m.marked[result]
+	}
+	if allMarked {
+		m.marked = make(map[*search.SearchResult]bool)
+		return
+	}
+	m.setMarked(visible, true)
+}
+
+// setMarked marks or unmarks results
+func (m *Model) setMarked(results []*search.SearchResult, marked bool) {
+	for _, result := range results {
+		if marked {
+			m.marked[result] = true
+		} else {
+			delete(m.marked, result)
+		}
+	}
+}
+
+// markedResults returns the marked results in result order
+func (m *Model) markedResults() []*search.SearchResult {
+	var results []*search.SearchResult
+	for _, result := range m.searchResults {
+		if m.marked[result] {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// targetResults returns the results an action applies to: the marked ones,
+// or the selected one when nothing is marked
+func (m *Model) targetResults() []*search.SearchResult {
+	if len(m.marked) > 0 {
+		return m.markedResults()
+	}
+	if result := m.selectedResult(); result != nil {
+		return []*search.SearchResult{result}
+	}
+	return nil
+}
+
+// openMarked opens the first marked result of each file in the editor
+// GUI editors get them all at once; terminal editors are run once per file,
+// one after the other.
+func (m *Model) openMarked() tea.Cmd {
+	m.editorError = nil
+	m.rememberSearch()
+
+	var locations []editor.Location
+	seen := make(map[string]bool)
+	for _, result := range m.markedResults() {
+		if seen[result.File] {
+			continue
+		}
+		seen[result.File] = true
+		loc, err := resultLocation(result)
+		if err != nil {
+			m.editorError = err
+			return nil
+		}
+		locations = append(locations, loc)
+	}
+
+	if !editor.IsTerminal(m.editor) {
+		if err := editor.OpenFiles(m.editor, locations); err != nil {
+			m.editorError = err
+			return nil
+		}
+		if m.stayOpen {
+			return nil
+		}
+		return tea.Quit
+	}
+
+	cmds := make([]tea.Cmd, 0, len(locations))
+	for i, loc := range locations {
+		cmd, err := editor.Command(m.editor, loc)
+		if err != nil {
+			m.editorError = err
+			return nil
+		}
+		last := i == len(locations)-1
+		cmds = append(cmds, tea.ExecProcess(cmd, func(err error) tea.Msg {
+			// Only the last editor ends the batch; earlier ones just report failures
+			if last || err != nil {
+				return editorFinishedMsg{Err: err}
+			}
+			return nil
+		}))
+	}
+	return tea.Sequence(cmds...)
+}
+
+// copyLocations copies the file:line of the marked (or selected) results to
+// the clipboard with an OSC 52 escape sequence, which works over SSH too
+func (m *Model) copyLocations() {
+	results := m.targetResults()
+	if len(results) == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(results))
+	for _, result := range results {
+		lines = append(lines, fmt.Sprintf("%s:%d", result.RelPath(m.currentDir), result.Line))
+	}
+
+	seq := osc52.New(strings.Join(lines, "\n") + "\n")
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	// Bubble Tea renders to stdout; stderr reaches the same terminal
+	if _, err := seq.WriteTo(os.Stderr); err != nil {
+		m.actionStatus = fmt.Sprintf("Copy failed: %v", err)
+		return
+	}
+	m.actionStatus = fmt.Sprintf("Copied %s", search.Plural(len(lines), "location", "locations"))
+}
+
+// writeQuickfix writes the marked (or selected) results to a Vim quickfix
+// file in the temporary directory
+// History results are skipped, as their files aren't in the working tree.
+func (m *Model) writeQuickfix() {
+	results, skipped := workingTreeResults(m.targetResults())
+	if len(results) == 0 {
+		if skipped > 0 {
+			m.actionStatus = "Quickfix failed: history results have no file to jump to"
+		}
+		return
+	}
+
+	// Absolute paths, so the file can be loaded from any directory
+	path, err := m.writeExport(quickfixFilePattern, export.Quickfix, results, export.Options{Query: m.query})
+	if err != nil {
+		m.actionStatus = fmt.Sprintf("Quickfix failed: %v", err)
+		return
+	}
+	m.actionStatus = fmt.Sprintf("Wrote %s to %s (vim -q)", search.Plural(len(results), "location", "locations"), path)
+	if skipped > 0 {
+		m.actionStatus += fmt.Sprintf(", skipped %s", search.Plural(skipped, "history result", "history results"))
+	}
+}
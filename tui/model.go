@@ -2,17 +2,27 @@ package tui
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"time"
+	"unicode"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/takaishi/fif/config"
 	"github.com/takaishi/fif/editor"
+	"github.com/takaishi/fif/keymap"
 	"github.com/takaishi/fif/preview"
+	"github.com/takaishi/fif/scope"
 	"github.com/takaishi/fif/search"
+	"github.com/takaishi/fif/searches"
 )
 
 const (
+	noSearch = -1 // searchID while no search is shown; the searcher's IDs start at 1
+
 	debounceDuration   = 250 * time.Millisecond
 	escSequenceTimeout = 100 * time.Millisecond // Timeout for ESC sequence detection
 )
@@ -23,6 +33,8 @@ type InputMode int
 const (
 	InputModeQuery InputMode = iota
 	InputModeMask
+	InputModeReplace
+	InputModeRev
 )
 
 // Model represents the application state
@@ -35,30 +47,101 @@ type Model struct {
 	queryInput  textInput
 	maskInput   textInput
 
+	// Git history search (toggled with Alt+L)
+	history  historyMode
+	rev      string // Revision searched, or the history the pickaxe looks at (empty means HEAD)
+	revInput textInput
+
+	// Search modes (toggled with Alt+X / Alt+C / Alt+W)
+	regexMode bool            // Treat the query as a regular expression
+	caseMode  search.CaseMode // Match case: smart, sensitive or insensitive
+	wholeWord bool            // Only match whole words
+
+	// Settings from the config file
+	debounce      time.Duration  // Delay between typing and starting a search
+	hidden        bool           // Search hidden files and directories
+	rgArgs        []string       // Extra arguments passed to rg
+	maxFilesize   string         // Skip files larger than this (empty means no limit)
+	previewBefore int            // Lines shown before the hit in the preview
+	previewAfter  int            // Lines shown after the hit in the preview
+	previewFit    bool           // Size the context to the preview pane
+	syntaxTheme   string         // Syntax highlighting theme of the preview ("none" for plain text)
+	keys          *keymap.Keymap // Action of each key
+
 	// Search state
-	searcher      *search.Searcher
-	searchCancel  context.CancelFunc
-	searchResults []*search.SearchResult
-	selectedIndex int
-	resultsOffset int // Scroll offset for results list
-	isSearching   bool
-	searchError   error
+	searcher         *search.Searcher
+	searchCancel     context.CancelFunc
+	searchGeneration int                           // Incremented on every search request to drop stale debounced requests
+	searchID         int64                         // ID of the search whose results are being shown
+	resultChan       <-chan search.SearchResultMsg // Channel delivering batches of the running search
+	searchResults    []*search.SearchResult
+	selectedIndex    int
+	resultsOffset    int // Scroll offset for results list, in rows
+	isSearching      bool
+	searchError      error
+	skipped          search.Skipped // What the last search left out
+
+	// Marked results (Ctrl+Space / Ctrl+A), acted on as a set
+	marked       map[*search.SearchResult]bool
+	actionStatus string // Outcome of the last copy / quickfix / export action
+	exportPrompt bool   // Waiting for the key choosing the export format
+
+	// Grouped view (toggled with Alt+G)
+	groupByFile    bool            // Show one header row per file with its results below
+	collapsedFiles map[string]bool // Files whose results are hidden in grouped view
+	headerSelected bool            // Whether the file header of the selected result is selected
+
+	// Replace mode (toggled with Alt+R)
+	replaceMode      bool
+	replaceInput     textInput
+	replacement      string
+	replaceDecisions map[*search.SearchResult]replaceDecision
+	replaceStatus    string               // Outcome of the last replace action
+	replaceError     error                // Error of the last replace action
+	fileStamps       map[string]time.Time // Modification time of files fif has written
+	lastSearch       search.Options       // Options of the search whose results are shown
+	searchStartedAt  time.Time            // When the search whose results are shown started
 
 	// Preview state
-	preview      *preview.Preview
-	previewError error
+	preview       *preview.Preview
+	previewResult *search.SearchResult // Result the preview was loaded for
+	previewError  error
+	previewCancel context.CancelFunc // Cancels the preview being loaded
+
+	// Preview viewport
+	previewTop          int // First file line shown, or 0 to place the viewport at the hit
+	previewGrow         int // Lines added to (or removed from) each side of the context with Alt+E / Alt+T
+	previewExtendBefore int // Lines loaded beyond the context by scrolling up
+	previewExtendAfter  int // Lines loaded beyond the context by scrolling down
 
 	// Editor
-	editor editor.Editor
+	editor           editor.Editor
+	stayOpen         bool                   // Keep running after opening a result
+	pickMode         bool                   // Enter chooses results for the caller instead of opening them
+	picked           []*search.SearchResult // Results chosen in pick mode
+	editorError      error                  // Error of the last attempt to open a result
+	restoreSelection *selectionAnchor       // Result to select again when a refreshed search delivers it
 
 	// Search scope
-	searchScope string // "project" or "directory"
-	gitRoot     string // Git repository root path
-	currentDir  string // Current working directory
+	scope         scope.Scope
+	namedScopes   []scope.Scope  // Named scopes from the config file, by name
+	gitScopes     []scope.Scope  // Git-backed scopes, in the order Alt+H cycles through them
+	picker        *dirPicker     // Directory picker, while it is open
+	scopeSettings scope.Settings // Configured defaults of scope specs in saved searches and the history
+	gitRoot       string         // Git repository root path
+	currentDir    string         // Current working directory
+
+	// Search history (Up/Down on an empty query, Ctrl+R)
+	searchLog      *searches.History // nil when the history can't be read
+	recallSelected int               // Selected entry of the recent searches list
+	recall         *recallPicker     // History search, while it is open
+	startSearch    *searches.Entry   // Search to run on startup (command line arguments, --saved)
 
 	// ESC sequence handling (for Alt key detection in some terminals)
 	waitingForEscSequence bool
 
+	showHelp bool // Whether the key bindings are shown in place of the results and preview
+
 	// UI dimensions
 	width  int
 	height int
@@ -77,20 +160,40 @@ func New() *Model {
 	gitRoot, isGitRepo := search.GetCurrentGitRoot()
 	currentDir, _ := os.Getwd()
 
-	searchScope := "directory" // Default to current directory
-	if isGitRepo {
-		searchScope = "project" // If in git repo, default to project scope
+	if !isGitRepo {
+		gitRoot = ""
 	}
+	// In a git repository the default is project scope, otherwise the current directory
+	initialScope := scope.Default(scope.Env{GitRoot: gitRoot, CurrentDir: currentDir})
+
+	// The history is kept per repository, or per directory outside one
+	historyRoot := gitRoot
+	if historyRoot == "" {
+		historyRoot = currentDir
+	}
+	searchLog, _ := searches.Open(historyRoot)
 
 	return &Model{
-		searcher:      search.NewSearcher(),
-		editor:        ed,
-		inputMode:     InputModeQuery,
-		selectedIndex: -1,
-		searchScope:   searchScope,
-		gitRoot:       gitRoot,
-		currentDir:    currentDir,
-		maskEnabled:   true, // Default: mask is enabled
+		searcher:         search.NewSearcher(),
+		editor:           ed,
+		inputMode:        InputModeQuery,
+		selectedIndex:    -1,
+		scope:            initialScope,
+		gitScopes:        scope.GitScopes("", scope.DefaultCommits),
+		searchLog:        searchLog,
+		gitRoot:          gitRoot,
+		currentDir:       currentDir,
+		maskEnabled:      true, // Default: mask is enabled
+		debounce:         debounceDuration,
+		previewBefore:    preview.DefaultBefore,
+		previewAfter:     preview.DefaultAfter,
+		previewFit:       true,
+		syntaxTheme:      themes["default"].syntax,
+		keys:             keymap.Default(),
+		collapsedFiles:   make(map[string]bool),
+		marked:           make(map[*search.SearchResult]bool),
+		replaceDecisions: make(map[*search.SearchResult]replaceDecision),
+		fileStamps:       make(map[string]time.Time),
 	}
 }
 
@@ -99,9 +202,78 @@ func (m *Model) SetEditor(ed editor.Editor) {
 	m.editor = ed
 }
 
+// ApplyConfig applies the settings of the config file
+// It fails if the key bindings or the theme are invalid.
+func (m *Model) ApplyConfig(cfg *config.Config) error {
+	keys, err := keymap.New(cfg.Keymap, cfg.Keys)
+	if err != nil {
+		return err
+	}
+	if err := SetTheme(cfg.Theme); err != nil {
+		return err
+	}
+
+	m.editor = cfg.Editor
+	m.stayOpen = cfg.StayOpen
+	m.pickMode = cfg.Pick
+	m.keys = keys
+	m.debounce = cfg.Debounce
+	m.hidden = cfg.Hidden
+	m.rgArgs = cfg.RgArgs
+	m.maxFilesize = cfg.MaxFilesize
+	m.previewBefore = cfg.PreviewBefore
+	m.previewAfter = cfg.PreviewAfter
+	m.previewFit = cfg.PreviewFit
+	m.syntaxTheme = cfg.SyntaxTheme
+	if m.syntaxTheme == "" {
+		m.syntaxTheme = themes[cfg.Theme].syntax
+	}
+	m.mask = cfg.Mask
+	m.maskInput.value = cfg.Mask
+
+	// Named scope roots are relative to the git root, or the current directory outside a repository
+	base := m.currentDir
+	if m.gitRoot != "" {
+		base = m.gitRoot
+	}
+	m.namedScopes = nil
+	for _, name := range sortedKeys(cfg.Scopes) {
+		m.namedScopes = append(m.namedScopes, scope.Named(name, scope.AbsRoots(base, cfg.Scopes[name])))
+	}
+
+	// Without a configured base the diff scope compares against the detected default branch
+	gitBase := cfg.GitBase
+	if gitBase == "" && m.gitRoot != "" {
+		gitBase = scope.DefaultBase(m.gitRoot)
+	}
+	m.gitScopes = scope.GitScopes(gitBase, cfg.GitCommits)
+	m.scopeSettings = cfg.ScopeSettings()
+
+	// The start search is applied in Init; an invalid scope is reported now
+	m.startSearch = cfg.Start
+	if m.startSearch != nil {
+		s, err := scope.Parse(m.startSearch.Scope, m.startSearch.Roots, m.scopeEnv(), m.scopeSettings)
+		if err != nil {
+			return err
+		}
+		if s.IsGit() && m.gitRoot == "" {
+			return fmt.Errorf("scope %q needs a git repository", m.startSearch.Scope)
+		}
+	}
+	return nil
+}
+
 // Init initializes the model
+// It runs the search given on the command line, if any, without waiting
+// for the debounce.
 func (m *Model) Init() tea.Cmd {
-	return nil
+	if m.startSearch == nil || m.applyEntry(*m.startSearch) == nil {
+		return nil
+	}
+	generation := m.searchGeneration
+	return func() tea.Msg {
+		return startSearchMsg{Generation: generation}
+	}
 }
 
 // Update handles messages and updates the model
@@ -110,6 +282,11 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		if m.previewFit {
+			// The context follows the size of the pane
+			m.previewTop = 0
+			return m, m.loadPreview()
+		}
 		return m, nil
 
 	case tea.KeyMsg:
@@ -124,14 +301,17 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case startSearchMsg:
 		return m.handleStartSearch(msg)
 
+	case dirsLoadedMsg:
+		return m.handleDirsLoaded(msg)
+
+	case editorFinishedMsg:
+		return m.handleEditorFinished(msg)
+
 	case escTimeoutMsg:
-		// ESC sequence timeout - treat as ESC key (quit)
+		// ESC sequence timeout - run the action of the ESC key (quit by default)
 		if m.waitingForEscSequence {
 			m.waitingForEscSequence = false
-			if m.searchCancel != nil {
-				m.searchCancel()
-			}
-			return m, tea.Quit
+			return m, m.runAction(m.keys.Action("esc"))
 		}
 		return m, nil
 
@@ -155,308 +335,329 @@ func (m *Model) Start() error {
 	return err
 }
 
-// handleKey processes keyboard input
-func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	keyStr := msg.String()
-
-	// FIRST: Check for special characters that represent Alt key sequences
-	// macOS sends Option+P as π (U+03C0) and Option+D as ∂ (U+2202)
-	// This must be checked BEFORE any other processing
-	//
-	// IMPORTANT: On macOS, when Option+P is pressed, the terminal sends
-	// the π character (U+03C0) as a regular rune WITHOUT the Alt modifier flag.
-	// This is macOS's standard behavior - Option key acts as a character modifier,
-	// not as a Meta key. We must intercept this character before it reaches
-	// the text input handler.
-	if len(msg.Runes) > 0 {
-		runeChar := msg.Runes[0]
-		// π (U+03C0) = Option+P on macOS
-		if runeChar == 'π' || runeChar == 0x03C0 {
-			// Alt+P: Switch to project scope
-			if m.gitRoot != "" {
-				if m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-			}
-			// Even if already in project scope, return to prevent text input
-			return m, nil
+// keyName returns the name of a key as the keymap knows it
+// Some terminals set the Alt modifier, some send ESC before the key, and
+// macOS sends special characters for Option+<key>. Option characters are
+// only taken as Alt keys when the key is bound, so the others can still be
+// typed.
+func (m *Model) keyName(msg tea.KeyMsg) string {
+	if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
+		r := msg.Runes[0]
+		if key, ok := keymap.OptionKey(r); ok && !msg.Alt && m.keys.Action(key) != "" {
+			return key
 		}
-		// ∂ (U+2202) = Option+D on macOS
-		if runeChar == '∂' || runeChar == 0x2202 {
-			// Alt+D: Switch to directory scope
-			if m.searchScope != "directory" {
-				m.searchScope = "directory"
-				return m, m.triggerSearch()
-			}
-			// Even if already in directory scope, return to prevent text input
-			return m, nil
+		if msg.Alt || m.waitingForEscSequence {
+			return "alt+" + string(unicode.ToLower(r))
 		}
 	}
+	name := msg.String()
+	if m.waitingForEscSequence && msg.Type != tea.KeyEscape && !msg.Alt {
+		return "alt+" + name
+	}
+	return name
+}
 
-	// Also check keyStr for π/∂ (in case Runes is empty but String contains it)
-	if keyStr == "π" || keyStr == "∂" {
-		if keyStr == "π" {
-			if m.gitRoot != "" {
-				if m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-			}
-			return m, nil
-		}
-		if keyStr == "∂" {
-			if m.searchScope != "directory" {
-				m.searchScope = "directory"
-				return m, m.triggerSearch()
-			}
-			return m, nil
-		}
+// handleKey processes keyboard input
+func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// The directory picker, the history search and the export prompt take
+	// all keys while they are open
+	if m.picker != nil {
+		return m.handlePickerKey(msg)
+	}
+	if m.recall != nil {
+		return m.handleRecallPickerKey(msg)
+	}
+	if m.exportPrompt {
+		return m.handleExportKey(msg)
+	}
+	// Any key closes the help
+	if m.showHelp {
+		m.showHelp = false
+		return m, nil
 	}
 
-	// Check for Alt key combinations
-	// Some terminals send Alt+P as ESC followed by 'p', so we check both
-	// the string representation and the Alt modifier
-	if msg.Alt {
-		// Alt key is pressed, check which key
-		if len(msg.Runes) > 0 {
-			runeChar := msg.Runes[0]
-			if runeChar == 'p' || runeChar == 'P' {
-				// Alt+P: Switch to project scope
-				if m.gitRoot != "" && m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-				return m, nil
-			}
-			if runeChar == 'd' || runeChar == 'D' {
-				// Alt+D: Switch to directory scope
-				if m.searchScope != "directory" {
-					m.searchScope = "directory"
-					return m, m.triggerSearch()
-				}
-				return m, nil
-			}
-		}
+	key := m.keyName(msg)
+	m.waitingForEscSequence = false
+	if key == "esc" {
+		// ESC might be the start of an Alt key sequence: wait for the next
+		// key, and run the action of ESC if none comes within the timeout
+		m.waitingForEscSequence = true
+		return m, tea.Tick(escSequenceTimeout, func(time.Time) tea.Msg {
+			return escTimeoutMsg{}
+		})
 	}
 
-	switch keyStr {
-	case "ctrl+c":
+	// Keys that type a character only act while there is nothing to edit
+	action := m.keys.Action(key)
+	if keymap.Printable(key) && m.activeInput().value != "" {
+		action = ""
+	}
+
+	// Up, Down and Enter browse the search history while the query is empty
+	if cmd, ok := m.handleRecallKey(action); ok {
+		return m, cmd
+	}
+	if action != "" {
+		return m, m.runAction(action)
+	}
+	// Other Alt keys are ignored so they are not treated as text input
+	if strings.HasPrefix(key, "alt+") {
+		return m, nil
+	}
+	return m.handleTextInput(msg)
+}
+
+// runAction performs an action of the keymap
+func (m *Model) runAction(action keymap.Action) tea.Cmd {
+	switch action {
+	case keymap.Quit:
+		m.rememberSearch()
 		if m.searchCancel != nil {
 			m.searchCancel()
 		}
-		return m, tea.Quit
+		return tea.Quit
 
-	case "esc":
-		// ESC key might be the start of an Alt key sequence
-		// Set flag to wait for next key with timeout
-		m.waitingForEscSequence = true
-		// Set timeout - if no key comes within timeout, treat as ESC (quit)
-		return m, tea.Tick(escSequenceTimeout, func(time.Time) tea.Msg {
-			return escTimeoutMsg{}
-		})
+	case keymap.Help:
+		m.showHelp = true
+		return nil
+
+	case keymap.HistorySearch:
+		m.openRecallPicker()
+		return nil
 
-	case "tab":
-		// Switch between query and mask input
-		if m.inputMode == InputModeQuery {
+	case keymap.FocusNext:
+		// Switch between query, replacement (in replace mode), mask and
+		// revision (in history mode) input
+		switch {
+		case m.inputMode == InputModeQuery && m.replaceMode:
+			m.inputMode = InputModeReplace
+		case m.inputMode == InputModeQuery || m.inputMode == InputModeReplace:
 			m.inputMode = InputModeMask
-		} else {
+		case m.inputMode == InputModeMask && m.history != historyOff:
+			m.inputMode = InputModeRev
+		default:
 			m.inputMode = InputModeQuery
 		}
-		return m, nil
+		return nil
 
-	case "alt+p", "alt+P":
-		// Switch to project scope (git repository)
-		if m.gitRoot != "" && m.searchScope != "project" {
-			m.searchScope = "project"
-			// Trigger new search with new scope
-			return m, m.triggerSearch()
+	case keymap.ResultPrev:
+		rows := m.rows()
+		if pos := m.selectedRow(rows); pos > 0 {
+			return m.selectRow(rows, pos-1)
 		}
-		return m, nil
+		return nil
 
-	case "alt+d", "alt+D":
-		// Switch to directory scope (current directory)
-		if m.searchScope != "directory" {
-			m.searchScope = "directory"
-			// Trigger new search with new scope
-			return m, m.triggerSearch()
+	case keymap.ResultNext:
+		rows := m.rows()
+		if pos := m.selectedRow(rows); pos < len(rows)-1 {
+			return m.selectRow(rows, pos+1)
 		}
-		return m, nil
+		return nil
 
-	case "up", "k":
-		if m.selectedIndex > 0 {
-			m.selectedIndex--
+	case keymap.FileCollapse:
+		// Collapse the selected file (grouped view only)
+		if m.groupByFile && m.selectedIndex >= 0 && m.selectedIndex < len(m.searchResults) {
+			m.collapsedFiles[m.searchResults[m.selectedIndex].File] = true
+			m.headerSelected = true
 			m.adjustScroll()
-			return m, m.loadPreview()
 		}
-		return m, nil
+		return nil
 
-	case "down", "j":
-		if m.selectedIndex < len(m.searchResults)-1 {
-			m.selectedIndex++
+	case keymap.FileExpand:
+		// Expand the selected file (grouped view only)
+		if m.groupByFile && m.selectedIndex >= 0 && m.selectedIndex < len(m.searchResults) {
+			delete(m.collapsedFiles, m.searchResults[m.selectedIndex].File)
 			m.adjustScroll()
-			return m, m.loadPreview()
 		}
-		return m, nil
+		return nil
+
+	case keymap.FileNext:
+		return m.selectFile(1)
+
+	case keymap.FilePrev:
+		return m.selectFile(-1)
+
+	case keymap.HitNext:
+		return m.selectHitInFile(1)
+
+	case keymap.HitPrev:
+		return m.selectHitInFile(-1)
+
+	case keymap.MarkToggle:
+		// Mark or unmark the selected result (or file, on its header)
+		m.toggleMark()
+		return nil
 
-	case "enter":
+	case keymap.MarkAll:
+		m.toggleMarkAll()
+		return nil
+
+	case keymap.PreviewDown:
+		return m.scrollPreview(m.previewCodeLines() / 2)
+
+	case keymap.PreviewUp:
+		return m.scrollPreview(-m.previewCodeLines() / 2)
+
+	case keymap.ContextExpand:
+		return m.adjustContext(contextStep)
+
+	case keymap.ContextTrim:
+		return m.adjustContext(-contextStep)
+
+	case keymap.Open:
+		// In replace mode Enter replaces the selected match
+		if m.replaceMode {
+			return m.replaceSelected()
+		}
+		// In pick mode Enter chooses the marked (or selected) results
+		if m.pickMode {
+			return m.pick()
+		}
+		// With marked results, open them all
+		if len(m.marked) > 0 {
+			return m.openMarked()
+		}
 		if m.selectedIndex >= 0 && m.selectedIndex < len(m.searchResults) {
-			result := m.searchResults[m.selectedIndex]
-			if err := editor.OpenFile(m.editor, result.File, result.Line, result.Column); err != nil {
-				// Error opening editor - could show a message, but for now just continue
-			}
-			return m, tea.Quit
+			return m.openResult(m.searchResults[m.selectedIndex])
 		}
-		return m, nil
+		return nil
 
-	default:
-		// Check if we're waiting for ESC sequence (Alt key)
-		if m.waitingForEscSequence {
-			m.waitingForEscSequence = false
-			// Check if this is Alt+P or Alt+D
-			if len(msg.Runes) > 0 {
-				runeChar := msg.Runes[0]
-				if runeChar == 'p' || runeChar == 'P' {
-					// Alt+P: Switch to project scope
-					if m.gitRoot != "" && m.searchScope != "project" {
-						m.searchScope = "project"
-						return m, m.triggerSearch()
-					}
-					return m, nil
-				}
-				if runeChar == 'd' || runeChar == 'D' {
-					// Alt+D: Switch to directory scope
-					if m.searchScope != "directory" {
-						m.searchScope = "directory"
-						return m, m.triggerSearch()
-					}
-					return m, nil
-				}
-			}
-			// If it's not P or D, ignore (ESC was part of sequence but not our command)
-			return m, nil
+	case keymap.ScopeProject:
+		return m.setScope(scope.Project())
+	case keymap.ScopeDirectory:
+		return m.setScope(scope.Directory())
+	case keymap.ScopeGit:
+		// Cycle through the git scopes (changed, staged, untracked, diff, recent commits)
+		return m.cycleGitScope()
+	case keymap.ScopePick:
+		return m.openPicker()
+	case keymap.ScopeNamed:
+		return m.cycleNamedScope()
+
+	case keymap.ToggleHistory:
+		// Cycle between searching the working tree, a revision and the pickaxe
+		return m.cycleHistory()
+	case keymap.ToggleCase:
+		// Cycle match case (smart -> sensitive -> insensitive)
+		m.caseMode = m.caseMode.Next()
+		return m.triggerSearch()
+	case keymap.ToggleWord:
+		m.wholeWord = !m.wholeWord
+		return m.triggerSearch()
+	case keymap.ToggleRegex:
+		m.regexMode = !m.regexMode
+		return m.triggerSearch()
+	case keymap.ToggleMask:
+		m.maskEnabled = !m.maskEnabled
+		return m.triggerSearch()
+
+	case keymap.ToggleReplace:
+		return m.toggleReplaceMode()
+	case keymap.ReplaceSkip:
+		if m.replaceMode {
+			return m.skipSelected()
+		}
+		return nil
+	case keymap.ReplaceFile:
+		if m.replaceMode {
+			return m.replaceAllInFile()
+		}
+		return nil
+	case keymap.ReplaceAll:
+		if m.replaceMode {
+			return m.replaceAll()
 		}
+		return nil
 
-		// Handle text input
-		return m.handleTextInput(msg)
+	case keymap.CopyLocations:
+		// Copy file:line of the marked (or selected) results
+		m.copyLocations()
+		return nil
+	case keymap.ExportQuickfix:
+		// Write the marked (or selected) results to a quickfix file
+		m.writeQuickfix()
+		return nil
+	case keymap.Export:
+		// Export the marked (or all) results, in a format chosen next
+		m.openExportPrompt()
+		return nil
+	case keymap.ToggleGroup:
+		// Toggle between flat and grouped-by-file view
+		m.groupByFile = !m.groupByFile
+		m.headerSelected = false
+		m.adjustScroll()
+		return nil
 	}
+	return nil
 }
 
-// handleTextInput processes text input for query and mask fields
-func (m *Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// FIRST: Check for special characters that might be Alt key sequences
-	// macOS sends Option+P as π (U+03C0) and Option+D as ∂ (U+2202)
-	// This must be checked BEFORE any other processing to prevent text input
-	keyStr := msg.String()
-
-	if len(msg.Runes) > 0 {
-		runeChar := msg.Runes[0]
-
-		// π (U+03C0) = Option+P on macOS
-		if runeChar == 'π' || runeChar == 0x03C0 {
-			// Alt+P: Switch to project scope
-			if m.gitRoot != "" {
-				if m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-			}
-			return m, nil
-		}
-		// ∂ (U+2202) = Option+D on macOS
-		if runeChar == '∂' || runeChar == 0x2202 {
-			// Alt+D: Switch to directory scope
-			if m.searchScope != "directory" {
-				m.searchScope = "directory"
-				return m, m.triggerSearch()
-			}
-			return m, nil
-		}
+// setScope switches the search scope and triggers a new search if it changed
+func (m *Model) setScope(s scope.Scope) tea.Cmd {
+	if s.IsGit() && m.gitRoot == "" {
+		return nil
 	}
-
-	// Also check keyStr for π/∂ (in case Runes is empty but String contains it)
-	if keyStr == "π" || keyStr == "∂" {
-		if keyStr == "π" {
-			if m.gitRoot != "" {
-				if m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-			}
-			return m, nil
-		}
-		if keyStr == "∂" {
-			if m.searchScope != "directory" {
-				m.searchScope = "directory"
-				return m, m.triggerSearch()
-			}
-			return m, nil
-		}
+	if m.scope.Equal(s) {
+		return nil
 	}
+	m.scope = s
+	return m.triggerSearch()
+}
 
-	// Check for Alt key combinations - prevent them from being treated as text input
-	// keyStr is already defined above
-	if keyStr == "alt+p" || keyStr == "alt+P" {
-		// Alt+P: Switch to project scope
-		if m.gitRoot != "" && m.searchScope != "project" {
-			m.searchScope = "project"
-			return m, m.triggerSearch()
-		}
-		return m, nil
+// cycleNamedScope switches to the next named scope from the config file
+func (m *Model) cycleNamedScope() tea.Cmd {
+	if len(m.namedScopes) == 0 {
+		return nil
 	}
-	if keyStr == "alt+d" || keyStr == "alt+D" {
-		// Alt+D: Switch to directory scope
-		if m.searchScope != "directory" {
-			m.searchScope = "directory"
-			return m, m.triggerSearch()
+	next := 0
+	if m.scope.Kind == scope.KindNamed {
+		for i, s := range m.namedScopes {
+			if s.Name == m.scope.Name {
+				next = (i + 1) % len(m.namedScopes)
+				break
+			}
 		}
-		return m, nil
 	}
+	return m.setScope(m.namedScopes[next])
+}
 
-	// Check Alt modifier and runes
-	if msg.Alt {
-		if len(msg.Runes) > 0 {
-			runeChar := msg.Runes[0]
-			if runeChar == 'p' || runeChar == 'P' {
-				// Alt+P: Switch to project scope
-				if m.gitRoot != "" && m.searchScope != "project" {
-					m.searchScope = "project"
-					return m, m.triggerSearch()
-				}
-				return m, nil
-			}
-			if runeChar == 'd' || runeChar == 'D' {
-				// Alt+D: Switch to directory scope
-				if m.searchScope != "directory" {
-					m.searchScope = "directory"
-					return m, m.triggerSearch()
-				}
-				return m, nil
-			}
+// cycleGitScope switches to the next git-backed scope
+// From any other scope it switches to the first one, the changed files.
+func (m *Model) cycleGitScope() tea.Cmd {
+	next := 0
+	for i, s := range m.gitScopes {
+		if m.scope.Equal(s) {
+			next = (i + 1) % len(m.gitScopes)
+			break
 		}
-		// Alt key is pressed but not P or D - don't process as text input
-		return m, nil
 	}
+	return m.setScope(m.gitScopes[next])
+}
 
-	var input *textInput
-	if m.inputMode == InputModeQuery {
-		input = &m.queryInput
-	} else {
-		input = &m.maskInput
+// activeInput returns the input being edited
+func (m *Model) activeInput() *textInput {
+	switch m.inputMode {
+	case InputModeQuery:
+		return &m.queryInput
+	case InputModeReplace:
+		return &m.replaceInput
+	case InputModeRev:
+		return &m.revInput
+	default:
+		return &m.maskInput
 	}
+}
 
-	switch keyStr {
+// handleTextInput processes text input for query and mask fields
+func (m *Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	input := m.activeInput()
+	switch msg.String() {
 	case "backspace":
 		if len(input.value) > 0 {
 			input.value = input.value[:len(input.value)-1]
 		}
 	case " ":
-		// In mask input mode, space toggles mask enabled/disabled
-		if m.inputMode == InputModeMask {
-			m.maskEnabled = !m.maskEnabled
-			return m, m.triggerSearch()
-		}
-		// Otherwise, add space to input
+		// Spaces are part of comma-separated masks ("*.go, !*_test.go"),
+		// so the mask is toggled with Alt+M instead
 		input.value += " "
 	default:
 		if len(msg.Runes) > 0 {
@@ -465,9 +666,16 @@ func (m *Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 
 	// Update the corresponding field
-	if m.inputMode == InputModeQuery {
+	switch m.inputMode {
+	case InputModeQuery:
 		m.query = input.value
-	} else {
+	case InputModeReplace:
+		// The replacement doesn't change what is found, only the diff preview
+		m.replacement = input.value
+		return m, m.loadPreview()
+	case InputModeRev:
+		m.rev = input.value
+	default:
 		m.mask = input.value
 	}
 
@@ -477,7 +685,19 @@ func (m *Model) handleTextInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // handleMouse handles mouse events
 func (m *Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
-	// Only handle mouse clicks (not mouse movement)
+	if m.picker != nil || m.recall != nil {
+		return m, nil
+	}
+
+	// The mouse wheel scrolls the preview
+	switch msg.Type {
+	case tea.MouseWheelUp:
+		return m, m.scrollPreview(-wheelLines)
+	case tea.MouseWheelDown:
+		return m, m.scrollPreview(wheelLines)
+	}
+
+	// Otherwise only handle mouse clicks (not mouse movement)
 	if msg.Type != tea.MouseLeft {
 		return m, nil
 	}
@@ -489,100 +709,142 @@ func (m *Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	// Calculate checkbox position using the same styles as renderHeader
-	// We need to match the exact rendering to get the correct position
-	searchIconStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("220")).
-		Bold(true)
-	queryInputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("255")).
-		Background(lipgloss.Color("236"))
-
-	icon := searchIconStyle.Render("🔍")
-	iconWidth := lipgloss.Width(icon)
-
-	queryValue := m.queryInput.value
-	if m.inputMode == InputModeQuery {
-		queryValue += "█"
-	}
-	queryDisplay := queryInputStyle.Render(queryValue)
-	queryWidth := lipgloss.Width(queryDisplay)
-
-	// Account for:
-	// - Border (1 char left)
-	// - headerStyle padding (1 char left)
-	// - icon + " " (iconWidth + 1)
-	// - queryDisplay
-	// - "  " (2 chars spacer)
-	// Then checkbox starts at the beginning of maskLabel
-	borderWidth := 1
-	headerPadding := 1
-	iconSpaceWidth := 1 // Space after icon
-	spacerWidth := 2    // "  " between query and mask
-
-	// Checkbox starts after all the above
-	checkboxStartX := borderWidth + headerPadding + iconWidth + iconSpaceWidth + queryWidth + spacerWidth
-	checkboxWidth := 3 // "[x]" or "[ ]"
-
-	// Also allow clicking on "File mask:" text (extend clickable area)
-	// "File mask:" is 11 characters, so total clickable width is 3 + 1 + 11 = 15
-	clickableWidth := checkboxWidth + 1 + 11 // "[x] File mask:"
-
-	// Check if click is on the checkbox or "File mask:" text
-	if msg.X >= checkboxStartX && msg.X < checkboxStartX+clickableWidth {
-		// Toggle mask enabled
-		m.maskEnabled = !m.maskEnabled
-		return m, m.triggerSearch()
+	// Walk the header items exactly as renderHeader lays them out
+	// Account for the border (1 char left) and headerStyle padding (1 char left)
+	const borderWidth = 1
+	const headerPadding = 1
+	x := borderWidth + headerPadding
+	for _, item := range headerItems(m) {
+		width := lipgloss.Width(item.view)
+		if msg.X >= x && msg.X < x+width {
+			return m, m.handleHeaderClick(item.action)
+		}
+		x += width
 	}
 
 	return m, nil
 }
 
+// handleHeaderClick performs the action of a clicked header item
+func (m *Model) handleHeaderClick(action headerAction) tea.Cmd {
+	switch action {
+	case headerActionToggleMask:
+		m.maskEnabled = !m.maskEnabled
+		return m.triggerSearch()
+	case headerActionToggleCase:
+		m.caseMode = m.caseMode.Next()
+		return m.triggerSearch()
+	case headerActionToggleWord:
+		m.wholeWord = !m.wholeWord
+		return m.triggerSearch()
+	case headerActionToggleRegex:
+		m.regexMode = !m.regexMode
+		return m.triggerSearch()
+	case headerActionScopeProject:
+		return m.setScope(scope.Project())
+	case headerActionScopeDirectory:
+		return m.setScope(scope.Directory())
+	case headerActionScopeGit:
+		return m.cycleGitScope()
+	case headerActionScopePick:
+		return m.openPicker()
+	case headerActionScopeNamed:
+		return m.cycleNamedScope()
+	}
+	return nil
+}
+
 // triggerSearch starts a new search with debounce
 func (m *Model) triggerSearch() tea.Cmd {
-	// Cancel previous search if any
+	// Cancel previous search if any, and drop the batches it has already
+	// queued: they still carry its ID
 	if m.searchCancel != nil {
 		m.searchCancel()
+		m.searchCancel = nil
 	}
+	m.searchID = noSearch
+	m.resultChan = nil
+	m.isSearching = false
 
 	// Reset selection and scroll
 	m.selectedIndex = -1
 	m.resultsOffset = 0
+	m.headerSelected = false
+	m.collapsedFiles = make(map[string]bool)
+	m.restoreSelection = nil
 	m.preview = nil
+	m.previewResult = nil
 	m.previewError = nil
+	m.skipped = search.Skipped{}
 
 	// If query is empty, clear results
 	if m.query == "" {
 		m.searchResults = nil
-		m.isSearching = false
+		return nil
+	}
+
+	// Report an invalid file mask instead of letting rg fail on it
+	if err := m.searchState().validate(); err != nil {
+		m.searchGeneration++
+		m.searchResults = nil
+		m.searchError = err
 		return nil
 	}
 
 	// Start search after debounce
-	query := m.query
-	mask := m.mask
-	// If mask is disabled, use empty string
-	if !m.maskEnabled {
-		mask = ""
-	}
-	return tea.Tick(debounceDuration, func(time.Time) tea.Msg {
-		return startSearchMsg{Query: query, Mask: mask}
+	m.searchGeneration++
+	generation := m.searchGeneration
+	return tea.Tick(m.debounce, func(time.Time) tea.Msg {
+		return startSearchMsg{Generation: generation}
 	})
 }
 
 // startSearchMsg is sent after debounce to start the actual search
 type startSearchMsg struct {
-	Query string
-	Mask  string
+	Generation int // Value of searchGeneration when the search was requested
+}
+
+// searchOptions builds the search options from the current input state
+func (m *Model) searchOptions() (search.Options, error) {
+	return m.searchState().options()
+}
+
+// searchState returns the part of the state that determines what is searched
+func (m *Model) searchState() searchState {
+	return searchState{
+		query:       m.query,
+		mask:        m.mask,
+		maskEnabled: m.maskEnabled,
+		scope:       m.scope,
+		gitRoot:     m.gitRoot,
+		currentDir:  m.currentDir,
+		regex:       m.regexMode,
+		caseMode:    m.caseMode,
+		wholeWord:   m.wholeWord,
+		hidden:      m.hidden,
+		extraArgs:   m.rgArgs,
+		maxFilesize: m.maxFilesize,
+	}
 }
 
 // escTimeoutMsg is sent when ESC sequence timeout occurs
 type escTimeoutMsg struct{}
 
-// handleSearchResult processes search results
+// handleSearchResult processes a batch of search results
+// Batches are appended to the current results so the selection and preview
+// the user already has are kept while the search is still running.
 func (m *Model) handleSearchResult(msg search.SearchResultMsg) (tea.Model, tea.Cmd) {
-	m.isSearching = false
-	m.searchCancel = nil
+	// Ignore batches from searches that have been superseded
+	if msg.SearchID != m.searchID {
+		return m, nil
+	}
+
+	if msg.Done {
+		m.isSearching = false
+		m.searchCancel = nil
+		m.resultChan = nil
+		m.skipped = msg.Skipped
+	}
 
 	if msg.Error != nil {
 		m.searchError = msg.Error
@@ -590,36 +852,123 @@ func (m *Model) handleSearchResult(msg search.SearchResultMsg) (tea.Model, tea.C
 		return m, nil
 	}
 
-	m.searchResults = msg.Results
-	m.searchError = nil
+	m.searchResults = append(m.searchResults, msg.Results...)
+
+	var cmds []tea.Cmd
+	if !msg.Done {
+		cmds = append(cmds, waitForSearchResult(m.resultChan))
+	}
+
+	// After a refresh, select the previously selected result again
+	if m.restoreSelection != nil {
+		if cmd := m.restoreSelected(msg.Done); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(cmds...)
+	}
 
 	// Auto-select first result if available
 	if len(m.searchResults) > 0 && m.selectedIndex < 0 {
 		m.selectedIndex = 0
 		m.resultsOffset = 0
-		return m, m.loadPreview()
+		cmds = append(cmds, m.loadPreview())
 	}
 
-	return m, nil
+	return m, tea.Batch(cmds...)
+}
+
+// waitForSearchResult waits for the next batch from a running search
+func waitForSearchResult(resultChan <-chan search.SearchResultMsg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-resultChan
+		if !ok {
+			// Channel closed without a final message (search was cancelled)
+			return nil
+		}
+		return msg
+	}
+}
+
+// rows returns the rows of the results list for the current view mode
+func (m *Model) rows() []resultRow {
+	return buildRows(m.searchResults, m.groupByFile, m.collapsedFiles)
+}
+
+// selectedRow returns the position of the selected row, or -1 if nothing is selected
+func (m *Model) selectedRow(rows []resultRow) int {
+	return findRow(rows, m.searchResults, m.selectedIndex, m.groupByFile && m.headerSelected)
+}
+
+// selectRow moves the selection to the row at pos
+// Selecting a file header selects the first result of that file for preview and open.
+func (m *Model) selectRow(rows []resultRow, pos int) tea.Cmd {
+	if pos < 0 || pos >= len(rows) {
+		return nil
+	}
+	previous := m.selectedIndex
+	row := rows[pos]
+	if row.isHeader() {
+		m.headerSelected = true
+		m.selectedIndex = firstResultInFile(m.searchResults, row.file)
+	} else {
+		m.headerSelected = false
+		m.selectedIndex = row.resultIndex
+	}
+	m.adjustScroll()
+	if m.selectedIndex != previous {
+		return m.loadPreview()
+	}
+	return nil
+}
+
+// selectFile moves the selection to the first result of the next (delta=1)
+// or previous (delta=-1) file
+func (m *Model) selectFile(delta int) tea.Cmd {
+	if m.selectedIndex < 0 || m.selectedIndex >= len(m.searchResults) {
+		return nil
+	}
+	groups := groupResults(m.searchResults)
+	current := m.searchResults[m.selectedIndex].File
+	for gi, group := range groups {
+		if group.file != current {
+			continue
+		}
+		target := gi + delta
+		if target < 0 || target >= len(groups) {
+			return nil
+		}
+		previous := m.selectedIndex
+		m.selectedIndex = groups[target].indices[0]
+		m.headerSelected = false
+		m.adjustScroll()
+		if m.selectedIndex != previous {
+			return m.loadPreview()
+		}
+		return nil
+	}
+	return nil
 }
 
 // adjustScroll adjusts the scroll offset to keep selected item visible
 func (m *Model) adjustScroll() {
 	const visibleResults = 5
 
-	if len(m.searchResults) <= visibleResults {
+	rows := m.rows()
+	if len(rows) <= visibleResults {
 		m.resultsOffset = 0
 		return
 	}
 
+	selected := m.selectedRow(rows)
+
 	// If selected item is above visible area, scroll up
-	if m.selectedIndex < m.resultsOffset {
-		m.resultsOffset = m.selectedIndex
+	if selected < m.resultsOffset {
+		m.resultsOffset = selected
 	}
 
 	// If selected item is below visible area, scroll down
-	if m.selectedIndex >= m.resultsOffset+visibleResults {
-		m.resultsOffset = m.selectedIndex - visibleResults + 1
+	if selected >= m.resultsOffset+visibleResults {
+		m.resultsOffset = selected - visibleResults + 1
 	}
 
 	// Ensure offset doesn't go negative
@@ -628,7 +977,7 @@ func (m *Model) adjustScroll() {
 	}
 
 	// Ensure offset doesn't exceed bounds
-	maxOffset := len(m.searchResults) - visibleResults
+	maxOffset := len(rows) - visibleResults
 	if m.resultsOffset > maxOffset {
 		m.resultsOffset = maxOffset
 	}
@@ -641,34 +990,74 @@ func (m *Model) loadPreview() tea.Cmd {
 	}
 
 	result := m.searchResults[m.selectedIndex]
+
+	// Only the latest selection matters: stop loading the previous one
+	if m.previewCancel != nil {
+		m.previewCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.previewCancel = cancel
+
+	if result != m.previewResult {
+		// A new result starts with the viewport at its hit
+		m.previewTop = 0
+		m.previewExtendBefore, m.previewExtendAfter = 0, 0
+	}
+	if m.replaceMode && m.replaceDecisions[result] == replacePending && !result.Binary {
+		return m.loadReplacePreview(result)
+	}
+	if result.Revision != nil {
+		return m.loadRevisionPreview(ctx, result)
+	}
+	before, after := m.previewWindow()
+	theme := m.syntaxTheme
 	return func() tea.Msg {
-		preview, err := preview.LoadPreview(result.File, result.Line)
-		return previewLoadedMsg{Preview: preview, Error: err}
+		preview, err := preview.LoadPreviewRange(ctx, result.Path(), result.Line, before, after)
+		if err == nil {
+			preview.Highlight(theme)
+		}
+		return previewLoadedMsg{Result: result, Preview: preview, Error: err}
 	}
 }
 
 // previewLoadedMsg is sent when preview is loaded
 type previewLoadedMsg struct {
+	Result  *search.SearchResult
 	Preview *preview.Preview
 	Error   error
 }
 
 // handlePreviewLoaded processes loaded preview
 func (m *Model) handlePreviewLoaded(msg previewLoadedMsg) (tea.Model, tea.Cmd) {
+	if errors.Is(msg.Error, context.Canceled) {
+		// Superseded by a newer selection
+		return m, nil
+	}
 	if msg.Error != nil {
 		m.previewError = msg.Error
 		m.preview = nil
+		m.previewResult = nil
 	} else {
 		m.preview = msg.Preview
+		m.previewResult = msg.Result
 		m.previewError = nil
+		m.placePreview()
 	}
 	return m, nil
 }
 
 // handleStartSearch starts the actual search
 func (m *Model) handleStartSearch(msg startSearchMsg) (tea.Model, tea.Cmd) {
-	// Only start if query hasn't changed
-	if m.query != msg.Query || m.mask != msg.Mask {
+	// Only start if nothing changed during the debounce
+	if msg.Generation != m.searchGeneration {
+		return m, nil
+	}
+
+	opts, err := m.searchOptions()
+	if err != nil {
+		// e.g. the changed-files scope has no files
+		m.searchError = err
+		m.searchResults = nil
 		return m, nil
 	}
 
@@ -677,17 +1066,19 @@ func (m *Model) handleStartSearch(msg startSearchMsg) (tea.Model, tea.Cmd) {
 	m.isSearching = true
 	m.searchError = nil
 
-	// Determine search path based on scope
-	searchPath := ""
-	if m.searchScope == "project" && m.gitRoot != "" {
-		searchPath = m.gitRoot
+	// Results of the previous search are replaced by the batches of this one
+	m.searchResults = nil
+	m.lastSearch = opts
+	m.searchStartedAt = time.Now()
+	m.resetReplaceState()
+	m.marked = make(map[*search.SearchResult]bool)
+	m.actionStatus = ""
+	if m.history != historyOff {
+		m.resultChan = m.searchHistory(ctx, opts)
 	} else {
-		searchPath = m.currentDir
+		m.resultChan = m.searcher.Search(ctx, opts)
 	}
+	m.searchID = m.searcher.CurrentID()
 
-	return m, func() tea.Msg {
-		resultChan := m.searcher.Search(ctx, msg.Query, msg.Mask, searchPath)
-		msg := <-resultChan
-		return msg
-	}
+	return m, waitForSearchResult(m.resultChan)
 }
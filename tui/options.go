@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/search"
+)
+
+// searchState is the part of a frontend's state that determines what is searched
+// Both the Bubble Tea Model and the tview App build their search options from it
+type searchState struct {
+	query       string
+	mask        string
+	maskEnabled bool
+	scope       scope.Scope
+	gitRoot     string
+	currentDir  string
+	regex       bool
+	caseMode    search.CaseMode
+	wholeWord   bool
+	hidden      bool     // Search hidden files and directories
+	extraArgs   []string // Extra arguments passed to rg
+	maxFilesize string   // Skip files larger than this, e.g. "10M"
+}
+
+// options converts the state into search options
+// It fails if the file mask is not a valid list of globs or the scope
+// can't be resolved (e.g. there are no changed files).
+func (s searchState) options() (search.Options, error) {
+	if err := s.validate(); err != nil {
+		return search.Options{}, err
+	}
+
+	// Determine search path based on scope
+	dir, roots, err := s.scope.Resolve(scope.Env{GitRoot: s.gitRoot, CurrentDir: s.currentDir})
+	if err != nil {
+		return search.Options{}, err
+	}
+
+	opts := search.Options{
+		Query:       s.query,
+		Regex:       s.regex,
+		CaseMode:    s.caseMode,
+		WholeWord:   s.wholeWord,
+		Dir:         dir,
+		Roots:       roots,
+		Hidden:      s.hidden,
+		ExtraArgs:   s.extraArgs,
+		MaxFilesize: s.maxFilesize,
+	}
+
+	// If mask is disabled, search all files
+	if s.maskEnabled {
+		mask, _ := search.ParseMask(s.mask) // Checked by validate
+		opts.Includes = mask.Includes
+		opts.Excludes = mask.Excludes
+	}
+
+	return opts, nil
+}
+
+// validate reports input the user has to fix before searching
+// Unlike options it is cheap: the scope is not resolved, so it can run on
+// every keystroke.
+func (s searchState) validate() error {
+	if s.maskEnabled {
+		if _, err := search.ParseMask(s.mask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takaishi/fif/export"
+	"github.com/takaishi/fif/internal/rgtest"
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/search"
+)
+
+func TestProjectScopeFromSubdirectory(t *testing.T) {
+	rg := rgtest.Install(t, "pkg/a.go")
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentDir := filepath.Join(root, "cmd", "tool")
+	for _, dir := range []string{currentDir, filepath.Join(root, "pkg")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "pkg", "a.go"), []byte("foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := searchState{query: "foo", scope: scope.Project(), gitRoot: root, currentDir: currentDir}
+	opts, err := state.options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Dir != root {
+		t.Errorf("options().Dir = %q, want the repository root %q", opts.Dir, root)
+	}
+
+	var results []*search.SearchResult
+	for msg := range search.NewSearcher().Search(context.Background(), opts) {
+		if msg.Error != nil {
+			t.Fatal(msg.Error)
+		}
+		results = append(results, msg.Results...)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	if got := rg.Dirs(t); len(got) != 1 || got[0] != root {
+		t.Errorf("rg ran in %q, want %q", got, root)
+	}
+
+	// The file that gets opened is under the root, not the current directory
+	result := results[0]
+	if got, want := result.Path(), filepath.Join(root, "pkg", "a.go"); got != want {
+		t.Errorf("Path() = %q, want %q", got, want)
+	}
+	// and it is displayed relative to the current directory
+	if got, want := result.RelPath(currentDir), filepath.Join("..", "..", "pkg", "a.go"); got != want {
+		t.Errorf("RelPath() = %q, want %q", got, want)
+	}
+
+	// The consumers of the result find the file too
+	m := &Model{searchResults: results, gitRoot: root, currentDir: currentDir, query: "foo"}
+	msg := m.loadPreview()().(previewLoadedMsg)
+	if msg.Error != nil || len(msg.Preview.Lines) == 0 || msg.Preview.Lines[0] != "foo" {
+		t.Errorf("preview = %+v, %v, want the file's first line", msg.Preview, msg.Error)
+	}
+	if loc, err := resultLocation(result); err != nil || loc.File != filepath.Join(root, "pkg", "a.go") {
+		t.Errorf("editor location = %+v, %v", loc, err)
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	m.exportResults(export.Quickfix)
+	path, ok := strings.CutPrefix(m.actionStatus, "Wrote 1 result to ")
+	if !ok {
+		t.Fatalf("export status = %q", m.actionStatus)
+	}
+	exported, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("pkg", "a.go") + ":1:1:"; !strings.HasPrefix(string(exported), want) {
+		t.Errorf("exported %q, want a location relative to the repository root %q", exported, want)
+	}
+}
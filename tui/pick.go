@@ -0,0 +1,30 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/search"
+)
+
+// pick chooses the marked results, or the selected one, and quits
+// The caller prints them (fif --pick).
+func (m *Model) pick() tea.Cmd {
+	results := m.markedResults()
+	if len(results) == 0 && m.selectedIndex >= 0 && m.selectedIndex < len(m.searchResults) {
+		results = []*search.SearchResult{m.searchResults[m.selectedIndex]}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	m.rememberSearch()
+	m.picked = results
+	if m.searchCancel != nil {
+		m.searchCancel()
+	}
+	return tea.Quit
+}
+
+// Picked returns the results chosen in pick mode, or nil if the user quit
+// without choosing
+func (m *Model) Picked() []*search.SearchResult {
+	return m.picked
+}
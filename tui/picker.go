@@ -0,0 +1,297 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/takaishi/fif/fuzzy"
+	"github.com/takaishi/fif/keymap"
+	"github.com/takaishi/fif/scope"
+)
+
+// maxPickerMatches caps the number of candidates kept after filtering
+const maxPickerMatches = 200
+
+// dirPicker is the directory picker opened with Alt+O
+// It offers the directories below the base with fuzzy completion. Several
+// directories can be picked to search them in a single run.
+type dirPicker struct {
+	input    textInput
+	base     string   // Directory the candidates are relative to
+	dirs     []string // All candidate directories, relative to base
+	matches  []string // Candidates matching the input, best first
+	selected int      // Index into matches
+	picked   []string // Absolute directories picked so far
+	loading  bool
+	err      error
+	cancel   context.CancelFunc
+}
+
+// dirsLoadedMsg is sent when the picker's candidate directories have been listed
+type dirsLoadedMsg struct {
+	Base string
+	Dirs []string
+	Err  error
+}
+
+// openPicker opens the directory picker
+// Directories picked for the current scope are kept so more can be added.
+func (m *Model) openPicker() tea.Cmd {
+	base := m.currentDir
+	if m.gitRoot != "" {
+		base = m.gitRoot
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &dirPicker{base: base, loading: true, cancel: cancel}
+	if m.scope.Kind == scope.KindDirs {
+		p.picked = append(p.picked, m.scope.Roots...)
+	}
+	m.picker = p
+	return func() tea.Msg {
+		dirs, err := scope.ListDirs(ctx, base)
+		return dirsLoadedMsg{Base: base, Dirs: dirs, Err: err}
+	}
+}
+
+// closePicker closes the directory picker without changing the scope
+func (m *Model) closePicker() {
+	if m.picker != nil {
+		m.picker.cancel()
+		m.picker = nil
+	}
+}
+
+// handleDirsLoaded fills the picker with the listed directories
+func (m *Model) handleDirsLoaded(msg dirsLoadedMsg) (tea.Model, tea.Cmd) {
+	p := m.picker
+	if p == nil || p.base != msg.Base {
+		return m, nil
+	}
+	p.loading = false
+	p.err = msg.Err
+	p.dirs = append([]string{"."}, msg.Dirs...)
+	p.filter()
+	return m, nil
+}
+
+// handlePickerKey processes keyboard input while the picker is open
+func (m *Model) handlePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.picker
+	switch m.pickerAction(msg, p.input.value) {
+	case keymap.Quit:
+		m.closePicker()
+		return m, nil
+	case keymap.ResultPrev:
+		if p.selected > 0 {
+			p.selected--
+		}
+		return m, nil
+	case keymap.ResultNext:
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+		return m, nil
+	case keymap.FocusNext:
+		// Complete the input with the selected candidate
+		if p.selected < len(p.matches) {
+			p.input.value = p.matches[p.selected] + "/"
+			p.filter()
+		}
+		return m, nil
+	case keymap.MarkToggle:
+		// Add (or remove) the current directory and keep picking
+		if dir, ok := p.current(); ok {
+			p.toggle(dir)
+			p.input.value = ""
+			p.filter()
+		}
+		return m, nil
+	case keymap.Open:
+		if dir, ok := p.current(); ok && (len(p.picked) == 0 || p.input.value != "") {
+			if !p.isPicked(dir) {
+				p.picked = append(p.picked, dir)
+			}
+		}
+		picked := p.picked
+		m.closePicker()
+		if len(picked) == 0 {
+			return m, nil
+		}
+		return m, m.setScope(scope.Dirs(picked...))
+	}
+
+	if p.input.edit(msg) {
+		p.filter()
+	}
+	return m, nil
+}
+
+// pickerAction returns the action of a key in the directory picker or the
+// history search
+// As in the main view, keys that type a character only act while the
+// input is empty.
+func (m *Model) pickerAction(msg tea.KeyMsg, input string) keymap.Action {
+	key := m.keyName(msg)
+	if keymap.Printable(key) && input != "" {
+		return ""
+	}
+	return m.keys.Action(key)
+}
+
+// edit applies Backspace or a typed character to a picker's input and
+// reports whether the input changed
+func (t *textInput) edit(msg tea.KeyMsg) bool {
+	switch {
+	case msg.String() == "backspace":
+		if len(t.value) == 0 {
+			return false
+		}
+		t.value = t.value[:len(t.value)-1]
+		return true
+	case len(msg.Runes) > 0 && !msg.Alt:
+		t.value += string(msg.Runes)
+		return true
+	}
+	return false
+}
+
+// keyHints renders hints like "Enter: search" for the bound actions
+func (m *Model) keyHints(hints ...keyHint) string {
+	var parts []string
+	for _, h := range hints {
+		if key := m.keys.Key(h.action); key != "" {
+			parts = append(parts, key+": "+h.label)
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+// keyHint is an action and what it does, for keyHints
+type keyHint struct {
+	action keymap.Action
+	label  string
+}
+
+// filter updates the candidates matching the input
+func (p *dirPicker) filter() {
+	pattern := strings.TrimSuffix(p.input.value, "/")
+	p.matches = fuzzy.Filter(pattern, p.dirs)
+	if len(p.matches) > maxPickerMatches {
+		p.matches = p.matches[:maxPickerMatches]
+	}
+	p.selected = 0
+}
+
+// current returns the absolute directory open or mark.toggle would pick
+// An input naming an existing directory (absolute, ~/..., or relative to
+// the base) wins over the fuzzy candidates, so directories outside the
+// base can be picked too.
+func (p *dirPicker) current() (string, bool) {
+	if value := strings.TrimSpace(p.input.value); value != "" {
+		path := value
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.base, path)
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return filepath.Clean(path), true
+		}
+	}
+	if p.selected < len(p.matches) {
+		return filepath.Join(p.base, p.matches[p.selected]), true
+	}
+	return "", false
+}
+
+// isPicked reports whether dir has been picked
+func (p *dirPicker) isPicked(dir string) bool {
+	for _, picked := range p.picked {
+		if picked == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// toggle adds dir to the picked directories, or removes it
+func (p *dirPicker) toggle(dir string) {
+	for i, picked := range p.picked {
+		if picked == dir {
+			p.picked = append(p.picked[:i], p.picked[i+1:]...)
+			return
+		}
+	}
+	p.picked = append(p.picked, dir)
+}
+
+// renderPicker renders the directory picker in place of the results and preview
+func renderPicker(m *Model, height int) string {
+	p := m.picker
+	width := m.width - 4
+
+	var lines []string
+	lines = append(lines, maskLabelStyle.Render("Directory: ")+queryInputStyle.Render(p.input.value+"█"))
+
+	if len(p.picked) > 0 {
+		names := make([]string, 0, len(p.picked))
+		for _, dir := range p.picked {
+			names = append(names, displayDir(p.base, dir))
+		}
+		lines = append(lines, statusStyle.Render(truncateText("Picked: "+strings.Join(names, ", "), width)))
+	}
+
+	switch {
+	case p.err != nil:
+		lines = append(lines, errorStyle.Render("Error listing directories: "+p.err.Error()))
+	case p.loading:
+		lines = append(lines, statusStyle.Render("Loading directories..."))
+	case len(p.matches) == 0:
+		lines = append(lines, statusStyle.Render("No matching directories"))
+	}
+
+	// Keep the selected candidate visible
+	visible := height - len(lines) - 3
+	if visible < 1 {
+		visible = 1
+	}
+	start := 0
+	if p.selected >= visible {
+		start = p.selected - visible + 1
+	}
+	for i := start; i < len(p.matches) && i < start+visible; i++ {
+		dir := p.matches[i]
+		marker := "  "
+		if p.isPicked(filepath.Join(p.base, dir)) {
+			marker = "● "
+		}
+		line := lipgloss.NewStyle().Width(width).Render(truncateText(marker+dir, width))
+		if i == p.selected {
+			line = selectedResultStyle.Render(line)
+		} else {
+			line = resultStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "", statusStyle.Render(fmt.Sprintf("In %s | %s", p.base, m.keyHints(
+		keyHint{keymap.Open, "search"}, keyHint{keymap.FocusNext, "complete"},
+		keyHint{keymap.MarkToggle, "add another"}, keyHint{keymap.Quit, "cancel"}))))
+	return previewStyle.Width(m.width - 2).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// displayDir returns dir relative to base when it is inside it
+func displayDir(base, dir string) string {
+	if rel, err := filepath.Rel(base, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return dir
+}
@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/keymap"
+)
+
+// keyMsg returns the message of a key as Bubble Tea sends it
+func keyMsg(key string) tea.KeyMsg {
+	switch key {
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "ctrl+j":
+		return tea.KeyMsg{Type: tea.KeyCtrlJ}
+	case "ctrl+k":
+		return tea.KeyMsg{Type: tea.KeyCtrlK}
+	case "ctrl+q":
+		return tea.KeyMsg{Type: tea.KeyCtrlQ}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+func TestPickersUseTheKeymap(t *testing.T) {
+	keys, err := keymap.New("vim", map[string]string{"quit": "ctrl+q", "focus.next": "ctrl+k", "result.prev": "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Model{keys: keys}
+	m.picker = &dirPicker{base: "/src", dirs: []string{".", "cmd", "pkg"}, cancel: func() {}}
+	m.picker.filter()
+	for _, key := range []string{"ctrl+j", "esc", "tab"} {
+		m.handleKey(keyMsg(key))
+	}
+	if m.picker == nil {
+		t.Fatal("Esc closed the picker although quit is bound to Ctrl+Q only")
+	}
+	if m.picker.selected != 1 {
+		t.Errorf("selected = %d after Ctrl+J, want 1", m.picker.selected)
+	}
+	if got := m.picker.input.value; got != "" {
+		t.Errorf("input = %q after Tab, which is no longer bound to focus.next", got)
+	}
+	m.handleKey(keyMsg("ctrl+k"))
+	if got := m.picker.input.value; got != "cmd/" {
+		t.Errorf("input = %q after Ctrl+K, want the completed %q", got, "cmd/")
+	}
+	m.handleKey(keyMsg("ctrl+q"))
+	if m.picker != nil {
+		t.Error("Ctrl+Q didn't close the picker")
+	}
+
+	m.recall = &recallPicker{items: []string{"foo", "bar", "baz"}}
+	m.recall.filter()
+	for _, key := range []string{"b", "ctrl+j", "ctrl+k"} {
+		m.handleKey(keyMsg(key))
+	}
+	if got := m.recall.input.value; got != "b" {
+		t.Errorf("input = %q, want %q", got, "b")
+	}
+	if m.recall.selected != 1 {
+		t.Errorf("selected = %d after Ctrl+J, want 1", m.recall.selected)
+	}
+	m.handleKey(keyMsg("backspace"))
+	if got := m.recall.input.value; got != "" {
+		t.Errorf("input = %q after Backspace, want it empty", got)
+	}
+	m.handleKey(keyMsg("ctrl+q"))
+	if m.recall != nil {
+		t.Error("Ctrl+Q didn't close the history search")
+	}
+}
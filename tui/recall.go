@@ -0,0 +1,233 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/takaishi/fif/fuzzy"
+	"github.com/takaishi/fif/keymap"
+	"github.com/takaishi/fif/scope"
+	"github.com/takaishi/fif/search"
+	"github.com/takaishi/fif/searches"
+)
+
+// recallPicker is the fuzzy search over the search history opened with Ctrl+R
+type recallPicker struct {
+	input    textInput
+	entries  map[string]searches.Entry // History entries by their description
+	items    []string                  // Descriptions of all entries, most recent first
+	matches  []string                  // Descriptions matching the input, best first
+	selected int                       // Index into matches
+}
+
+// currentEntry returns the current search as a history entry
+func (m *Model) currentEntry() searches.Entry {
+	e := searches.Entry{
+		Query:       m.query,
+		Mask:        m.mask,
+		MaskEnabled: m.maskEnabled,
+		Regex:       m.regexMode,
+		Word:        m.wholeWord,
+	}
+	if !m.scope.Equal(scope.Default(m.scopeEnv())) {
+		e.Scope = m.scope.Spec()
+		if m.scope.Kind == scope.KindDirs {
+			e.Roots = m.scope.Roots
+		}
+	}
+	if m.caseMode != search.CaseSmart {
+		e.Case = m.caseMode.String()
+	}
+	return e
+}
+
+// scopeEnv returns the environment scopes are resolved in
+func (m *Model) scopeEnv() scope.Env {
+	return scope.Env{GitRoot: m.gitRoot, CurrentDir: m.currentDir}
+}
+
+// rememberSearch records the current search in the history
+// It is called when the user acts on the results (opening them, or
+// quitting with a query), so the queries typed on the way are not kept.
+func (m *Model) rememberSearch() {
+	if m.searchLog == nil || m.query == "" {
+		return
+	}
+	// A history that can't be saved is not worth interrupting the user for
+	_ = m.searchLog.Add(m.currentEntry())
+}
+
+// recentSearches returns the search history, most recent first
+func (m *Model) recentSearches() []searches.Entry {
+	if m.searchLog == nil {
+		return nil
+	}
+	return m.searchLog.Entries()
+}
+
+// applyEntry restores a search from the history or a saved search and runs it
+// A scope that no longer exists (e.g. a removed named scope) falls back to
+// the default scope.
+func (m *Model) applyEntry(e searches.Entry) tea.Cmd {
+	m.query = e.Query
+	m.queryInput.value = e.Query
+	m.mask = e.Mask
+	m.maskInput.value = e.Mask
+	m.maskEnabled = e.MaskEnabled
+	m.regexMode = e.Regex
+	m.wholeWord = e.Word
+	m.caseMode, _ = search.ParseCaseMode(e.Case)
+	s, err := scope.Parse(e.Scope, e.Roots, m.scopeEnv(), m.scopeSettings)
+	if err != nil || (s.IsGit() && m.gitRoot == "") {
+		s = scope.Default(m.scopeEnv())
+	}
+	m.scope = s
+	m.inputMode = InputModeQuery
+	m.recallSelected = 0
+	return m.triggerSearch()
+}
+
+// handleRecallKey processes the result.prev, result.next and open actions
+// (Up, Down and Enter) while the query is empty: they browse the search
+// history shown in place of the results
+func (m *Model) handleRecallKey(action keymap.Action) (tea.Cmd, bool) {
+	entries := m.recentSearches()
+	if m.query != "" || m.inputMode != InputModeQuery || len(entries) == 0 {
+		return nil, false
+	}
+	switch action {
+	case keymap.ResultPrev:
+		if m.recallSelected > 0 {
+			m.recallSelected--
+		}
+	case keymap.ResultNext:
+		if m.recallSelected < len(entries)-1 {
+			m.recallSelected++
+		}
+	case keymap.Open:
+		return m.applyEntry(entries[min(m.recallSelected, len(entries)-1)]), true
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// openRecallPicker opens the fuzzy search over the search history
+func (m *Model) openRecallPicker() {
+	entries := m.recentSearches()
+	if len(entries) == 0 {
+		m.actionStatus = "No search history yet"
+		return
+	}
+	p := &recallPicker{entries: make(map[string]searches.Entry, len(entries))}
+	for _, e := range entries {
+		desc := e.String()
+		if _, ok := p.entries[desc]; !ok {
+			p.entries[desc] = e
+			p.items = append(p.items, desc)
+		}
+	}
+	p.filter()
+	m.recall = p
+}
+
+// handleRecallPickerKey processes keyboard input while the history search is open
+func (m *Model) handleRecallPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.recall
+	switch m.pickerAction(msg, p.input.value) {
+	case keymap.Quit:
+		m.recall = nil
+		return m, nil
+	case keymap.ResultPrev, keymap.HistorySearch:
+		if p.selected > 0 {
+			p.selected--
+		}
+		return m, nil
+	case keymap.ResultNext:
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+		return m, nil
+	case keymap.Open:
+		m.recall = nil
+		if p.selected >= len(p.matches) {
+			return m, nil
+		}
+		return m, m.applyEntry(p.entries[p.matches[p.selected]])
+	}
+
+	if p.input.edit(msg) {
+		p.filter()
+	}
+	return m, nil
+}
+
+// filter updates the entries matching the input
+func (p *recallPicker) filter() {
+	p.matches = fuzzy.Filter(p.input.value, p.items)
+	if len(p.matches) > maxPickerMatches {
+		p.matches = p.matches[:maxPickerMatches]
+	}
+	p.selected = 0
+}
+
+// renderRecallPicker renders the history search in place of the results and preview
+func renderRecallPicker(m *Model, height int) string {
+	p := m.recall
+	width := m.width - 4
+
+	var lines []string
+	lines = append(lines, maskLabelStyle.Render("History: ")+queryInputStyle.Render(p.input.value+"█"))
+	if len(p.matches) == 0 {
+		lines = append(lines, statusStyle.Render("No matching searches"))
+	}
+	lines = append(lines, renderEntryList(p.matches, p.selected, height-len(lines)-3, width)...)
+
+	lines = append(lines, "", statusStyle.Render(fmt.Sprintf("%d searches | %s", len(p.items), m.keyHints(
+		keyHint{keymap.Open, "search"}, keyHint{keymap.ResultPrev, "previous"},
+		keyHint{keymap.ResultNext, "next"}, keyHint{keymap.Quit, "cancel"}))))
+	return previewStyle.Width(m.width - 2).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// renderRecentSearches renders the search history in place of the results
+// while the query is empty
+func renderRecentSearches(m *Model, maxHeight int) string {
+	entries := m.recentSearches()
+	if len(entries) == 0 {
+		return ""
+	}
+	items := make([]string, len(entries))
+	for i, e := range entries {
+		items[i] = e.String()
+	}
+	hint := fmt.Sprintf("Recent searches (%s/%s, %s", m.keys.Key(keymap.ResultPrev), m.keys.Key(keymap.ResultNext), m.keys.Key(keymap.Open))
+	if key := m.keys.Key(keymap.HistorySearch); key != "" {
+		hint += "; " + key + " to search them"
+	}
+	lines := []string{statusStyle.Render(hint + ")")}
+	lines = append(lines, renderEntryList(items, m.recallSelected, maxHeight-1, m.width-4)...)
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderEntryList renders up to visible items, keeping the selected one in view
+func renderEntryList(items []string, selected, visible, width int) []string {
+	if visible < 1 {
+		visible = 1
+	}
+	start := 0
+	if selected >= visible {
+		start = selected - visible + 1
+	}
+	var lines []string
+	for i := start; i < len(items) && i < start+visible; i++ {
+		line := lipgloss.NewStyle().Width(width).Render(truncateText(items[i], width))
+		if i == selected {
+			line = selectedResultStyle.Render(line)
+		} else {
+			line = resultStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,265 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/takaishi/fif/preview"
+	"github.com/takaishi/fif/replace"
+	"github.com/takaishi/fif/search"
+)
+
+// replaceDecision records what happened to a result in replace mode
+type replaceDecision int
+
+const (
+	replacePending replaceDecision = iota
+	replaceDone
+	replaceSkipped
+	replaceFailed
+)
+
+// toggleReplaceMode switches replace mode on or off
+// Entering it with marked results limits the replacement to them: the
+// others are skipped.
+func (m *Model) toggleReplaceMode() tea.Cmd {
+	if m.history != historyOff && !m.replaceMode {
+		m.actionStatus = "Replace works on the working tree only"
+		return nil
+	}
+	m.replaceMode = !m.replaceMode
+	if m.replaceMode {
+		m.inputMode = InputModeReplace
+		if len(m.marked) > 0 {
+			for _, result := range m.searchResults {
+				if !m.marked[result] && m.replaceDecisions[result] == replacePending {
+					m.replaceDecisions[result] = replaceSkipped
+				}
+			}
+		}
+	} else if m.inputMode == InputModeReplace {
+		m.inputMode = InputModeQuery
+	}
+	m.replaceStatus = ""
+	m.replaceError = nil
+	// Switch the preview between plain and diff view
+	return m.loadPreview()
+}
+
+// resetReplaceState forgets all replace decisions; called when a new search starts
+func (m *Model) resetReplaceState() {
+	m.replaceDecisions = make(map[*search.SearchResult]replaceDecision)
+	m.fileStamps = make(map[string]time.Time)
+	m.replaceStatus = ""
+	m.replaceError = nil
+}
+
+// replacer builds the replacer for the results currently shown
+// It uses the options of the search that produced them, not the current input.
+func (m *Model) replacer() (*replace.Replacer, error) {
+	return replace.NewReplacer(m.lastSearch, m.replacement)
+}
+
+// selectedResult returns the selected result, or nil if nothing is selected
+func (m *Model) selectedResult() *search.SearchResult {
+	if m.selectedIndex < 0 || m.selectedIndex >= len(m.searchResults) {
+		return nil
+	}
+	return m.searchResults[m.selectedIndex]
+}
+
+// replaceSelected replaces the selected match and moves to the next pending one
+func (m *Model) replaceSelected() tea.Cmd {
+	result := m.selectedResult()
+	if result == nil {
+		return nil
+	}
+	if m.replaceDecisions[result] == replacePending {
+		m.applyReplacements([]*search.SearchResult{result})
+	}
+	return m.selectNextPending()
+}
+
+// skipSelected leaves the selected match unchanged and moves to the next pending one
+func (m *Model) skipSelected() tea.Cmd {
+	result := m.selectedResult()
+	if result == nil {
+		return nil
+	}
+	if m.replaceDecisions[result] == replacePending {
+		m.replaceDecisions[result] = replaceSkipped
+	}
+	return m.selectNextPending()
+}
+
+// replaceAllInFile replaces every pending match in the selected result's file
+func (m *Model) replaceAllInFile() tea.Cmd {
+	selected := m.selectedResult()
+	if selected == nil {
+		return nil
+	}
+	var results []*search.SearchResult
+	for _, result := range m.searchResults {
+		if result.File == selected.File {
+			results = append(results, result)
+		}
+	}
+	m.applyReplacements(results)
+	return m.selectNextPending()
+}
+
+// replaceAll replaces every pending match
+func (m *Model) replaceAll() tea.Cmd {
+	m.applyReplacements(m.searchResults)
+	return m.loadPreview()
+}
+
+// applyReplacements writes the pending replacements among results
+// Each file is written once, atomically, and refused if it changed since the
+// search ran (or since fif last wrote it).
+func (m *Model) applyReplacements(results []*search.SearchResult) {
+	r, err := m.replacer()
+	if err != nil {
+		m.replaceError = err
+		return
+	}
+
+	replaced, files, failed := 0, 0, 0
+	var lastErr error
+	for _, group := range groupResults(results) {
+		var pending []*search.SearchResult
+		var edits []replace.Edit
+		for _, i := range group.indices {
+			result := results[i]
+			if m.replaceDecisions[result] != replacePending {
+				continue
+			}
+			if result.Binary {
+				m.replaceDecisions[result] = replaceSkipped
+				continue
+			}
+			edit, err := r.Edit(result)
+			if err != nil {
+				m.replaceDecisions[result] = replaceFailed
+				lastErr = err
+				continue
+			}
+			pending = append(pending, result)
+			edits = append(edits, edit)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+
+		notAfter, ok := m.fileStamps[group.file]
+		if !ok {
+			notAfter = m.searchStartedAt
+		}
+		modTime, err := replace.ApplyFile(pending[0].Path(), edits, notAfter)
+		if err != nil {
+			for _, result := range pending {
+				m.replaceDecisions[result] = replaceFailed
+			}
+			failed++
+			lastErr = err
+			continue
+		}
+		m.fileStamps[group.file] = modTime
+
+		for i, result := range pending {
+			m.replaceDecisions[result] = replaceDone
+			if result.LineLength > 0 {
+				result.LineLength += len(edits[i].New) - len(edits[i].Old)
+			}
+			result.Text = edits[i].New
+			result.Matches = nil
+		}
+		m.shiftOffsets(group.file, edits)
+		replaced += len(edits)
+		files++
+	}
+
+	m.replaceError = lastErr
+	m.replaceStatus = fmt.Sprintf("Replaced %s in %s", search.Plural(replaced, "match", "matches"), search.Plural(files, "file", "files"))
+	if failed > 0 {
+		m.replaceStatus += fmt.Sprintf(", %s refused", search.Plural(failed, "file", "files"))
+	}
+}
+
+// shiftOffsets moves the line offsets of the file's other results past the
+// lines that were just rewritten
+func (m *Model) shiftOffsets(file string, edits []replace.Edit) {
+	for _, result := range m.searchResults {
+		if result.File != file {
+			continue
+		}
+		var delta int64
+		for _, edit := range edits {
+			if edit.Offset < result.AbsoluteOffset {
+				delta += int64(len(edit.New) - len(edit.Old))
+			}
+		}
+		result.AbsoluteOffset += delta
+	}
+}
+
+// selectNextPending selects the next result that has not been decided yet
+func (m *Model) selectNextPending() tea.Cmd {
+	for i := m.selectedIndex + 1; i < len(m.searchResults); i++ {
+		if m.replaceDecisions[m.searchResults[i]] == replacePending {
+			m.selectedIndex = i
+			m.headerSelected = false
+			m.adjustScroll()
+			return m.loadPreview()
+		}
+	}
+	// Nothing left after the selection; refresh the preview of the current one
+	return m.loadPreview()
+}
+
+// loadReplacePreview loads the diff preview of the pending replacement of result
+func (m *Model) loadReplacePreview(result *search.SearchResult) tea.Cmd {
+	r, err := m.replacer()
+	if err != nil {
+		return func() tea.Msg {
+			return previewLoadedMsg{Result: result, Error: err}
+		}
+	}
+	newLine, err := r.ReplaceLine(result)
+	if err != nil {
+		return func() tea.Msg {
+			return previewLoadedMsg{Result: result, Error: err}
+		}
+	}
+	path := result.Path()
+	before, after := m.contextSize()
+	return func() tea.Msg {
+		p, err := preview.LoadReplacePreview(path, result.Line, before, after, newLine)
+		if err == nil && result.LineLength > 0 {
+			// A long line: show the replaced window rather than the start of the line
+			for i, line := range p.Diff {
+				switch line.Kind {
+				case preview.DiffRemoved:
+					p.Diff[i].Text = result.DisplayText()
+				case preview.DiffAdded:
+					p.Diff[i].Text = cropMarks(result, newLine)
+				}
+			}
+		}
+		return previewLoadedMsg{Result: result, Preview: p, Error: err}
+	}
+}
+
+// cropMarks adds the ellipses of a cropped result's line to text, a
+// replacement of its window
+func cropMarks(result *search.SearchResult, text string) string {
+	before, after := result.Cropped()
+	if before {
+		text = "…" + text
+	}
+	if after {
+		text += "…"
+	}
+	return text
+}
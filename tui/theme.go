@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// palette holds the colors of a theme
+type palette struct {
+	text      string // Result text
+	bright    string // Selected and input text
+	dim       string // Labels, status line and inactive toggles
+	border    string // Borders and line numbers
+	accent    string // Active scope tab and toggles
+	selection string // Selected result and hit line background
+	highlight string // Matched text
+	inputBg   string // Query input and match background
+	file      string // File headers in grouped view
+	removed   string // Removed line background in replace diffs
+	added     string // Added line background in replace diffs
+	err       string // Errors
+	syntax    string // Syntax highlighting theme of the preview, unless configured
+}
+
+// themes are the color themes selectable with the theme setting
+// "default" matches the styles declared in view.go.
+var themes = map[string]palette{
+	"default": {
+		text: "252", bright: "255", dim: "245", border: "240", accent: "62",
+		selection: "25", highlight: "220", inputBg: "236", file: "117",
+		removed: "52", added: "22", err: "196", syntax: "monokai",
+	},
+	"light": {
+		text: "236", bright: "232", dim: "242", border: "248", accent: "111",
+		selection: "153", highlight: "130", inputBg: "254", file: "25",
+		removed: "224", added: "194", err: "160", syntax: "github",
+	},
+	"high-contrast": {
+		text: "15", bright: "15", dim: "250", border: "250", accent: "21",
+		selection: "19", highlight: "226", inputBg: "0", file: "51",
+		removed: "88", added: "28", err: "9", syntax: "monokai",
+	},
+}
+
+// SetTheme switches the TUI styles to the named color theme
+func SetTheme(name string) error {
+	p, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (default, light or high-contrast)", name)
+	}
+	applyPalette(p)
+	return nil
+}
+
+// applyPalette recolors the styles declared in view.go
+func applyPalette(p palette) {
+	c := func(s string) lipgloss.Color { return lipgloss.Color(s) }
+
+	headerStyle = headerStyle.BorderForeground(c(p.border))
+	searchIconStyle = searchIconStyle.Foreground(c(p.highlight))
+	queryInputStyle = queryInputStyle.Foreground(c(p.bright)).Background(c(p.inputBg))
+	maskLabelStyle = maskLabelStyle.Foreground(c(p.dim))
+	statusStyle = statusStyle.Foreground(c(p.dim))
+	scopeStyle = scopeStyle.Foreground(c(p.bright)).Background(c(p.accent))
+	scopeInactiveStyle = scopeInactiveStyle.Foreground(c(p.dim))
+	toggleActiveStyle = toggleActiveStyle.Foreground(c(p.bright)).Background(c(p.accent))
+	toggleInactiveStyle = toggleInactiveStyle.Foreground(c(p.dim))
+
+	resultStyle = resultStyle.Foreground(c(p.text))
+	selectedResultStyle = selectedResultStyle.Foreground(c(p.bright)).Background(c(p.selection))
+	highlightStyle = highlightStyle.Foreground(c(p.highlight)).Background(c(p.inputBg))
+	fileHeaderStyle = fileHeaderStyle.Foreground(c(p.file))
+	fileInfoStyle = fileInfoStyle.Foreground(c(p.dim))
+
+	previewHeaderStyle = previewHeaderStyle.Foreground(c(p.dim))
+	previewStyle = previewStyle.BorderForeground(c(p.border))
+	lineNumberStyle = lineNumberStyle.Foreground(c(p.border))
+	hitLineStyle = hitLineStyle.Foreground(c(p.bright)).Background(c(p.selection))
+	hitLineNumberStyle = hitLineNumberStyle.Foreground(c(p.bright)).Background(c(p.selection))
+	removedLineStyle = removedLineStyle.Foreground(c(p.bright)).Background(c(p.removed))
+	addedLineStyle = addedLineStyle.Foreground(c(p.bright)).Background(c(p.added))
+
+	errorStyle = errorStyle.Foreground(c(p.err))
+}
@@ -3,8 +3,13 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/takaishi/fif/highlight"
+	"github.com/takaishi/fif/keymap"
+	"github.com/takaishi/fif/preview"
+	"github.com/takaishi/fif/scope"
 	"github.com/takaishi/fif/search"
 )
 
@@ -39,6 +44,15 @@ var (
 				Foreground(lipgloss.Color("245")).
 				Padding(0, 1)
 
+	toggleActiveStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("255")).
+				Background(lipgloss.Color("62")).
+				Padding(0, 1)
+
+	toggleInactiveStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("245")).
+				Padding(0, 1)
+
 	// Result styles
 	resultStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("252"))
@@ -53,6 +67,10 @@ var (
 			Background(lipgloss.Color("236")).
 			Bold(true)
 
+	fileHeaderStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("117")).
+			Bold(true)
+
 	fileInfoStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("245")).
 			Align(lipgloss.Right).
@@ -84,25 +102,46 @@ var (
 				Width(6).
 				Align(lipgloss.Right)
 
+	removedLineStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("255")).
+				Background(lipgloss.Color("52"))
+
+	addedLineStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("255")).
+			Background(lipgloss.Color("22"))
+
 	errorStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("196")).
 			Bold(true)
 )
 
-// renderView renders the entire UI
-func renderView(m *Model) string {
-	if m.width == 0 || m.height == 0 {
-		return "Initializing..."
-	}
+// resultsHeight is the fixed height of the results list
+const resultsHeight = 5
 
-	// Calculate layout heights
+// previewHeight returns the height available to the preview pane
+func (m *Model) previewHeight() int {
 	headerHeight := 3
+	if m.replaceMode {
+		headerHeight++ // Replacement input line
+	}
+	if m.history != historyOff {
+		headerHeight++ // History mode and revision input line
+	}
 	statusHeight := 1
-	const resultsHeight = 5 // Fixed height for results list
 	previewHeight := m.height - headerHeight - statusHeight - resultsHeight - 2
 	if previewHeight < 5 {
 		previewHeight = 5
 	}
+	return previewHeight
+}
+
+// renderView renders the entire UI
+func renderView(m *Model) string {
+	if m.width == 0 || m.height == 0 {
+		return "Initializing..."
+	}
+
+	previewHeight := m.previewHeight()
 
 	var sections []string
 
@@ -110,6 +149,20 @@ func renderView(m *Model) string {
 	header := renderHeader(m)
 	sections = append(sections, header)
 
+	// The directory picker replaces results and preview while it is open
+	if m.picker != nil {
+		sections = append(sections, renderPicker(m, resultsHeight+previewHeight))
+		return lipgloss.JoinVertical(lipgloss.Left, sections...)
+	}
+	if m.recall != nil {
+		sections = append(sections, renderRecallPicker(m, resultsHeight+previewHeight))
+		return lipgloss.JoinVertical(lipgloss.Left, sections...)
+	}
+	if m.showHelp {
+		sections = append(sections, renderHelp(m, resultsHeight+previewHeight))
+		return lipgloss.JoinVertical(lipgloss.Left, sections...)
+	}
+
 	// Results section
 	results := renderResults(m, resultsHeight)
 	sections = append(sections, results)
@@ -124,8 +177,32 @@ func renderView(m *Model) string {
 	return content
 }
 
-// renderHeader renders the search bar with icon, query, mask, and status
-func renderHeader(m *Model) string {
+// headerAction identifies what a click on a header item does
+type headerAction int
+
+const (
+	headerActionNone headerAction = iota
+	headerActionToggleMask
+	headerActionToggleCase
+	headerActionToggleWord
+	headerActionToggleRegex
+	headerActionScopeProject
+	headerActionScopeDirectory
+	headerActionScopeGit
+	headerActionScopePick
+	headerActionScopeNamed
+)
+
+// headerItem is a single rendered piece of the header line
+type headerItem struct {
+	view   string
+	action headerAction
+}
+
+// headerItems builds the pieces of the header line from left to right
+// It is shared by renderHeader and handleMouse so click positions always
+// match what is drawn
+func headerItems(m *Model) []headerItem {
 	// Search icon
 	icon := searchIconStyle.Render("🔍")
 
@@ -136,58 +213,207 @@ func renderHeader(m *Model) string {
 	}
 	queryDisplay := queryInputStyle.Render(queryValue)
 
+	// Search mode toggles (JetBrains style: Cc / W / .*)
+	caseLabel := "Cc"
+	if m.caseMode == search.CaseSmart {
+		caseLabel = "Cc~"
+	}
+	caseToggle := renderToggle(caseLabel, m.caseMode == search.CaseSensitive)
+	wordToggle := renderToggle("W", m.wholeWord)
+	regexToggle := renderToggle(".*", m.regexMode)
+
 	// File mask with checkbox
 	checkbox := "[ ]"
 	if m.maskEnabled {
 		checkbox = "[✓]"
 	}
 	maskLabel := maskLabelStyle.Render(fmt.Sprintf("%s File mask:", checkbox))
+	// While editing, show the raw input; otherwise show the parsed mask list
 	maskValue := m.maskInput.value
-	if maskValue == "" {
-		maskValue = "*"
-	}
+	maskValueStyle := maskLabelStyle
 	if m.inputMode == InputModeMask {
 		maskValue += "█" // Cursor indicator
+	} else if mask, err := search.ParseMask(maskValue); err != nil {
+		maskValueStyle = errorStyle
+	} else if mask.IsEmpty() {
+		maskValue = "*"
+	} else {
+		maskValue = mask.String()
+	}
+	maskDisplay := maskValueStyle.Render(" " + maskValue)
+
+	items := []headerItem{
+		{view: icon + " "},
+		{view: queryDisplay},
+		{view: " "},
+		{view: caseToggle, action: headerActionToggleCase},
+		{view: wordToggle, action: headerActionToggleWord},
+		{view: regexToggle, action: headerActionToggleRegex},
+		{view: "  "},
+		{view: maskLabel, action: headerActionToggleMask},
+		{view: maskDisplay},
+		{view: "  "},
 	}
-	maskDisplay := maskLabelStyle.Render(fmt.Sprintf("%s %s", maskLabel, maskValue))
 
-	// Search scope tabs (In Project / In Directory)
-	var projectTab, directoryTab string
-	if m.searchScope == "project" {
-		projectTab = scopeStyle.Render("In Project")
-		directoryTab = scopeInactiveStyle.Render("In Directory")
-	} else {
-		projectTab = scopeInactiveStyle.Render("In Project")
-		directoryTab = scopeStyle.Render("In Directory")
+	return append(items, scopeTabs(m)...)
+}
+
+// scopeTabs builds the search scope tabs of the header
+// Project and git scopes need a git repository. The directory tab shows
+// the picked directories once there are some; the git and named scope tabs
+// show the active scope of their kind, or the first one.
+func scopeTabs(m *Model) []headerItem {
+	tab := func(s scope.Scope, action headerAction) headerItem {
+		active := m.scope.Equal(s)
+		if s.Kind == scope.KindNamed || s.Kind == scope.KindDirs {
+			active = m.scope.Kind == s.Kind
+		}
+		if active {
+			return headerItem{view: scopeStyle.Render(s.Label()), action: action}
+		}
+		return headerItem{view: scopeInactiveStyle.Render(s.Label()), action: action}
 	}
 
-	// Only show project tab if git repository is detected
-	scopeTabs := directoryTab
+	var tabs []headerItem
 	if m.gitRoot != "" {
-		scopeTabs = lipgloss.JoinHorizontal(lipgloss.Left, projectTab, " ", directoryTab)
+		tabs = append(tabs, tab(scope.Project(), headerActionScopeProject))
+	}
+	tabs = append(tabs, tab(scope.Directory(), headerActionScopeDirectory))
+	if m.gitRoot != "" {
+		git := m.gitScopes[0]
+		if m.scope.IsGit() && m.scope.Kind != scope.KindProject {
+			git = m.scope
+		}
+		tabs = append(tabs, tab(git, headerActionScopeGit))
 	}
 
+	picked := scope.Dirs()
+	if m.scope.Kind == scope.KindDirs {
+		picked = m.scope
+	}
+	tabs = append(tabs, tab(picked, headerActionScopePick))
+
+	if len(m.namedScopes) > 0 {
+		named := m.namedScopes[0]
+		if m.scope.Kind == scope.KindNamed {
+			named = m.scope
+		}
+		tabs = append(tabs, tab(named, headerActionScopeNamed))
+	}
+
+	// Separate the tabs with a space
+	items := make([]headerItem, 0, 2*len(tabs))
+	for i, t := range tabs {
+		if i > 0 {
+			items = append(items, headerItem{view: " "})
+		}
+		items = append(items, t)
+	}
+	return items
+}
+
+// renderToggle renders a search mode toggle
+func renderToggle(label string, active bool) string {
+	if active {
+		return toggleActiveStyle.Render(label)
+	}
+	return toggleInactiveStyle.Render(label)
+}
+
+// renderHeader renders the search bar with icon, query, mask, and status
+func renderHeader(m *Model) string {
 	// Build header line
-	headerLine := lipgloss.JoinHorizontal(lipgloss.Left,
-		icon+" ",
-		queryDisplay,
-		"  ",
-		maskDisplay,
-		"  ",
-		scopeTabs,
-	)
+	items := headerItems(m)
+	views := make([]string, 0, len(items))
+	for _, item := range items {
+		views = append(views, item.view)
+	}
+	headerLine := lipgloss.JoinHorizontal(lipgloss.Left, views...)
+
+	headerLines := []string{headerLine}
+
+	// Replacement input (replace mode only)
+	if m.replaceMode {
+		replaceValue := m.replaceInput.value
+		if m.inputMode == InputModeReplace {
+			replaceValue += "█" // Cursor indicator
+		}
+		headerLines = append(headerLines, maskLabelStyle.Render("↳ Replace: ")+queryInputStyle.Render(replaceValue))
+	}
+
+	// Revision input (history mode only)
+	if m.history != historyOff {
+		revValue := m.revInput.value
+		if m.inputMode == InputModeRev {
+			revValue += "█" // Cursor indicator
+		} else if revValue == "" {
+			revValue = "HEAD"
+		}
+		label := "↳ History: Revision "
+		if m.history == historyPickaxe {
+			label = "↳ History: Pickaxe, commits adding or removing the query in "
+		}
+		headerLines = append(headerLines, maskLabelStyle.Render(label)+queryInputStyle.Render(revValue))
+	}
 
 	// Status line
 	status := renderStatus(m)
-	statusLine := statusStyle.Render(status)
+	headerLines = append(headerLines, statusStyle.Render(status))
 
 	// Combine
-	header := lipgloss.JoinVertical(lipgloss.Left, headerLine, statusLine)
+	header := lipgloss.JoinVertical(lipgloss.Left, headerLines...)
 	return headerStyle.Width(m.width - 2).Render(header)
 }
 
 // renderStatus renders the status information
 func renderStatus(m *Model) string {
+	status := renderSearchStatus(m)
+	if skipped := m.skipped.String(); skipped != "" {
+		status += " | " + skipped
+	}
+	if len(m.marked) > 0 {
+		status += fmt.Sprintf(" | %d marked", len(m.marked))
+	}
+	if m.actionStatus != "" {
+		status += " | " + m.actionStatus
+	}
+	if m.editorError != nil {
+		status += " | Editor: " + m.editorError.Error()
+	}
+	if !m.replaceMode {
+		return status
+	}
+	switch {
+	case m.replaceError != nil:
+		return status + " | Replace: " + m.replaceError.Error()
+	case m.replaceStatus != "":
+		return status + " | " + m.replaceStatus
+	default:
+		return status + " | " + renderReplaceHint(m.keys)
+	}
+}
+
+// renderReplaceHint lists the keys of the replace actions
+func renderReplaceHint(km *keymap.Keymap) string {
+	var hints []string
+	for _, h := range []struct {
+		action keymap.Action
+		label  string
+	}{
+		{keymap.Open, "replace"},
+		{keymap.ReplaceSkip, "skip"},
+		{keymap.ReplaceFile, "all in file"},
+		{keymap.ReplaceAll, "all"},
+	} {
+		if key := km.Key(h.action); key != "" {
+			hints = append(hints, key+": "+h.label)
+		}
+	}
+	return strings.Join(hints, "  ")
+}
+
+// renderSearchStatus renders the search part of the status information
+func renderSearchStatus(m *Model) string {
 	if m.isSearching {
 		return "Searching..."
 	}
@@ -219,7 +445,7 @@ func renderStatus(m *Model) string {
 func renderResults(m *Model, maxHeight int) string {
 	if len(m.searchResults) == 0 {
 		if m.query == "" {
-			return ""
+			return renderRecentSearches(m, maxHeight)
 		}
 		return "No results found"
 	}
@@ -227,21 +453,44 @@ func renderResults(m *Model, maxHeight int) string {
 	const visibleResults = 5
 	availableWidth := m.width - 4 // Reserve space for borders
 
-	// Calculate which results to display based on scroll offset
+	// Calculate which rows to display based on scroll offset
+	rows := m.rows()
+	selected := m.selectedRow(rows)
 	startIdx := m.resultsOffset
 	endIdx := startIdx + visibleResults
-	if endIdx > len(m.searchResults) {
-		endIdx = len(m.searchResults)
+	if endIdx > len(rows) {
+		endIdx = len(rows)
 	}
 
 	var lines []string
 	for i := startIdx; i < endIdx; i++ {
-		result := m.searchResults[i]
-		// Format result with 2-column layout: code snippet | file:line
-		line := formatResultJetBrains(m, result, availableWidth)
+		row := rows[i]
+
+		var line string
+		if row.isHeader() {
+			line = formatFileHeader(row, availableWidth)
+		} else if m.groupByFile {
+			// File is shown in the header row, so only the line number goes on the right
+			result := m.searchResults[row.resultIndex]
+			fileInfo := resultMarkers(m, result) + fmt.Sprintf("%d", result.Line)
+			line = formatResultJetBrains(result, fileInfo, 2, availableWidth)
+		} else {
+			// Format result with 2-column layout: code snippet | file:line
+			result := m.searchResults[row.resultIndex]
+			fileParts := strings.Split(result.File, "/")
+			fileName := fileParts[len(fileParts)-1]
+			if result.Revision != nil {
+				fileParts = strings.Split(result.Revision.Path, "/")
+				fileName = result.Revision.Short() + " " + fileParts[len(fileParts)-1]
+			}
+			fileInfo := resultMarkers(m, result) + fmt.Sprintf("%s %d", fileName, result.Line)
+			line = formatResultJetBrains(result, fileInfo, 0, availableWidth)
+		}
 
-		if i == m.selectedIndex {
+		if i == selected {
 			line = selectedResultStyle.Render(line)
+		} else if row.isHeader() {
+			line = fileHeaderStyle.Render(line)
 		} else {
 			line = resultStyle.Render(line)
 		}
@@ -251,13 +500,75 @@ func renderResults(m *Model, maxHeight int) string {
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
-// formatResultJetBrains formats a result in JetBrains style: code snippet | file:line
-func formatResultJetBrains(m *Model, result *search.SearchResult, width int) string {
-	// Extract filename from path
-	fileParts := strings.Split(result.File, "/")
-	fileName := fileParts[len(fileParts)-1]
-	fileInfo := fmt.Sprintf("%s %d", fileName, result.Line)
+// resultMarkers returns the markers shown before the file info of a result
+func resultMarkers(m *Model, result *search.SearchResult) string {
+	marker := replaceMarker(m, result)
+	if m.marked[result] {
+		marker = "● " + marker
+	}
+	return marker
+}
 
+// replaceMarker returns the marker shown before the file info of a result
+// whose replacement has been decided
+func replaceMarker(m *Model, result *search.SearchResult) string {
+	switch m.replaceDecisions[result] {
+	case replaceDone:
+		return "✓ "
+	case replaceSkipped:
+		return "– "
+	case replaceFailed:
+		return "✗ "
+	}
+	return ""
+}
+
+// formatFileHeader formats a file header row of the grouped view: path and hit count,
+// and the commit of a history result
+func formatFileHeader(row resultRow, width int) string {
+	marker := "▾"
+	if row.collapsed {
+		marker = "▸"
+	}
+	hits := "1 match"
+	if row.count != 1 {
+		hits = fmt.Sprintf("%d matches", row.count)
+	}
+	header := fmt.Sprintf("%s %s  %s", marker, row.file, hits)
+	if row.revision != nil {
+		header += "  " + revisionInfo(row.revision)
+	}
+	return lipgloss.NewStyle().Width(width).Render(truncateText(header, width))
+}
+
+// truncateText truncates text to maxWidth characters with an ellipsis
+func truncateText(text string, maxWidth int) string {
+	return highlightMatches(text, nil, maxWidth)
+}
+
+// resultSnippet renders the line of a result with its matches highlighted
+// A cropped long line gets an ellipsis on the cropped side, a binary match
+// just says so.
+func resultSnippet(result *search.SearchResult, maxWidth int) string {
+	if result.Binary {
+		return fileInfoStyle.Render(truncateText(search.BinaryText, maxWidth))
+	}
+	before, after := result.Cropped()
+	prefix, suffix := "", ""
+	if before {
+		prefix = "…"
+		maxWidth--
+	}
+	// A line cut to the width already ends with "..."
+	if after && utf8.RuneCountInString(result.Text) < maxWidth {
+		suffix = "…"
+	}
+	return prefix + highlightMatches(result.Text, result.Matches, maxWidth) + suffix
+}
+
+// formatResultJetBrains formats a result in JetBrains style: code snippet | file info
+// indent is the number of spaces before the code snippet
+func formatResultJetBrains(result *search.SearchResult, fileInfo string, indent, width int) string {
 	// Reserve space for file info on the right (minimum 25 chars for filename + line number)
 	fileInfoAreaWidth := 30
 	if fileInfoAreaWidth > width/3 {
@@ -273,8 +584,8 @@ func formatResultJetBrains(m *Model, result *search.SearchResult, width int) str
 		fileInfoAreaWidth = width - codeWidth
 	}
 
-	// Format code snippet with query highlight (left-aligned, fixed width)
-	codeSnippet := highlightQuery(m.query, result.Text, codeWidth)
+	// Format code snippet with match highlight (left-aligned, fixed width)
+	codeSnippet := strings.Repeat(" ", indent) + resultSnippet(result, codeWidth-indent)
 	// Ensure code snippet doesn't exceed its allocated width
 	codeSnippetStyled := lipgloss.NewStyle().Width(codeWidth).Render(codeSnippet)
 
@@ -291,77 +602,90 @@ func formatResultJetBrains(m *Model, result *search.SearchResult, width int) str
 	return resultLineStyled
 }
 
-// highlightQuery highlights the search query in the text
-func highlightQuery(query, text string, maxWidth int) string {
-	if query == "" {
-		// Truncate if needed
-		if len(text) > maxWidth {
-			return text[:maxWidth-3] + "..."
+// highlightMatches highlights the given match ranges in the text
+// The text is truncated to maxWidth characters before styling so that
+// ANSI sequences are never cut in half
+func highlightMatches(text string, matches []search.Submatch, maxWidth int) string {
+	return renderCode(text, nil, matches, maxWidth, nil)
+}
+
+// renderCode colors the text with its syntax spans and highlights the match
+// ranges on top of them, truncating it to maxWidth characters like
+// highlightMatches
+// With a line style (e.g. the hit line's), every segment is drawn on it.
+func renderCode(text string, syntax []highlight.Span, matches []search.Submatch, maxWidth int, line *lipgloss.Style) string {
+	// Truncate if needed
+	cut := len(text)
+	suffix := ""
+	if maxWidth > 3 && utf8.RuneCountInString(text) > maxWidth {
+		visibleLen := 0
+		for i := range text {
+			if visibleLen >= maxWidth-3 {
+				cut = i
+				break
+			}
+			visibleLen++
 		}
-		return text
+		suffix = "..."
 	}
 
-	// Simple case-insensitive highlighting
-	queryLower := strings.ToLower(query)
-	textLower := strings.ToLower(text)
-
-	// Find all occurrences
-	var parts []string
-	lastIndex := 0
-	searchIndex := 0
-
-	for {
-		idx := strings.Index(textLower[searchIndex:], queryLower)
-		if idx == -1 {
-			break
+	// Style of each byte: 0 for plain text, -1 for a match, i+1 for syntax[i]
+	kinds := make([]int, cut)
+	for i, span := range syntax {
+		for j := max(span.Start, 0); j < min(span.End, cut); j++ {
+			kinds[j] = i + 1
 		}
-		actualIdx := searchIndex + idx
-
-		// Add text before match
-		if actualIdx > lastIndex {
-			parts = append(parts, text[lastIndex:actualIdx])
+	}
+	for _, match := range matches {
+		for j := max(match.Start, 0); j < min(match.End, cut); j++ {
+			kinds[j] = -1
 		}
-
-		// Add highlighted match
-		matchText := text[actualIdx : actualIdx+len(query)]
-		parts = append(parts, highlightStyle.Render(matchText))
-
-		lastIndex = actualIdx + len(query)
-		searchIndex = lastIndex
 	}
 
-	// Add remaining text
-	if lastIndex < len(text) {
-		parts = append(parts, text[lastIndex:])
+	var b strings.Builder
+	for start := 0; start < cut; {
+		end := start + 1
+		for end < cut && kinds[end] == kinds[start] {
+			end++
+		}
+		segment := text[start:end]
+		switch kind := kinds[start]; {
+		case kind < 0:
+			b.WriteString(highlightStyle.Render(segment))
+		case kind > 0:
+			style := syntaxStyle(syntax[kind-1].Style)
+			if line != nil {
+				style = style.Inherit(*line)
+			}
+			b.WriteString(style.Render(segment))
+		case line != nil:
+			b.WriteString(line.Render(segment))
+		default:
+			b.WriteString(segment)
+		}
+		start = end
+	}
+	if line != nil && suffix != "" {
+		suffix = line.Render(suffix)
 	}
+	b.WriteString(suffix)
+	return b.String()
+}
 
-	// Join parts
-	highlighted := strings.Join(parts, "")
+// syntaxStyles caches the lipgloss style of each syntax highlighting style
+var syntaxStyles = map[highlight.Style]lipgloss.Style{}
 
-	// Truncate if needed
-	if len(highlighted) > maxWidth {
-		// Try to truncate while preserving ANSI codes
-		truncated := highlighted
-		// Simple truncation (could be improved to handle ANSI codes properly)
-		visibleLen := 0
-		inAnsi := false
-		for i, r := range highlighted {
-			if r == '\x1b' {
-				inAnsi = true
-			} else if inAnsi && r == 'm' {
-				inAnsi = false
-			} else if !inAnsi {
-				visibleLen++
-				if visibleLen >= maxWidth-3 {
-					truncated = highlighted[:i] + "..."
-					break
-				}
-			}
+// syntaxStyle returns the lipgloss style drawing a syntax highlighting style
+func syntaxStyle(s highlight.Style) lipgloss.Style {
+	style, ok := syntaxStyles[s]
+	if !ok {
+		style = lipgloss.NewStyle().Bold(s.Bold).Italic(s.Italic).Underline(s.Underline)
+		if s.Color != "" {
+			style = style.Foreground(lipgloss.Color(s.Color))
 		}
-		return truncated
+		syntaxStyles[s] = style
 	}
-
-	return highlighted
+	return style
 }
 
 // renderPreview renders the code preview
@@ -374,16 +698,47 @@ func renderPreview(m *Model, maxHeight int) string {
 		return ""
 	}
 
-	// Preview header with file path
+	// Preview header with file path, relative to the current directory
 	filePath := m.preview.File
+	if m.previewResult != nil && m.previewResult.Revision != nil {
+		// A file from history: show the commit it comes from
+		filePath = m.previewResult.File + "  " + revisionInfo(m.previewResult.Revision) + "  " + m.previewResult.Revision.Subject
+	} else if m.previewResult != nil {
+		filePath = m.previewResult.RelPath(m.currentDir)
+	}
+	if m.preview.Diff == nil && m.previewTop > 0 {
+		// Position of the viewport in the file
+		last := min(m.previewTop+maxHeight-3, m.preview.StartLine+len(m.preview.Lines)-1)
+		filePath += fmt.Sprintf("  lines %d-%d", m.previewTop, last)
+	}
 	header := previewHeaderStyle.Render(filePath)
 
 	var lines []string
 	lines = append(lines, header)
 
 	// Code lines
-	availableWidth := m.width - 10 // Reserve space for line numbers and borders
-	for i, line := range m.preview.Lines {
+	availableWidth := m.width - 13 // Reserve space for line numbers, borders and padding
+
+	if m.preview.Binary {
+		lines = append(lines, fileInfoStyle.Render("Binary file, no preview"))
+		return previewStyle.Width(m.width - 2).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	}
+
+	// Pending replacement: show the hit line as removed and its replacement as added
+	if m.preview.Diff != nil {
+		for _, diffLine := range m.preview.Diff {
+			if len(lines) >= maxHeight-1 {
+				break
+			}
+			lines = append(lines, renderDiffLine(diffLine, availableWidth))
+		}
+		previewContent := lipgloss.JoinVertical(lipgloss.Left, lines...)
+		return previewStyle.Width(m.width - 2).Render(previewContent)
+	}
+
+	// Show the lines from the top of the viewport
+	for i := max(m.previewTop-m.preview.StartLine, 0); i < len(m.preview.Lines); i++ {
+		line := m.preview.Lines[i]
 		if len(lines) >= maxHeight-1 {
 			break
 		}
@@ -391,18 +746,28 @@ func renderPreview(m *Model, maxHeight int) string {
 		lineNum := m.preview.StartLine + i
 		lineNumStr := fmt.Sprintf("%4d", lineNum)
 
+		var syntax []highlight.Span
+		if i < len(m.preview.Syntax) {
+			syntax = m.preview.Syntax[i]
+		}
+
 		// Highlight the hit line
 		if i+1 == m.preview.HitLine {
 			lineNumStr = hitLineNumberStyle.Render(lineNumStr)
-			// Highlight query in the hit line
-			line = highlightQueryInPreview(m.query, line, availableWidth)
-			line = hitLineStyle.Render(line)
+			// Highlight matches in the hit line
+			var matches []search.Submatch
+			if m.previewResult != nil {
+				matches = m.previewResult.Matches
+			}
+			if r := m.previewResult; r != nil && r.LineLength > 0 {
+				// A long line: show the window around the match instead of its start
+				line = renderCode(r.DisplayText(), nil, shiftMatches(r, matches), availableWidth, &hitLineStyle)
+			} else {
+				line = renderCode(line, syntax, matches, availableWidth, &hitLineStyle)
+			}
 		} else {
 			lineNumStr = lineNumberStyle.Render(lineNumStr)
-			// Truncate long lines
-			if len(line) > availableWidth {
-				line = line[:availableWidth-3] + "..."
-			}
+			line = renderCode(line, syntax, nil, availableWidth, nil)
 		}
 
 		lines = append(lines, fmt.Sprintf("%s | %s", lineNumStr, line))
@@ -412,13 +777,32 @@ func renderPreview(m *Model, maxHeight int) string {
 	return previewStyle.Width(m.width - 2).Render(previewContent)
 }
 
-// highlightQueryInPreview highlights query in preview line
-func highlightQueryInPreview(query, line string, maxWidth int) string {
-	if query == "" {
-		if len(line) > maxWidth {
-			return line[:maxWidth-3] + "..."
-		}
-		return line
+// shiftMatches moves the matches of a cropped result past the ellipsis
+// DisplayText puts before its text
+func shiftMatches(result *search.SearchResult, matches []search.Submatch) []search.Submatch {
+	if before, _ := result.Cropped(); !before {
+		return matches
+	}
+	shift := len("…")
+	shifted := make([]search.Submatch, len(matches))
+	for i, m := range matches {
+		m.Start += shift
+		m.End += shift
+		shifted[i] = m
+	}
+	return shifted
+}
+
+// renderDiffLine renders a single line of a replace diff preview
+func renderDiffLine(diffLine preview.DiffLine, maxWidth int) string {
+	lineNumStr := fmt.Sprintf("%4d", diffLine.Number)
+	text := truncateText(diffLine.Text, maxWidth)
+	switch diffLine.Kind {
+	case preview.DiffRemoved:
+		return removedLineStyle.Render(fmt.Sprintf("%s - %s", lineNumStr, text))
+	case preview.DiffAdded:
+		return addedLineStyle.Render(fmt.Sprintf("%s + %s", lineNumStr, text))
+	default:
+		return fmt.Sprintf("%s | %s", lineNumberStyle.Render(lineNumStr), text)
 	}
-	return highlightQuery(query, line, maxWidth)
 }
@@ -0,0 +1,124 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// contextStep is the number of lines Alt+E / Alt+T add to or remove from
+// each side of the preview context
+const contextStep = 5
+
+// wheelLines is the number of lines a mouse wheel step scrolls the preview
+const wheelLines = 3
+
+// previewCodeLines returns the number of file lines the preview pane shows
+func (m *Model) previewCodeLines() int {
+	// One line is taken by the file path header
+	if lines := m.previewHeight() - 2; lines > 1 {
+		return lines
+	}
+	return 1
+}
+
+// contextSize returns the lines of context loaded before and after the hit
+// With fitting the context fills the pane, split in the configured
+// before/after ratio; otherwise the configured sizes are used. Alt+E / Alt+T
+// adjust both on top of that.
+func (m *Model) contextSize() (before, after int) {
+	before, after = m.previewBefore, m.previewAfter
+	if m.previewFit {
+		lines := m.previewCodeLines() - 1 // The hit line itself
+		if total := before + after; total > 0 {
+			before = lines * before / total
+		} else {
+			before = lines / 3
+		}
+		after = lines - before
+	}
+	return max(before+m.previewGrow, 0), max(after+m.previewGrow, 0)
+}
+
+// previewWindow returns the lines loaded before and after the hit: the
+// context and what scrolling added to it
+func (m *Model) previewWindow() (before, after int) {
+	before, after = m.contextSize()
+	return before + m.previewExtendBefore, after + m.previewExtendAfter
+}
+
+// adjustContext grows (delta > 0) or shrinks the preview context
+func (m *Model) adjustContext(delta int) tea.Cmd {
+	before, after := m.contextSize()
+	if delta < 0 && before == 0 && after == 0 {
+		return nil
+	}
+	m.previewGrow += delta
+	m.previewTop = 0
+	m.previewExtendBefore, m.previewExtendAfter = 0, 0
+	return m.loadPreview()
+}
+
+// placePreview positions the viewport after a preview was loaded
+// A newly loaded preview starts at its first line, or scrolls the hit line
+// into view if the context doesn't fit. A scrolled preview stays where it is.
+func (m *Model) placePreview() {
+	if m.preview == nil {
+		return
+	}
+	visible := m.previewCodeLines()
+	hit := m.preview.StartLine + m.preview.HitLine - 1
+	if m.previewTop == 0 {
+		m.previewTop = m.preview.StartLine
+		if len(m.preview.Lines) > visible && hit-m.previewTop >= visible {
+			m.previewTop = hit - visible/3
+		}
+	}
+	last := m.preview.StartLine + len(m.preview.Lines) - 1
+	m.previewTop = min(m.previewTop, last-visible+1)
+	m.previewTop = max(m.previewTop, m.preview.StartLine)
+}
+
+// scrollPreview scrolls the preview by delta lines
+// Scrolling past the loaded lines loads more of the file, a page ahead.
+func (m *Model) scrollPreview(delta int) tea.Cmd {
+	if m.preview == nil || m.preview.Diff != nil {
+		return nil
+	}
+	visible := m.previewCodeLines()
+	top := m.previewTop + delta
+	last := m.preview.StartLine + len(m.preview.Lines) - 1
+
+	switch {
+	case top < m.preview.StartLine && m.preview.StartLine > 1:
+		m.previewExtendBefore += m.preview.StartLine - top + visible
+		m.previewTop = max(top, 1)
+		return m.loadPreview()
+	case top+visible-1 > last && !m.preview.AtEnd:
+		m.previewExtendAfter += top + visible - 1 - last + visible
+		m.previewTop = top
+		return m.loadPreview()
+	}
+	m.previewTop = top
+	m.placePreview()
+	return nil
+}
+
+// selectHitInFile selects the next (dir > 0) or previous hit in the
+// selected result's file, wrapping around
+func (m *Model) selectHitInFile(dir int) tea.Cmd {
+	current := m.selectedResult()
+	if current == nil {
+		return nil
+	}
+	n := len(m.searchResults)
+	for i := 1; i < n; i++ {
+		index := ((m.selectedIndex+dir*i)%n + n) % n
+		if m.searchResults[index].File == current.File {
+			m.selectedIndex = index
+			m.headerSelected = false
+			delete(m.collapsedFiles, current.File)
+			m.adjustScroll()
+			return m.loadPreview()
+		}
+	}
+	return nil
+}